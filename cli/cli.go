@@ -63,6 +63,11 @@ func (s *Subcommand) Config() *config.Config {
 	return s.config
 }
 
+// ConfigPath returns the path passed with --config, empty if the default locations are used.
+func (s *Subcommand) ConfigPath() string {
+	return rootArgs.configPath
+}
+
 func (s *Subcommand) run(cmd *cobra.Command, args []string) {
 	s.tryParseConfig()
 	err := s.Run(s, args)
@@ -12,6 +12,7 @@ import (
 	"github.com/zrepl/zrepl/daemon/job"
 	"github.com/zrepl/zrepl/daemon/logging"
 	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/pruning"
 	"os"
 )
 
@@ -58,6 +59,11 @@ var ConfigcheckCmd = &cli.Subcommand{
 			}
 		}
 
+		// keep rules that were valid but behave differently since keep rules are scoped
+		for _, note := range pruningMigrationNotes(subcommand.Config()) {
+			fmt.Fprintf(os.Stderr, "note: %s\n", note)
+		}
+
 		// further: try to build logging outlets
 		outlets, err := logging.OutletsFromConfig(*subcommand.Config().Global.Logging)
 		if err != nil {
@@ -110,3 +116,29 @@ var ConfigcheckCmd = &cli.Subcommand{
 	},
 }
 
+func pruningMigrationNotes(c *config.Config) (notes []string) {
+	add := func(job, list string, in []config.PruningEnum) {
+		for _, note := range pruning.ScopeMigrationNotes(in) {
+			notes = append(notes, fmt.Sprintf("job %q %s: %s", job, list, note))
+		}
+	}
+	for _, j := range c.Jobs {
+		var sr *config.PruningSenderReceiver
+		switch v := j.Ret.(type) {
+		case *config.PushJob:
+			sr = &v.Pruning
+		case *config.PullJob:
+			sr = &v.Pruning
+		case *config.LocalJob:
+			sr = &v.Pruning
+		case *config.SnapJob:
+			add(v.Name, "keep", v.Pruning.Keep)
+		}
+		if sr != nil {
+			add(j.Name(), "keep_sender", sr.KeepSender)
+			add(j.Name(), "keep_receiver", sr.KeepReceiver)
+			add(j.Name(), "keep_sender_bookmarks", sr.KeepSenderBookmarks)
+		}
+	}
+	return notes
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"fmt"
+	"github.com/spf13/pflag"
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/zfs"
+	"strings"
+)
+
+var HoldsCmd = &cli.Subcommand{
+	Use:   "holds",
+	Short: "list and release the holds that zrepl places on replicated snapshots",
+	SetupSubcommands: func() []*cli.Subcommand {
+		return []*cli.Subcommand{holdsList, holdsRelease}
+	},
+}
+
+var holdsArgs struct {
+	job       string
+	recursive bool
+	dryRun    bool
+}
+
+func setupHoldsFlags(f *pflag.FlagSet) {
+	f.StringVar(&holdsArgs.job, "job", "", "only holds of this job")
+	f.BoolVarP(&holdsArgs.recursive, "recursive", "r", false, "include descendants of FILESYSTEM")
+}
+
+var holdsList = &cli.Subcommand{
+	Use:             "list [--job JOB] [-r] [FILESYSTEM]",
+	Short:           "list zrepl's holds on the snapshots of FILESYSTEM or of all filesystems",
+	NoRequireConfig: true,
+	SetupFlags:      setupHoldsFlags,
+	Run: func(subcommand *cli.Subcommand, args []string) error {
+		if len(args) > 1 {
+			return fmt.Errorf("specify at most one filesystem")
+		}
+		root := ""
+		if len(args) == 1 {
+			root = args[0]
+		}
+		holds, err := zreplHolds(root)
+		if err != nil {
+			return err
+		}
+		for _, h := range holds {
+			job := strings.TrimPrefix(h.Tag, zfs.ReplicationHoldTagPrefix)
+			fmt.Printf("%s\t%s\t%s\n", h.Snapshot, job, h.Since)
+		}
+		return nil
+	},
+}
+
+var holdsRelease = &cli.Subcommand{
+	Use:             "release [--job JOB] [-r] [--dry-run] FILESYSTEM|SNAPSHOT...",
+	Short:           "release zrepl's holds on the given snapshots or on the snapshots of the given filesystems",
+	NoRequireConfig: true,
+	SetupFlags: func(f *pflag.FlagSet) {
+		setupHoldsFlags(f)
+		f.BoolVar(&holdsArgs.dryRun, "dry-run", false, "only print the holds that would be released")
+	},
+	Run: func(subcommand *cli.Subcommand, args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("specify at least one filesystem or snapshot")
+		}
+		var holds []zfs.Hold
+		for _, arg := range args {
+			var hs []zfs.Hold
+			var err error
+			if strings.Contains(arg, "@") {
+				hs, err = zfs.ZFSHolds(arg)
+				hs = filterZreplHolds(hs)
+			} else {
+				hs, err = zreplHolds(arg)
+			}
+			if err != nil {
+				return err
+			}
+			holds = append(holds, hs...)
+		}
+		for _, h := range holds {
+			fmt.Printf("release %s %s\n", h.Tag, h.Snapshot)
+			if holdsArgs.dryRun {
+				continue
+			}
+			if err := zfs.ZFSRelease(h.Tag, h.Snapshot); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// zreplHolds returns zrepl's holds on the snapshots of root (all filesystems if empty) that match holdsArgs.
+func zreplHolds(root string) ([]zfs.Hold, error) {
+	snaps, err := zfs.ZFSListHeldSnapshots(root, holdsArgs.recursive || root == "")
+	if err != nil {
+		return nil, err
+	}
+	holds, err := zfs.ZFSHolds(snaps...)
+	if err != nil {
+		return nil, err
+	}
+	return filterZreplHolds(holds), nil
+}
+
+func filterZreplHolds(holds []zfs.Hold) []zfs.Hold {
+	res := make([]zfs.Hold, 0, len(holds))
+	for _, h := range holds {
+		if !zfs.IsReplicationHoldTag(h.Tag) {
+			continue
+		}
+		if holdsArgs.job != "" && h.Tag != zfs.ReplicationHoldTag(holdsArgs.job) {
+			continue
+		}
+		res = append(res, h)
+	}
+	return res
+}
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+var pruneArgs struct {
+	dryRun bool
+	format string
+}
+
+var PruneCmd = &cli.Subcommand{
+	Use:     "prune --dry-run [--format human|json] JOB",
+	Short:   "show which snapshots the pruning of JOB would keep or destroy, and which keep rules keep them",
+	Example: `  zrepl prune --dry-run prod_to_backups`,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.BoolVar(&pruneArgs.dryRun, "dry-run", false, "only plan the pruning, required")
+		f.StringVar(&pruneArgs.format, "format", "human", "output format [human|json]")
+	},
+	Run: runPruneCmd,
+}
+
+func runPruneCmd(subcommand *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("Expected 1 argument: JOB")
+	}
+	if !pruneArgs.dryRun {
+		return errors.New("only --dry-run is supported, the job prunes during its invocations (see zrepl signal wakeup)")
+	}
+	if pruneArgs.format != "human" && pruneArgs.format != "json" {
+		return fmt.Errorf("unsupported --format %q", pruneArgs.format)
+	}
+
+	httpc, err := controlHttpClient(subcommand.Config().Global.Control.SockPath)
+	if err != nil {
+		return err
+	}
+
+	req := daemon.PruneReq{
+		Name:   args[0],
+		DryRun: true,
+	}
+	var res daemon.PruneRes
+	if err := jsonRequestResponse(httpc, daemon.ControlJobEndpointPrune, req, &res); err != nil {
+		return err
+	}
+
+	if pruneArgs.format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+
+	failed := false
+	for _, side := range pruneSides(res.Sides) {
+		if !printPruneDryRun(os.Stdout, side, res.Sides[side]) {
+			failed = true
+		}
+	}
+	if failed {
+		return errors.New("pruning could not be planned for all filesystems")
+	}
+	return nil
+}
+
+// pruneSides returns the sides of sides in the order in which the job prunes them.
+func pruneSides(sides map[string]*pruner.Report) []string {
+	order := map[string]int{"sender": 0, "receiver": 1, "local": 2}
+	res := make([]string, 0, len(sides))
+	for side := range sides {
+		res = append(res, side)
+	}
+	sort.Slice(res, func(i, j int) bool { return order[res[i]] < order[res[j]] })
+	return res
+}
+
+// printPruneDryRun prints the plan of side and returns false if planning failed.
+func printPruneDryRun(out io.Writer, side string, r *pruner.Report) (ok bool) {
+	fmt.Fprintf(out, "%s:\n", side)
+	ok = true
+	if r.Error != "" {
+		fmt.Fprintf(out, "  error: %s\n", r.Error)
+		ok = false
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, fs := range r.Pending {
+		fmt.Fprintf(w, "  %s\n", fs.Filesystem)
+		if fs.LastError != "" {
+			fmt.Fprintf(w, "    error: %s\n", fs.LastError)
+			ok = false
+			continue
+		}
+		printPruneDryRunVersions(w, "@", fs.SnapshotList)
+		printPruneDryRunVersions(w, "#", fs.BookmarkList)
+		fmt.Fprintf(w, "    %d of %d snapshots would be destroyed\n", len(fs.DestroyList), len(fs.SnapshotList))
+	}
+	w.Flush()
+	return ok
+}
+
+func printPruneDryRunVersions(w io.Writer, delim string, list []pruner.SnapshotReport) {
+	for _, s := range list {
+		action := "keep"
+		if s.Destroy {
+			action = "destroy"
+		}
+		if s.DestroyForSpace {
+			action = "destroy (space)"
+		}
+		keptBy := make([]string, len(s.KeptBy))
+		for i, k := range s.KeptBy {
+			keptBy[i] = k.String()
+		}
+		fmt.Fprintf(w, "    %s\t%s%s\t%s\n", action, delim, s.Name, strings.Join(keptBy, ", "))
+	}
+}
@@ -1,23 +1,62 @@
 package client
 
 import (
+	"fmt"
 	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
 	"github.com/zrepl/zrepl/cli"
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon"
+	"strings"
+	"time"
 )
 
+var signalArgs struct {
+	cancelInvocations bool
+	pauseFor          time.Duration
+	pauseUntil        string
+}
+
 var SignalCmd = &cli.Subcommand{
-	Use:   "signal [wakeup|reset] JOB",
-	Short: "wake up a job from wait state or abort its current invocation",
+	Use:   "signal [wakeup|reset|resume] JOB | signal pause [--for DURATION|--until TIME] JOB | signal reload [--cancel]",
+	Short: "wake up a job from wait state, abort its current invocation, pause or resume it, or reload the config",
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.BoolVar(&signalArgs.cancelInvocations, "cancel", false, "reload: cancel the current invocation of removed and changed jobs instead of waiting for it to finish")
+		f.DurationVar(&signalArgs.pauseFor, "for", 0, "pause: resume the job automatically after this duration (e.g. 2h30m)")
+		f.StringVar(&signalArgs.pauseUntil, "until", "", "pause: resume the job automatically at this time (RFC 3339, e.g. 2006-01-02T15:04:05+07:00)")
+	},
 	Run: func(subcommand *cli.Subcommand, args []string) error {
 		return runSignalCmd(subcommand.Config(), args)
 	},
 }
 
 func runSignalCmd(config *config.Config, args []string) error {
+	if len(args) == 1 && args[0] == "reload" {
+		return runSignalReload(config)
+	}
 	if len(args) != 2 {
-		return errors.Errorf("Expected 2 arguments: [wakeup|reset] JOB")
+		return errors.Errorf("Expected 2 arguments: [wakeup|reset|pause|resume] JOB")
+	}
+	req := daemon.SignalReq{Name: args[1], Op: args[0]}
+	if signalArgs.pauseFor != 0 || signalArgs.pauseUntil != "" {
+		if req.Op != "pause" {
+			return errors.New("--for and --until can only be used with pause")
+		}
+		if signalArgs.pauseFor != 0 && signalArgs.pauseUntil != "" {
+			return errors.New("--for and --until are mutually exclusive")
+		}
+		if signalArgs.pauseFor != 0 {
+			if signalArgs.pauseFor < 0 {
+				return errors.New("--for must be positive")
+			}
+			req.Until = time.Now().Add(signalArgs.pauseFor)
+		} else {
+			until, err := time.Parse(time.RFC3339, signalArgs.pauseUntil)
+			if err != nil {
+				return errors.Wrap(err, "invalid --until")
+			}
+			req.Until = until
+		}
 	}
 
 	httpc, err := controlHttpClient(config.Global.Control.SockPath)
@@ -25,15 +64,37 @@ func runSignalCmd(config *config.Config, args []string) error {
 		return err
 	}
 
-	err = jsonRequestResponse(httpc, daemon.ControlJobEndpointSignal,
-		struct {
-			Name string
-			Op string
-		}{
-			Name: args[1],
-			Op: args[0],
-		},
-		struct{}{},
-	)
+	err = jsonRequestResponse(httpc, daemon.ControlJobEndpointSignal, req, struct{}{})
 	return err
 }
+
+func runSignalReload(config *config.Config) error {
+	httpc, err := controlHttpClient(config.Global.Control.SockPath)
+	if err != nil {
+		return err
+	}
+
+	req := daemon.ReloadReq{CancelInvocations: signalArgs.cancelInvocations}
+	var res daemon.ReloadRes
+	if err := jsonRequestResponse(httpc, daemon.ControlJobEndpointReload, req, &res); err != nil {
+		return err
+	}
+
+	printJobs := func(what string, jobs []string) {
+		if len(jobs) > 0 {
+			fmt.Printf("%-10s %s\n", what+":", strings.Join(jobs, ", "))
+		}
+	}
+	printJobs("added", res.Added)
+	printJobs("removed", res.Removed)
+	printJobs("restarted", res.Restarted)
+	printJobs("unchanged", res.Unchanged)
+	if len(res.Pending) > 0 {
+		fmt.Printf("waiting for the current invocation of %s to finish before stopping them (use --cancel to abort it)\n",
+			strings.Join(res.Pending, ", "))
+	}
+	if res.GlobalChanged {
+		fmt.Println("changes to the global section are not applied, restart the daemon to apply them")
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/daemon"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"os"
+)
+
+var snapshotArgs struct {
+	filesystems []string
+	suffix      string
+	wakeup      bool
+	dryRun      bool
+}
+
+var SnapshotCmd = &cli.Subcommand{
+	Use:   "snapshot [--fs FS]... [--suffix SUFFIX] [--wakeup | --dry-run] JOB",
+	Short: "take snapshots of the filesystems of a push, source, local or snap job now",
+	Example: `  zrepl snapshot --wakeup prod_to_backups
+  zrepl snapshot --fs pool/db --suffix before_upgrade prod_to_backups`,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.StringSliceVar(&snapshotArgs.filesystems, "fs", nil, "only snapshot these filesystems (must be matched by the job's filesystem filter)")
+		f.StringVar(&snapshotArgs.suffix, "suffix", "", "snapshot name suffix appended to the job's prefix (default: current time)")
+		f.BoolVar(&snapshotArgs.wakeup, "wakeup", false, "wake up the job to replicate (push, local) or prune (snap) the snapshots")
+		f.BoolVar(&snapshotArgs.dryRun, "dry-run", false, "only run the snapshotting hooks (with ZREPL_DRYRUN=true), do not take snapshots")
+	},
+	Run: runSnapshotCmd,
+}
+
+func runSnapshotCmd(subcommand *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("Expected 1 argument: JOB")
+	}
+	if snapshotArgs.wakeup && snapshotArgs.dryRun {
+		return errors.New("--wakeup and --dry-run are mutually exclusive")
+	}
+
+	httpc, err := controlHttpClient(subcommand.Config().Global.Control.SockPath)
+	if err != nil {
+		return err
+	}
+
+	req := daemon.SnapshotReq{
+		Name: args[0],
+		ManualRequest: snapper.ManualRequest{
+			Filesystems: snapshotArgs.filesystems,
+			Suffix:      snapshotArgs.suffix,
+			DryRun:      snapshotArgs.dryRun,
+		},
+		Wakeup: snapshotArgs.wakeup,
+	}
+	var res daemon.SnapshotRes
+	if err := jsonRequestResponse(httpc, daemon.ControlJobEndpointSnapshot, req, &res); err != nil {
+		return err
+	}
+
+	dryRun := ""
+	if snapshotArgs.dryRun {
+		dryRun = " (dry run)"
+	}
+	failed := 0
+	for _, r := range res.Results {
+		if r.Error != "" {
+			failed++
+			fmt.Printf("FAIL %s@%s%s: %s\n", r.Filesystem, r.Snapshot, dryRun, r.Error)
+		} else {
+			fmt.Printf("OK   %s@%s%s\n", r.Filesystem, r.Snapshot, dryRun)
+		}
+		for _, h := range r.Hooks {
+			if h.Error != "" {
+				fmt.Printf("     %s %s: %s\n", h.Type, h.Hook, h.Error)
+			} else {
+				fmt.Printf("     %s %s: ok (took %s)\n", h.Type, h.Hook, h.DoneAt.Sub(h.StartAt))
+			}
+		}
+	}
+	if res.WakeupError != "" {
+		fmt.Fprintf(os.Stderr, "cannot wake up job: %s\n", res.WakeupError)
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d of %d snapshots could not be created", failed, len(res.Results))
+	}
+	if res.WakeupError != "" {
+		return errors.New("snapshots created, but job could not be woken up")
+	}
+	return nil
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/zrepl/zrepl/daemon"
 	"github.com/zrepl/zrepl/daemon/job"
 	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/pruning"
 	"github.com/zrepl/zrepl/replication"
 	"github.com/zrepl/zrepl/replication/fsrep"
 	"io"
@@ -297,8 +299,41 @@ func (t *tui) draw() {
 			t.printf("Type: %s", v.Type)
 			t.setIndent(1)
 			t.newline()
+			if v.Paused != nil {
+				if v.Paused.Until.IsZero() {
+					t.printf("Paused: until resumed")
+				} else {
+					t.printf("Paused: until %s", v.Paused.Until.Format(time.RFC3339))
+				}
+				t.newline()
+			}
+
+			if passiveStatus, ok := v.JobSpecific.(*job.PassiveStatus); ok && passiveStatus != nil && passiveStatus.Snapshotting != nil {
+				t.printf("Snapshotting:")
+				t.newline()
+				t.addIndent(1)
+				t.renderSnapperReport(passiveStatus.Snapshotting)
+				t.addIndent(-1)
+				continue
+			}
+
+			if snapStatus, ok := v.JobSpecific.(*job.SnapJobStatus); ok && snapStatus != nil {
+				if snapStatus.Snapshotting != nil {
+					t.printf("Snapshotting:")
+					t.newline()
+					t.addIndent(1)
+					t.renderSnapperReport(snapStatus.Snapshotting)
+					t.addIndent(-1)
+				}
+				t.printf("Pruning:")
+				t.newline()
+				t.addIndent(1)
+				t.renderPrunerReport(snapStatus.Pruning)
+				t.addIndent(-1)
+				continue
+			}
 
-			if v.Type != job.TypePush && v.Type != job.TypePull {
+			if v.Type != job.TypePush && v.Type != job.TypePull && v.Type != job.TypeLocal {
 				t.printf("No status representation for job type '%s', dumping as YAML", v.Type)
 				t.newline()
 				asYaml, err := yaml.Marshal(v.JobSpecific)
@@ -319,6 +354,18 @@ func (t *tui) draw() {
 				continue
 			}
 
+			if pushStatus.Snapshotting != nil {
+				t.printf("Snapshotting:")
+				t.newline()
+				t.addIndent(1)
+				t.renderSnapperReport(pushStatus.Snapshotting)
+				t.addIndent(-1)
+			}
+
+			if w := pushStatus.ReplicationWindow; w != nil {
+				t.renderReplicationWindow(w)
+			}
+
 			t.printf("Replication:")
 			t.newline()
 			t.addIndent(1)
@@ -348,11 +395,13 @@ func (t *tui) renderReplicationReport(rep *replication.Report, history *bytesPro
 		return
 	}
 
-	all := make([]*fsrep.Report, 0, len(rep.Completed)+len(rep.Pending) + 1)
+	all := make([]*fsrep.Report, 0, len(rep.Completed)+len(rep.Pending)+len(rep.Active))
 	all = append(all, rep.Completed...)
 	all = append(all, rep.Pending...)
-	if rep.Active != nil {
-		all = append(all, rep.Active)
+	all = append(all, rep.Active...)
+	active := make(map[*fsrep.Report]bool, len(rep.Active))
+	for _, fs := range rep.Active {
+		active[fs] = true
 	}
 	sort.Slice(all, func(i, j int) bool {
 		return all[i].Filesystem < all[j].Filesystem
@@ -372,7 +421,14 @@ func (t *tui) renderReplicationReport(rep *replication.Report, history *bytesPro
 		t.newline()
 	}
 	if rep.SleepUntil.After(time.Now()) && !state.IsTerminal() {
-		t.printf("Sleeping until %s (%s left)\n", rep.SleepUntil, rep.SleepUntil.Sub(time.Now()))
+		t.printf("Sleeping until %s (%s left)", rep.SleepUntil, rep.SleepUntil.Sub(time.Now()))
+		if rep.FailedAttempts > 0 {
+			t.printf(", backing off after %d failed attempts", rep.FailedAttempts)
+		}
+		t.newline()
+	}
+	if len(rep.Active) > 1 {
+		t.printf("Replicating %d filesystems in parallel\n", len(rep.Active))
 	}
 
 	if state != replication.Planning && state != replication.PlanningError {
@@ -408,10 +464,116 @@ func (t *tui) renderReplicationReport(rep *replication.Report, history *bytesPro
 		}
 	}
 	for _, fs := range all {
-		t.printFilesystemStatus(fs, fs == rep.Active, maxFSLen)
+		t.printFilesystemStatus(fs, active[fs], maxFSLen)
+	}
+}
+
+func (t *tui) renderReplicationWindow(w *job.ReplicationWindowStatus) {
+	onClose := "finish"
+	if w.CancelOnClose {
+		onClose = "cancel"
+	}
+	switch {
+	case w.Open && w.ClosesAt.IsZero():
+		t.printf("Replication Window: open")
+	case w.Open:
+		t.printf("Replication Window: open until %s (on close: %s)", w.ClosesAt.Format(time.RFC3339), onClose)
+	case w.NextOpen.IsZero():
+		t.printf("Replication Window: closed, no window opens")
+	default:
+		t.printf("Replication Window: closed, next window at %s", w.NextOpen.Format(time.RFC3339))
+	}
+	if w.Deferred {
+		t.printf(" (invocation deferred)")
+	}
+	t.newline()
+}
+
+func (t *tui) renderSnapperReport(r *snapper.Report) {
+	t.printf("Status: %s", r.State)
+	t.newline()
+
+	if r.Error != "" {
+		t.printf("Error: %s\n", r.Error)
+	}
+	if r.SleepUntil.After(time.Now()) && r.State&(snapper.SyncUp|snapper.Waiting|snapper.ErrorWait) != 0 {
+		t.printf("Next snapshots at %s (%s left)\n", r.SleepUntil, r.SleepUntil.Sub(time.Now()))
+	}
+
+	var maxFSLen int
+	for _, fs := range r.Progress {
+		if len(fs.Path) > maxFSLen {
+			maxFSLen = len(fs.Path)
+		}
+	}
+	for _, fs := range r.Progress {
+		t.printf("%s %s", rightPad(fs.Path, maxFSLen, " "), fs.State)
+		switch fs.State {
+		case snapper.SnapStarted:
+			t.printf(" %s (started %s ago)", fs.SnapName, time.Now().Sub(fs.StartAt).Round(time.Second))
+		case snapper.SnapDone:
+			t.printf(" %s (took %s)", fs.SnapName, fs.DoneAt.Sub(fs.StartAt))
+		case snapper.SnapError:
+			t.printf(" %s: %s", fs.SnapName, fs.Error)
+		}
+		t.newline()
+		t.addIndent(1)
+		for _, h := range fs.Hooks {
+			if h.Error != "" {
+				t.printf("%s %s failed: %s", h.Type, h.Hook, h.Error)
+			} else {
+				t.printf("%s %s ok (took %s)", h.Type, h.Hook, h.DoneAt.Sub(h.StartAt))
+			}
+			t.newline()
+		}
+		t.addIndent(-1)
 	}
 }
 
+// keptBySummary counts the snapshots in list kept by each keep rule,
+// ignoring the rule's detail (e.g. the grid bucket).
+func keptBySummary(list []pruner.SnapshotReport) string {
+	type rule struct {
+		index int
+		name  string
+	}
+	var rules []rule
+	counts := make(map[rule]int)
+	for _, s := range list {
+		for _, k := range s.KeptBy {
+			r := rule{k.Rule, k.RuleName}
+			if counts[r] == 0 {
+				rules = append(rules, r)
+			}
+			counts[r]++
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		// rules without an index (e.g. holds) go last
+		if (rules[i].index < 0) != (rules[j].index < 0) {
+			return rules[j].index < 0
+		}
+		return rules[i].index < rules[j].index
+	})
+	strs := make([]string, len(rules))
+	for i, r := range rules {
+		k := pruning.KeptBy{Rule: r.index, RuleName: r.name}
+		strs[i] = fmt.Sprintf("%s: %d", k, counts[r])
+	}
+	return strings.Join(strs, ", ")
+}
+
+// spaceDestroyCount counts the snapshots in list destroyed by space-aware pruning.
+func spaceDestroyCount(list []pruner.SnapshotReport) int {
+	n := 0
+	for _, s := range list {
+		if s.DestroyForSpace {
+			n++
+		}
+	}
+	return n
+}
+
 func (t *tui) renderPrunerReport(r *pruner.Report) {
 	if r == nil {
 		t.printf("...\n")
@@ -494,8 +656,14 @@ func (t *tui) renderPrunerReport(r *pruner.Report) {
 			continue
 		}
 
-		pruneRuleActionStr := fmt.Sprintf("(destroy %d of %d snapshots)",
-			len(fs.DestroyList), len(fs.SnapshotList))
+		destroyStr := fmt.Sprintf("destroy %d of %d snapshots", len(fs.DestroyList), len(fs.SnapshotList))
+		if n := spaceDestroyCount(fs.SnapshotList); n > 0 {
+			destroyStr = fmt.Sprintf("%s, %d to free space", destroyStr, n)
+		}
+		pruneRuleActionStr := fmt.Sprintf("(%s)", destroyStr)
+		if keptBy := keptBySummary(fs.SnapshotList); keptBy != "" {
+			pruneRuleActionStr = fmt.Sprintf("(%s, kept by %s)", destroyStr, keptBy)
+		}
 
 		if fs.completed {
 			t.printf( "Completed  %s\n", pruneRuleActionStr)
@@ -618,6 +786,12 @@ func (t *tui) printFilesystemStatus(rep *fsrep.Report, active bool, maxFS int) {
 	next := ""
 	if rep.Problem != "" {
 		next = rep.Problem
+		if rep.NextRetry.After(time.Now()) {
+			next += fmt.Sprintf(" (%d failed attempts, retry in %s)",
+				rep.FailedAttempts, rep.NextRetry.Sub(time.Now()).Round(time.Second))
+		}
+	} else if rep.WaitingForSlot {
+		next = "waiting for slot"
 	} else if len(rep.Pending) > 0 {
 		if rep.Pending[0].From != "" {
 			next = fmt.Sprintf("next: %s => %s", rep.Pending[0].From, rep.Pending[0].To)
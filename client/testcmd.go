@@ -25,9 +25,9 @@ var testFilterArgs struct {
 
 var testFilter = &cli.Subcommand{
 	Use: "filesystems --job JOB [--all | --input INPUT]",
-	Short: "test filesystems filter specified in push or source job",
+	Short: "test filesystems filter specified in push, source, local or snap job",
 	SetupFlags: func(f *pflag.FlagSet) {
-		f.StringVar(&testFilterArgs.job, "job", "", "the name of the push or source job")
+		f.StringVar(&testFilterArgs.job, "job", "", "the name of the push, source, local or snap job")
 		f.StringVar(&testFilterArgs.input, "input", "", "a filesystem name to test against the job's filters")
 		f.BoolVar(&testFilterArgs.all, "all", false, "test all local filesystems")
 	},
@@ -53,6 +53,8 @@ func runTestFilterCmd(subcommand *cli.Subcommand, args []string) error {
 	switch j := job.Ret.(type) {
 	case *config.SourceJob: confFilter = j.Filesystems
 	case *config.PushJob: confFilter = j.Filesystems
+	case *config.SnapJob: confFilter = j.Filesystems
+	case *config.LocalJob: confFilter = j.Filesystems
 	default:
 		return fmt.Errorf("job type %T does not have filesystems filter", j)
 	}
@@ -9,6 +9,7 @@ import (
 	"reflect"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -37,6 +38,8 @@ func (j JobEnum) Name() string {
 	case *SinkJob: name = v.Name
 	case *PullJob: name = v.Name
 	case *SourceJob: name = v.Name
+	case *SnapJob: name = v.Name
+	case *LocalJob: name = v.Name
 	default:
 		panic(fmt.Sprintf("unknownn job type %T", v))
 	}
@@ -46,8 +49,10 @@ func (j JobEnum) Name() string {
 type ActiveJob struct {
 	Type         string                `yaml:"type"`
 	Name         string                `yaml:"name"`
-	Connect     ConnectEnum     `yaml:"connect"`
 	Pruning      PruningSenderReceiver `yaml:"pruning"`
+	Replication  *ReplicationOptions   `yaml:"replication,optional,fromdefaults"`
+	// nil if the job's send streams are not limited
+	BandwidthLimit *BandwidthLimit     `yaml:"bandwidth_limit,optional"`
 	Debug        JobDebugSettings      `yaml:"debug,optional"`
 }
 
@@ -60,29 +65,129 @@ type PassiveJob struct {
 
 type PushJob struct {
 	ActiveJob `yaml:",inline"`
+	Connect      ConnectEnum               `yaml:"connect"`
 	Snapshotting SnapshottingEnum          `yaml:"snapshotting"`
 	Filesystems FilesystemsFilter `yaml:"filesystems"`
+	Send        *SendOptions      `yaml:"send,optional,fromdefaults"`
 }
 
 type PullJob struct {
 	ActiveJob `yaml:",inline"`
+	Connect   ConnectEnum   `yaml:"connect"`
 	RootFS    string        `yaml:"root_fs"`
 	Interval  time.Duration `yaml:"interval,positive"`
+	Recv      *RecvOptions  `yaml:"recv,optional,fromdefaults"`
 }
 
 type SinkJob struct {
 	PassiveJob `yaml:",inline"`
-	RootFS     string `yaml:"root_fs"`
+	RootFS     string       `yaml:"root_fs"`
+	Recv       *RecvOptions `yaml:"recv,optional,fromdefaults"`
 }
 
 type SourceJob struct {
 	PassiveJob `yaml:",inline"`
 	Snapshotting SnapshottingEnum      `yaml:"snapshotting"`
 	Filesystems FilesystemsFilter `yaml:"filesystems"`
+	Send        *SendOptions      `yaml:"send,optional,fromdefaults"`
+	BandwidthLimit *BandwidthLimit `yaml:"bandwidth_limit,optional"`
+}
+
+// LocalJob replicates between pools of the same host, without a transport.
+type LocalJob struct {
+	ActiveJob    `yaml:",inline"`
+	Snapshotting SnapshottingEnum  `yaml:"snapshotting"`
+	Filesystems  FilesystemsFilter `yaml:"filesystems"`
+	RootFS       string            `yaml:"root_fs"`
+	Send         *SendOptions      `yaml:"send,optional,fromdefaults"`
+	Recv         *RecvOptions      `yaml:"recv,optional,fromdefaults"`
+}
+
+type SnapJob struct {
+	Type         string            `yaml:"type"`
+	Name         string            `yaml:"name"`
+	Pruning      PruningLocal      `yaml:"pruning"`
+	Snapshotting SnapshottingEnum  `yaml:"snapshotting"`
+	Filesystems  FilesystemsFilter `yaml:"filesystems"`
+	Debug        JobDebugSettings  `yaml:"debug,optional"`
 }
 
 type FilesystemsFilter map[string]bool
 
+type SendOptions struct {
+	Compressed     bool `yaml:"compressed,optional,default=false"`
+	LargeBlocks    bool `yaml:"large_blocks,optional,default=false"`
+	EmbeddedData   bool `yaml:"embedded_data,optional,default=false"`
+	Raw            bool `yaml:"raw,optional,default=false"`
+	SendProperties bool `yaml:"send_properties,optional,default=false"`
+	// hold the most recently replicated snapshot of each filesystem
+	HoldReplicated bool `yaml:"hold_replicated,optional,default=true"`
+	// bookmark every replicated snapshot, see PruningSenderReceiver.KeepSenderBookmarks
+	BookmarkReplicated bool `yaml:"bookmark_replicated,optional,default=false"`
+}
+
+type RecvOptions struct {
+	Properties *PropertyRecvOptions `yaml:"properties,optional,fromdefaults"`
+}
+
+type PropertyRecvOptions struct {
+	Override map[string]string `yaml:"override,optional"`
+	Exclude  []string          `yaml:"exclude,optional"`
+}
+
+type ReplicationOptions struct {
+	// number of filesystems that are replicated in parallel
+	Concurrency        int                        `yaml:"concurrency,optional,default=1"`
+	ConflictResolution *ConflictResolutionOptions `yaml:"conflict_resolution,optional,fromdefaults"`
+	// nil if replication is allowed at any time
+	Windows *ReplicationWindows `yaml:"windows,optional"`
+	Retry   *RetryOptions       `yaml:"retry,optional,fromdefaults"`
+}
+
+// backoff after temporary errors and per-filesystem retry budget
+type RetryOptions struct {
+	InitialInterval time.Duration `yaml:"initial_interval,optional,positive,default=10s"`
+	MaxInterval     time.Duration `yaml:"max_interval,optional,positive,default=10m"`
+	// per filesystem, 0 means unlimited
+	MaxAttempts int           `yaml:"max_attempts,optional,default=0"`
+	MaxDuration time.Duration `yaml:"max_duration,optional"`
+}
+
+type ReplicationWindows struct {
+	TimeZone string               `yaml:"timezone,optional,default=Local"`
+	OnClose  string               `yaml:"on_close,optional,default=finish"`
+	Allow    []*ReplicationWindow `yaml:"allow"`
+}
+
+type ReplicationWindow struct {
+	Days  string `yaml:"days,optional"` // cron-style day-of-week field, empty means every day
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+}
+
+// BandwidthLimit limits the throughput of send streams.
+// Max and the Max of profiles are rates such as 10MiB/s, 0 or unlimited means no limit.
+type BandwidthLimit struct {
+	Max      string              `yaml:"max"`
+	TimeZone string              `yaml:"timezone,optional,default=Local"`
+	// the first profile whose time window contains the current time overrides Max
+	Profiles []*BandwidthProfile `yaml:"profiles,optional"`
+}
+
+type BandwidthProfile struct {
+	Days  string `yaml:"days,optional"` // cron-style day-of-week field, empty means every day
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+	Max   string `yaml:"max"`
+}
+
+type ConflictResolutionOptions struct {
+	InitialReplication string `yaml:"initial_replication,optional,default=most_recent"`
+	Diverged           string `yaml:"diverged,optional,default=fail"`
+	RollbackMaxDestroy int    `yaml:"rollback_max_destroy,optional,default=1"`
+	RollbackDryRun     bool   `yaml:"rollback_dry_run,optional,default=false"`
+}
+
 type SnapshottingEnum struct {
 	Ret interface{}
 }
@@ -91,19 +196,63 @@ type SnapshottingPeriodic struct {
 	Type string		`yaml:"type"`
 	Prefix string	`yaml:"prefix"`
 	Interval time.Duration `yaml:"interval,positive"`
+	Hooks    HookList      `yaml:"hooks,optional"`
+}
+
+type SnapshottingCron struct {
+	Type     string   `yaml:"type"`
+	Prefix   string   `yaml:"prefix"`
+	Cron     []string `yaml:"cron"`
+	TimeZone string   `yaml:"timezone,optional,default=Local"`
+	Hooks    HookList `yaml:"hooks,optional"`
 }
 
 type SnapshottingManual struct {
-	Type string `yaml:"type"`
+	Type   string   `yaml:"type"`
+	Prefix string   `yaml:"prefix,optional,default=zrepl_"` // for zrepl snapshot JOB
+	Hooks  HookList `yaml:"hooks,optional"`                  // for zrepl snapshot JOB
+}
+
+type HookList []HookEnum
+
+type HookEnum struct {
+	Ret interface{}
+}
+
+type HookCommand struct {
+	Type        string            `yaml:"type"`
+	Path        string            `yaml:"path"`
+	Timeout     time.Duration     `yaml:"timeout,optional,positive,default=30s"`
+	Filesystems FilesystemsFilter `yaml:"filesystems,optional"` // all filesystems of the job if empty
+	ErrIsFatal  bool              `yaml:"err_is_fatal,optional"`
 }
 
 type PruningSenderReceiver struct {
 	KeepSender   []PruningEnum `yaml:"keep_sender"`
 	KeepReceiver []PruningEnum `yaml:"keep_receiver"`
+	// bookmarks created by SendOptions.BookmarkReplicated, kept if empty
+	KeepSenderBookmarks []PruningEnum `yaml:"keep_sender_bookmarks,optional"`
+	// space-aware pruning, disabled if nil
+	SpaceSender   *PruneSpace `yaml:"space_sender,optional"`
+	SpaceReceiver *PruneSpace `yaml:"space_receiver,optional"`
 }
 
 type PruningLocal struct {
 	Keep []PruningEnum `yaml:"keep"`
+	// space-aware pruning, disabled if nil
+	Space *PruneSpace `yaml:"space,optional"`
+}
+
+// PruneSpace destroys the oldest snapshots kept by the keep rules
+// while the used percentage or the available space exceed the thresholds.
+// Unset (zero) thresholds are not checked.
+type PruneSpace struct {
+	// "pool" checks the thresholds on the pool of each filesystem, "dataset" on the filesystem itself
+	Of                string   `yaml:"of,optional,default=pool"`
+	MaxUsedPercent    int      `yaml:"max_used_percent,optional"`
+	TargetUsedPercent int      `yaml:"target_used_percent,optional"`
+	MinAvailable      ByteSize `yaml:"min_available,optional"`
+	TargetAvailable   ByteSize `yaml:"target_available,optional"`
 }
 
 type LoggingOutletEnumList []LoggingOutletEnum
@@ -131,6 +280,9 @@ type Global struct {
 	Control    *GlobalControl         `yaml:"control,optional,fromdefaults"`
 	Serve      *GlobalServe           `yaml:"serve,optional,fromdefaults"`
 	RPC        *RPCConfig             `yaml:"rpc,optional,fromdefaults"`
+	Transfers  *GlobalTransfers       `yaml:"transfers,optional,fromdefaults"`
+	// limit shared by the send streams of all jobs, nil if unlimited
+	BandwidthLimit *BandwidthLimit `yaml:"bandwidth_limit,optional"`
 }
 
 func Default(i interface{}) {
@@ -240,11 +392,15 @@ type PruningEnum struct {
 type PruneKeepNotReplicated struct {
 	Type string `yaml:"type"`
 	KeepSnapshotAtCursor bool `yaml:"keep_snapshot_at_cursor,optional,default=true"`
+	// the rule only applies to snapshots whose name matches, all snapshots if empty
+	Regex string `yaml:"regex,optional"`
 }
 
 type PruneKeepLastN struct {
 	Type  string `yaml:"type"`
 	Count int    `yaml:"count"`
+	// the rule only applies to snapshots whose name matches, all snapshots if empty
+	Regex string `yaml:"regex,optional"`
 }
 
 type PruneKeepRegex struct { // FIXME rename to KeepRegex
@@ -253,6 +409,20 @@ type PruneKeepRegex struct { // FIXME rename to KeepRegex
 	Negate bool   `yaml:"negate,optional,default=false"`
 }
 
+type PruneKeepWithin struct {
+	Type     string           `yaml:"type"`
+	Duration PositiveDuration `yaml:"duration"`
+	// the rule only applies to snapshots whose name matches, all snapshots if empty
+	Regex string `yaml:"regex,optional"`
+}
+
+type PruneKeepOlderThan struct {
+	Type     string           `yaml:"type"`
+	Duration PositiveDuration `yaml:"duration"`
+	// the rule only applies to snapshots whose name matches, all snapshots if empty
+	Regex string `yaml:"regex,optional"`
+}
+
 type LoggingOutletEnum struct {
 	Ret interface{}
 }
@@ -299,6 +469,15 @@ type PrometheusMonitoring struct {
 
 type GlobalControl struct {
 	SockPath string `yaml:"sockpath,default=/var/run/zrepl/control"`
+	// state of control operations that must survive daemon restarts, e.g. paused jobs
+	StateDir string `yaml:"statedir,default=/var/lib/zrepl"`
+}
+
+// limits of concurrent zfs send/recv streams across all jobs, 0 means unlimited
+type GlobalTransfers struct {
+	MaxConcurrent int `yaml:"max_concurrent,optional,default=0"`
+	PerPool       int `yaml:"per_pool,optional,default=0"`
+	PerRemote     int `yaml:"per_remote,optional,default=0"`
 }
 
 type GlobalServe struct {
@@ -344,6 +523,8 @@ func (t *JobEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
 		"sink":   &SinkJob{},
 		"pull":   &PullJob{},
 		"source": &SourceJob{},
+		"snap":   &SnapJob{},
+		"local":  &LocalJob{},
 	})
 	return
 }
@@ -374,14 +555,40 @@ func (t *PruningEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error)
 		"last_n":         &PruneKeepLastN{},
 		"grid":           &PruneGrid{},
 		"regex":          &PruneKeepRegex{},
+		"within":         &PruneKeepWithin{},
+		"older_than":     &PruneKeepOlderThan{},
 	})
-	return
+	if err != nil {
+		return err
+	}
+	switch v := t.Ret.(type) {
+	case *PruneKeepNotReplicated:
+		_, err = regexp.Compile(v.Regex)
+	case *PruneKeepLastN:
+		_, err = regexp.Compile(v.Regex)
+	case *PruneKeepWithin:
+		_, err = regexp.Compile(v.Regex)
+	case *PruneKeepOlderThan:
+		_, err = regexp.Compile(v.Regex)
+	}
+	if err != nil {
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("invalid regex: %s", err)}}
+	}
+	return nil
 }
 
 func (t *SnapshottingEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
 	t.Ret, err = enumUnmarshal(u, map[string]interface{}{
 		"periodic": &SnapshottingPeriodic{},
 		"manual": &SnapshottingManual{},
+		"cron": &SnapshottingCron{},
+	})
+	return
+}
+
+func (t *HookEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
+	t.Ret, err = enumUnmarshal(u, map[string]interface{}{
+		"command": &HookCommand{},
 	})
 	return
 }
@@ -445,6 +652,61 @@ func ParseConfigBytes(bytes []byte) (*Config, error) {
 	return c, nil
 }
 
+// PositiveDuration is a time.Duration in the format of the retention grid, e.g. 48h or 14d.
+type PositiveDuration time.Duration
+
+func (d *PositiveDuration) UnmarshalYAML(u func(interface{}, bool) error) error {
+	var in string
+	if err := u(&in, true); err != nil {
+		return err
+	}
+	parsed, err := parsePostitiveDuration(in)
+	if err != nil {
+		return err
+	}
+	*d = PositiveDuration(parsed)
+	return nil
+}
+
+// ByteSize is a number of bytes such as 100GiB, 1.5TB or 4096.
+type ByteSize uint64
+
+var byteSizeUnits = []struct {
+	suffix string
+	factor float64
+}{
+	// longest suffixes first
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"TiB", 1 << 40},
+	{"KB", 1e3},
+	{"MB", 1e6},
+	{"GB", 1e9},
+	{"TB", 1e12},
+	{"B", 1},
+}
+
+func (b *ByteSize) UnmarshalYAML(u func(interface{}, bool) error) error {
+	var in string
+	if err := u(&in, true); err != nil {
+		return err
+	}
+	num, factor := strings.TrimSpace(in), 1.0
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(num, unit.suffix) {
+			num, factor = strings.TrimSpace(strings.TrimSuffix(num, unit.suffix)), unit.factor
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return fmt.Errorf("invalid size %q, must be a non-negative number of bytes, e.g. 100GiB", in)
+	}
+	*b = ByteSize(f * factor)
+	return nil
+}
+
 var durationStringRegex *regexp.Regexp = regexp.MustCompile(`^\s*(\d+)\s*(s|m|h|d|w)\s*$`)
 
 func parsePostitiveDuration(e string) (d time.Duration, err error) {
@@ -72,6 +72,53 @@ global:
 	assert.Equal(t, ":9091", conf.Global.Monitoring[0].Ret.(*PrometheusMonitoring).Listen)	
 }
 
+func TestControlStateDir(t *testing.T) {
+	conf := testValidGlobalSection(t, "")
+	assert.Equal(t, "/var/lib/zrepl", conf.Global.Control.StateDir)
+
+	conf = testValidGlobalSection(t, `
+global:
+  control:
+    statedir: /tmp/zrepl
+`)
+	assert.Equal(t, "/tmp/zrepl", conf.Global.Control.StateDir)
+	assert.Equal(t, "/var/run/zrepl/control", conf.Global.Control.SockPath)
+}
+
+func TestTransfers(t *testing.T) {
+	conf := testValidGlobalSection(t, "")
+	assert.Equal(t, GlobalTransfers{}, *conf.Global.Transfers)
+
+	conf = testValidGlobalSection(t, `
+global:
+  transfers:
+    max_concurrent: 4
+    per_pool: 2
+    per_remote: 1
+`)
+	assert.Equal(t, GlobalTransfers{MaxConcurrent: 4, PerPool: 2, PerRemote: 1}, *conf.Global.Transfers)
+}
+
+func TestGlobalBandwidthLimit(t *testing.T) {
+	conf := testValidGlobalSection(t, "")
+	assert.Nil(t, conf.Global.BandwidthLimit)
+
+	conf = testValidGlobalSection(t, `
+global:
+  bandwidth_limit:
+    max: 100MiB/s
+    profiles:
+    - days: mon-fri
+      start: "08:00"
+      end: "18:00"
+      max: 10MiB/s
+`)
+	bw := conf.Global.BandwidthLimit
+	assert.Equal(t, "100MiB/s", bw.Max)
+	assert.Equal(t, "Local", bw.TimeZone)
+	assert.Equal(t, []*BandwidthProfile{{Days: "mon-fri", Start: "08:00", End: "18:00", Max: "10MiB/s"}}, bw.Profiles)
+}
+
 func TestLoggingOutletEnumList_SetDefaults(t *testing.T) {
 	e := &LoggingOutletEnumList{}
 	var i yaml.Defaulter = e
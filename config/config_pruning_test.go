@@ -0,0 +1,162 @@
+package config
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const pruneKeepTmpl = `
+jobs:
+- name: foo
+  type: snap
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+    %s
+`
+
+func TestPruneKeepTimeWindow(t *testing.T) {
+	fill := func(s string) string { return fmt.Sprintf(pruneKeepTmpl, s) }
+
+	t.Run("within", func(t *testing.T) {
+		c := testValidConfig(t, fill(`
+    - type: within
+      duration: 48h
+`))
+		keep := c.Jobs[0].Ret.(*SnapJob).Pruning.Keep
+		assert.Equal(t, &PruneKeepWithin{
+			Type:     "within",
+			Duration: PositiveDuration(48 * time.Hour),
+		}, keep[0].Ret)
+	})
+
+	t.Run("older_than with regex", func(t *testing.T) {
+		c := testValidConfig(t, fill(`
+    - type: older_than
+      duration: 2w
+      regex: ^manual_
+`))
+		keep := c.Jobs[0].Ret.(*SnapJob).Pruning.Keep
+		assert.Equal(t, &PruneKeepOlderThan{
+			Type:     "older_than",
+			Duration: PositiveDuration(14 * 24 * time.Hour),
+			Regex:    "^manual_",
+		}, keep[0].Ret)
+	})
+
+	t.Run("missing duration", func(t *testing.T) {
+		_, err := testConfig(t, fill(`
+    - type: within
+`))
+		assert.Error(t, err)
+	})
+
+	t.Run("non-positive duration", func(t *testing.T) {
+		_, err := testConfig(t, fill(`
+    - type: within
+      duration: 0h
+`))
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid regex", func(t *testing.T) {
+		_, err := testConfig(t, fill(`
+    - type: older_than
+      duration: 1d
+      regex: "("
+`))
+		assert.Error(t, err)
+	})
+
+}
+
+func TestPruneKeepScope(t *testing.T) {
+	fill := func(s string) string { return fmt.Sprintf(pruneKeepTmpl, s) }
+
+	t.Run("last_n", func(t *testing.T) {
+		c := testValidConfig(t, fill(`
+    - type: last_n
+      count: 10
+      regex: ^zrepl_
+    - type: last_n
+      count: 3
+`))
+		keep := c.Jobs[0].Ret.(*SnapJob).Pruning.Keep
+		assert.Equal(t, &PruneKeepLastN{Type: "last_n", Count: 10, Regex: "^zrepl_"}, keep[0].Ret)
+		assert.Equal(t, &PruneKeepLastN{Type: "last_n", Count: 3}, keep[1].Ret)
+	})
+
+	t.Run("invalid regex", func(t *testing.T) {
+		_, err := testConfig(t, fill(`
+    - type: last_n
+      count: 10
+      regex: "["
+`))
+		assert.Error(t, err)
+	})
+
+}
+
+func TestPruneSpace(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: snap
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+    - type: last_n
+      count: 10
+    %s
+`
+	fill := func(s string) string { return fmt.Sprintf(tmpl, s) }
+
+	t.Run("absent", func(t *testing.T) {
+		c := testValidConfig(t, fill(""))
+		assert.Nil(t, c.Jobs[0].Ret.(*SnapJob).Pruning.Space)
+	})
+
+	t.Run("sizes", func(t *testing.T) {
+		c := testValidConfig(t, fill(`
+    space:
+      min_available: 1.5GiB
+      target_available: 4096
+`))
+		assert.Equal(t, &PruneSpace{
+			Of:              "pool",
+			MinAvailable:    ByteSize(3 << 29),
+			TargetAvailable: ByteSize(4096),
+		}, c.Jobs[0].Ret.(*SnapJob).Pruning.Space)
+	})
+
+	t.Run("percent", func(t *testing.T) {
+		c := testValidConfig(t, fill(`
+    space:
+      of: dataset
+      max_used_percent: 90
+      target_used_percent: 80
+      min_available: 100 GB
+`))
+		assert.Equal(t, &PruneSpace{
+			Of:                "dataset",
+			MaxUsedPercent:    90,
+			TargetUsedPercent: 80,
+			MinAvailable:      ByteSize(100e9),
+		}, c.Jobs[0].Ret.(*SnapJob).Pruning.Space)
+	})
+
+	t.Run("invalid size", func(t *testing.T) {
+		_, err := testConfig(t, fill(`
+    space:
+      min_available: 10 apples
+`))
+		assert.Error(t, err)
+	})
+
+}
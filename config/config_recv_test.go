@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRecvOptions(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: sink
+  serve:
+    type: local
+    listener_name: foo
+  root_fs: "pool/backup"
+  %s
+`
+	fill := func(s string) string { return fmt.Sprintf(tmpl, s) }
+
+	t.Run("defaults", func(t *testing.T) {
+		c := testValidConfig(t, fill(""))
+		recv := c.Jobs[0].Ret.(*SinkJob).Recv
+		assert.NotNil(t, recv)
+		assert.NotNil(t, recv.Properties)
+		assert.Empty(t, recv.Properties.Override)
+		assert.Empty(t, recv.Properties.Exclude)
+	})
+
+	t.Run("override and exclude", func(t *testing.T) {
+		c := testValidConfig(t, fill(`
+  recv:
+    properties:
+      override: {
+        "readonly": "on",
+        "canmount": "off",
+        "mountpoint": "none",
+      }
+      exclude: ["keylocation"]
+`))
+		props := c.Jobs[0].Ret.(*SinkJob).Recv.Properties
+		assert.Equal(t, map[string]string{
+			"readonly":   "on",
+			"canmount":   "off",
+			"mountpoint": "none",
+		}, props.Override)
+		assert.Equal(t, []string{"keylocation"}, props.Exclude)
+	})
+
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestReplicationOptions(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: pull
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  root_fs: "pool/backup"
+  interval: 10m
+  pruning:
+    keep_sender:
+    - type: last_n
+      count: 10
+    keep_receiver:
+    - type: last_n
+      count: 10
+  %s
+`
+	fill := func(s string) string { return fmt.Sprintf(tmpl, s) }
+
+	t.Run("defaults", func(t *testing.T) {
+		c := testValidConfig(t, fill(""))
+		rep := c.Jobs[0].Ret.(*PullJob).Replication
+		assert.NotNil(t, rep)
+		assert.Equal(t, 1, rep.Concurrency)
+		assert.Nil(t, rep.Windows)
+		assert.Equal(t, RetryOptions{
+			InitialInterval: 10 * time.Second,
+			MaxInterval:     10 * time.Minute,
+		}, *rep.Retry)
+		assert.Equal(t, ConflictResolutionOptions{
+			InitialReplication: "most_recent",
+			Diverged:           "fail",
+			RollbackMaxDestroy: 1,
+		}, *rep.ConflictResolution)
+	})
+
+	t.Run("concurrency", func(t *testing.T) {
+		c := testValidConfig(t, fill(`
+  replication:
+    concurrency: 4
+`))
+		rep := c.Jobs[0].Ret.(*PullJob).Replication
+		assert.Equal(t, 4, rep.Concurrency)
+	})
+
+	t.Run("conflict resolution", func(t *testing.T) {
+		c := testValidConfig(t, fill(`
+  replication:
+    conflict_resolution:
+      initial_replication: all
+      diverged: rollback
+      rollback_max_destroy: 5
+      rollback_dry_run: true
+`))
+		rep := c.Jobs[0].Ret.(*PullJob).Replication
+		assert.Equal(t, ConflictResolutionOptions{
+			InitialReplication: "all",
+			Diverged:           "rollback",
+			RollbackMaxDestroy: 5,
+			RollbackDryRun:     true,
+		}, *rep.ConflictResolution)
+	})
+
+	t.Run("windows", func(t *testing.T) {
+		c := testValidConfig(t, fill(`
+  replication:
+    windows:
+      allow:
+      - days: mon-fri
+        start: "22:00"
+        end: "06:00"
+      - days: sat,sun
+        start: "00:00"
+        end: "24:00"
+`))
+		ws := c.Jobs[0].Ret.(*PullJob).Replication.Windows
+		assert.Equal(t, "Local", ws.TimeZone)
+		assert.Equal(t, "finish", ws.OnClose)
+		assert.Equal(t, 2, len(ws.Allow))
+		assert.Equal(t, ReplicationWindow{Days: "mon-fri", Start: "22:00", End: "06:00"}, *ws.Allow[0])
+
+		c = testValidConfig(t, fill(`
+  replication:
+    windows:
+      timezone: Europe/Berlin
+      on_close: cancel
+      allow:
+      - start: "22:00"
+        end: "06:00"
+`))
+		ws = c.Jobs[0].Ret.(*PullJob).Replication.Windows
+		assert.Equal(t, "Europe/Berlin", ws.TimeZone)
+		assert.Equal(t, "cancel", ws.OnClose)
+		assert.Equal(t, "", ws.Allow[0].Days)
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		c := testValidConfig(t, fill(`
+  replication:
+    retry:
+      initial_interval: 30s
+      max_interval: 1h
+      max_attempts: 5
+      max_duration: 6h
+`))
+		rep := c.Jobs[0].Ret.(*PullJob).Replication
+		assert.Equal(t, RetryOptions{
+			InitialInterval: 30 * time.Second,
+			MaxInterval:     time.Hour,
+			MaxAttempts:     5,
+			MaxDuration:     6 * time.Hour,
+		}, *rep.Retry)
+	})
+
+	t.Run("bandwidth limit", func(t *testing.T) {
+		c := testValidConfig(t, fill(""))
+		assert.Nil(t, c.Jobs[0].Ret.(*PullJob).BandwidthLimit)
+
+		c = testValidConfig(t, fill(`
+  bandwidth_limit:
+    max: 5MB/s
+    timezone: UTC
+`))
+		bw := c.Jobs[0].Ret.(*PullJob).BandwidthLimit
+		assert.Equal(t, "5MB/s", bw.Max)
+		assert.Equal(t, "UTC", bw.TimeZone)
+		assert.Empty(t, bw.Profiles)
+	})
+
+	t.Run("unknown option", func(t *testing.T) {
+		_, err := testConfig(t, fill(`
+  replication:
+    parallel: true
+`))
+		assert.Error(t, err)
+	})
+
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSendOptions(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: source
+  serve:
+    type: local
+    listener_name: foo
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  %s
+`
+	fill := func(s string) string { return fmt.Sprintf(tmpl, s) }
+
+	t.Run("defaults", func(t *testing.T) {
+		c := testValidConfig(t, fill(""))
+		send := c.Jobs[0].Ret.(*SourceJob).Send
+		assert.NotNil(t, send)
+		assert.Equal(t, SendOptions{HoldReplicated: true}, *send)
+	})
+
+	t.Run("all", func(t *testing.T) {
+		c := testValidConfig(t, fill(`
+  send:
+    compressed: true
+    large_blocks: true
+    embedded_data: true
+    raw: true
+    send_properties: true
+    bookmark_replicated: true
+`))
+		send := c.Jobs[0].Ret.(*SourceJob).Send
+		assert.Equal(t, SendOptions{
+			Compressed:         true,
+			LargeBlocks:        true,
+			EmbeddedData:       true,
+			Raw:                true,
+			SendProperties:     true,
+			HoldReplicated:     true,
+			BookmarkReplicated: true,
+		}, *send)
+	})
+
+	t.Run("no holds", func(t *testing.T) {
+		c := testValidConfig(t, fill(`
+  send:
+    hold_replicated: false
+`))
+		send := c.Jobs[0].Ret.(*SourceJob).Send
+		assert.False(t, send.HoldReplicated)
+	})
+
+	t.Run("unknown option", func(t *testing.T) {
+		_, err := testConfig(t, fill(`
+  send:
+    dedup: true
+`))
+		assert.Error(t, err)
+	})
+
+}
@@ -37,6 +37,31 @@ jobs:
     interval: 10m
 `
 
+	cron := `
+  snapshotting:
+    type: cron
+    prefix: zrepl_
+    cron: ["0 2 * * *", "*/15 9-17 * * mon-fri"]
+    timezone: Europe/Berlin
+`
+
+	hooks := `
+  snapshotting:
+    type: periodic
+    prefix: zrepl_
+    interval: 10m
+    hooks:
+    - type: command
+      path: /etc/zrepl/hooks/pgsql.sh
+      timeout: 1m
+      err_is_fatal: true
+      filesystems: {
+        "tank/db<": true,
+      }
+    - type: command
+      path: /etc/zrepl/hooks/notify.sh
+`
+
 	fillSnapshotting := func(s string) string {return fmt.Sprintf(tmpl, s)}
 	var c *Config
 
@@ -44,6 +69,7 @@ jobs:
 		c = testValidConfig(t, fillSnapshotting(manual))
 		snm := c.Jobs[0].Ret.(*PushJob).Snapshotting.Ret.(*SnapshottingManual)
 		assert.Equal(t, "manual", snm.Type)
+		assert.Equal(t, "zrepl_", snm.Prefix)
 	})
 
 	t.Run("periodic", func(t *testing.T) {
@@ -54,4 +80,28 @@ jobs:
 		assert.Equal(t, "zrepl_" , snp.Prefix)
 	})
 
+	t.Run("cron", func(t *testing.T) {
+		c = testValidConfig(t, fillSnapshotting(cron))
+		snc := c.Jobs[0].Ret.(*PushJob).Snapshotting.Ret.(*SnapshottingCron)
+		assert.Equal(t, "cron", snc.Type)
+		assert.Equal(t, "zrepl_", snc.Prefix)
+		assert.Equal(t, []string{"0 2 * * *", "*/15 9-17 * * mon-fri"}, snc.Cron)
+		assert.Equal(t, "Europe/Berlin", snc.TimeZone)
+	})
+
+	t.Run("hooks", func(t *testing.T) {
+		c = testValidConfig(t, fillSnapshotting(hooks))
+		snp := c.Jobs[0].Ret.(*PushJob).Snapshotting.Ret.(*SnapshottingPeriodic)
+		assert.Len(t, snp.Hooks, 2)
+		h0 := snp.Hooks[0].Ret.(*HookCommand)
+		assert.Equal(t, "/etc/zrepl/hooks/pgsql.sh", h0.Path)
+		assert.Equal(t, time.Minute, h0.Timeout)
+		assert.True(t, h0.ErrIsFatal)
+		assert.Equal(t, FilesystemsFilter{"tank/db<": true}, h0.Filesystems)
+		h1 := snp.Hooks[1].Ret.(*HookCommand)
+		assert.Equal(t, 30*time.Second, h1.Timeout)
+		assert.False(t, h1.ErrIsFatal)
+		assert.Empty(t, h1.Filesystems)
+	})
+
 }
@@ -0,0 +1,108 @@
+// Package bandwidth builds the rate limiters for send streams from the config.
+package bandwidth
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/util/ratelimit"
+	"github.com/zrepl/zrepl/util/window"
+)
+
+var rateUnits = []struct {
+	suffix string
+	factor int64
+}{
+	// longest suffixes first
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"KB", 1000},
+	{"MB", 1000 * 1000},
+	{"GB", 1000 * 1000 * 1000},
+	{"B", 1},
+}
+
+// ParseRate parses a rate in bytes per second such as 500KiB/s or 10MB.
+// The /s suffix is optional, 0 and unlimited mean no limit and are returned as 0.
+func ParseRate(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "unlimited" {
+		return 0, nil
+	}
+	num := strings.TrimSuffix(s, "/s")
+	factor := int64(1)
+	for _, u := range rateUnits {
+		if strings.HasSuffix(num, u.suffix) {
+			num, factor = strings.TrimSpace(strings.TrimSuffix(num, u.suffix)), u.factor
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return 0, errors.Errorf("invalid rate %q, must be a non-negative number of bytes per second, e.g. 10MiB/s", s)
+	}
+	rate := int64(f * float64(factor))
+	if f > 0 && rate == 0 {
+		return 0, errors.Errorf("invalid rate %q, must be at least 1B/s", s)
+	}
+	return rate, nil
+}
+
+type profile struct {
+	windows *window.Windows
+	rate    int64
+}
+
+// FromConfig returns a bucket with the rate configured in in, nil if in is nil.
+func FromConfig(in *config.BandwidthLimit) (*ratelimit.Bucket, error) {
+	if in == nil {
+		return nil, nil
+	}
+	max, err := ParseRate(in.Max)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid max")
+	}
+	loc, err := time.LoadLocation(in.TimeZone)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid timezone")
+	}
+	profiles := make([]profile, len(in.Profiles))
+	for i, p := range in.Profiles {
+		w, err := window.Parse(p.Days, p.Start, p.End)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid profile #%d", i)
+		}
+		profiles[i].windows = window.New([]*window.Window{w}, loc)
+		if profiles[i].rate, err = ParseRate(p.Max); err != nil {
+			return nil, errors.Wrapf(err, "invalid max of profile #%d", i)
+		}
+	}
+	return ratelimit.NewBucket(func(now time.Time) int64 {
+		for _, p := range profiles {
+			if p.windows.Contains(now) {
+				return p.rate
+			}
+		}
+		return max
+	}), nil
+}
+
+type contextKey int
+
+const contextKeyGlobal contextKey = iota
+
+// WithGlobal returns a context carrying b, the bucket shared by the send streams of all jobs.
+func WithGlobal(ctx context.Context, b *ratelimit.Bucket) context.Context {
+	return context.WithValue(ctx, contextKeyGlobal, b)
+}
+
+// Global returns the bucket in ctx, nil if there is none.
+func Global(ctx context.Context) *ratelimit.Bucket {
+	b, _ := ctx.Value(contextKeyGlobal).(*ratelimit.Bucket)
+	return b
+}
@@ -0,0 +1,66 @@
+package bandwidth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zrepl/zrepl/config"
+)
+
+func TestParseRate(t *testing.T) {
+	valid := map[string]int64{
+		"0":         0,
+		"unlimited": 0,
+		"1024":      1024,
+		"100B/s":    100,
+		"10KB/s":    10 * 1000,
+		"10KiB/s":   10 * 1024,
+		"1.5MiB/s":  3 << 19,
+		"2 MB":      2 * 1000 * 1000,
+		"1GiB/s":    1 << 30,
+		" 5MiB/s ":  5 << 20,
+	}
+	for in, expected := range valid {
+		rate, err := ParseRate(in)
+		if assert.NoError(t, err, "%q", in) {
+			assert.Equal(t, expected, rate, "%q", in)
+		}
+	}
+	for _, in := range []string{"", "-1MB/s", "fast", "10 parsecs", "10MB/h", "0.1B/s"} {
+		_, err := ParseRate(in)
+		assert.Error(t, err, "%q", in)
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	b, err := FromConfig(nil)
+	require.NoError(t, err)
+	assert.Nil(t, b)
+
+	b, err = FromConfig(&config.BandwidthLimit{
+		Max:      "100MiB/s",
+		TimeZone: "UTC",
+		Profiles: []*config.BandwidthProfile{
+			{Days: "mon-fri", Start: "08:00", End: "18:00", Max: "10MiB/s"},
+			{Start: "08:00", End: "18:00", Max: "50MiB/s"},
+		},
+	})
+	require.NoError(t, err)
+	// 2018-10-01 is a Monday
+	assert.Equal(t, int64(10<<20), b.Rate(time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)))
+	assert.Equal(t, int64(50<<20), b.Rate(time.Date(2018, 10, 6, 12, 0, 0, 0, time.UTC)))
+	assert.Equal(t, int64(100<<20), b.Rate(time.Date(2018, 10, 1, 20, 0, 0, 0, time.UTC)))
+
+	invalid := []*config.BandwidthLimit{
+		{Max: "fast", TimeZone: "UTC"},
+		{Max: "1MB/s", TimeZone: "Nowhere/Special"},
+		{Max: "1MB/s", TimeZone: "UTC", Profiles: []*config.BandwidthProfile{{Start: "25:00", End: "06:00", Max: "1MB/s"}}},
+		{Max: "1MB/s", TimeZone: "UTC", Profiles: []*config.BandwidthProfile{{Start: "22:00", End: "06:00", Max: "slow"}}},
+	}
+	for _, in := range invalid {
+		_, err := FromConfig(in)
+		assert.Error(t, err, "%#v", in)
+	}
+}
@@ -9,7 +9,10 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/zrepl/zrepl/daemon/job"
 	"github.com/zrepl/zrepl/daemon/nethelpers"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/daemon/snapper"
 	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/util/envconst"
 	"github.com/zrepl/zrepl/version"
 	"io"
 	"net"
@@ -20,10 +23,11 @@ import (
 type controlJob struct {
 	sockaddr *net.UnixAddr
 	jobs     *jobs
+	reloader *reloader
 }
 
-func newControlJob(sockpath string, jobs *jobs) (j *controlJob, err error) {
-	j = &controlJob{jobs: jobs}
+func newControlJob(sockpath string, jobs *jobs, reloader *reloader) (j *controlJob, err error) {
+	j = &controlJob{jobs: jobs, reloader: reloader}
 
 	j.sockaddr, err = net.ResolveUnixAddr("unix", sockpath)
 	if err != nil {
@@ -67,8 +71,46 @@ const (
 	ControlJobEndpointVersion string = "/version"
 	ControlJobEndpointStatus  string = "/status"
 	ControlJobEndpointSignal  string = "/signal"
+	ControlJobEndpointSnapshot string = "/snapshot"
+	ControlJobEndpointReload   string = "/reload"
+	ControlJobEndpointPrune    string = "/prune"
 )
 
+// SignalReq is the request body of ControlJobEndpointSignal.
+type SignalReq struct {
+	Name string // job name
+	Op   string // wakeup, reset, pause or resume
+	// pause: the pause expires at Until, zero pauses the job until it is resumed
+	Until time.Time
+}
+
+// SnapshotReq is the request body of ControlJobEndpointSnapshot.
+type SnapshotReq struct {
+	Name string // job name
+	snapper.ManualRequest
+	// wake up the job after the snapshots have been taken
+	Wakeup bool
+}
+
+// SnapshotRes is the response body of ControlJobEndpointSnapshot.
+type SnapshotRes struct {
+	Results     []*snapper.ManualResult
+	WakeupError string
+}
+
+// PruneReq is the request body of ControlJobEndpointPrune.
+type PruneReq struct {
+	Name string // job name
+	// must be set, pruning is only done by the job itself
+	DryRun bool
+}
+
+// PruneRes is the response body of ControlJobEndpointPrune.
+type PruneRes struct {
+	// by prune side (sender, receiver or local)
+	Sides map[string]*pruner.Report
+}
+
 func (j *controlJob) Run(ctx context.Context) {
 
 	log := job.GetLogger(ctx)
@@ -108,11 +150,7 @@ func (j *controlJob) Run(ctx context.Context) {
 
 	mux.Handle(ControlJobEndpointSignal,
 		requestLogger{log: log, handler: jsonRequestResponder{func(decoder jsonDecoder) (interface{}, error) {
-			type reqT struct {
-				Name string
-				Op string
-			}
-			var req reqT
+			var req SignalReq
 			if decoder(&req) != nil {
 				return nil, errors.Errorf("decode failed")
 			}
@@ -123,16 +161,49 @@ func (j *controlJob) Run(ctx context.Context) {
 				err = j.jobs.wakeup(req.Name)
 			case "reset":
 				err = j.jobs.reset(req.Name)
+			case "pause":
+				err = j.jobs.pause(req.Name, req.Until)
+			case "resume":
+				err = j.jobs.resume(req.Name)
 			default:
 				err = fmt.Errorf("operation %q is invalid", req.Op)
 			}
 
 			return struct{}{}, err
 		}}})
+	mux.Handle(ControlJobEndpointSnapshot,
+		requestLogger{log: log, handler: jsonRequestResponder{func(decoder jsonDecoder) (interface{}, error) {
+			var req SnapshotReq
+			if decoder(&req) != nil {
+				return nil, errors.Errorf("decode failed")
+			}
+			return j.jobs.snapshot(ctx, &req)
+		}}})
+
+	mux.Handle(ControlJobEndpointPrune,
+		requestLogger{log: log, handler: jsonRequestResponder{func(decoder jsonDecoder) (interface{}, error) {
+			var req PruneReq
+			if decoder(&req) != nil {
+				return nil, errors.Errorf("decode failed")
+			}
+			return j.jobs.pruneDryRun(ctx, &req)
+		}}})
+
+	mux.Handle(ControlJobEndpointReload,
+		requestLogger{log: log, handler: jsonRequestResponder{func(decoder jsonDecoder) (interface{}, error) {
+			var req ReloadReq
+			if decoder(&req) != nil {
+				return nil, errors.Errorf("decode failed")
+			}
+			return j.reloader.reload(&req)
+		}}})
+
 	server := http.Server{
 		Handler: mux,
 		// control socket is local, 1s timeout should be more than sufficient, even on a loaded system
-		WriteTimeout: 1*time.Second,
+		// (except for writing responses to requests that run zfs commands or wait for jobs to exit,
+		// e.g. ControlJobEndpointSnapshot, ControlJobEndpointReload and ControlJobEndpointPrune)
+		WriteTimeout: envconst.Duration("ZREPL_CONTROL_WRITE_TIMEOUT", 1*time.Minute),
 		ReadTimeout: 1*time.Second,
 	}
 
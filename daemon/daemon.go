@@ -6,11 +6,17 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/bandwidth"
 	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/job/pause"
 	"github.com/zrepl/zrepl/daemon/job/reset"
+	"github.com/zrepl/zrepl/daemon/job/stop"
 	"github.com/zrepl/zrepl/daemon/job/wakeup"
 	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/daemon/transferlimit"
 	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/util/ratelimit"
 	"github.com/zrepl/zrepl/version"
 	"os"
 	"os/signal"
@@ -20,7 +26,8 @@ import (
 	"time"
 )
 
-func Run(conf *config.Config) error {
+// configPath is the path conf was parsed from, it is re-read on reload.
+func Run(conf *config.Config, configPath string) error {
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -53,10 +60,25 @@ func Run(conf *config.Config) error {
 
 	ctx = job.WithLogger(ctx, log)
 
-	jobs := newJobs()
+	limiter, err := transferlimit.FromConfig(conf.Global.Transfers)
+	if err != nil {
+		return errors.Wrap(err, "cannot build transfer limits")
+	}
+	limiter.RegisterMetrics(prometheus.DefaultRegisterer)
+
+	globalBandwidth, err := bandwidth.FromConfig(conf.Global.BandwidthLimit)
+	if err != nil {
+		return errors.Wrap(err, "cannot build global bandwidth limit")
+	}
+
+	jobs := newJobs(newPauseStore(conf.Global.Control.StateDir), limiter, globalBandwidth)
+	if err := jobs.loadPauses(confJobs); err != nil {
+		return err
+	}
+	reloader := newReloader(ctx, configPath, conf, jobs)
 
 	// start control socket
-	controlJob, err := newControlJob(conf.Global.Control.SockPath, jobs)
+	controlJob, err := newControlJob(conf.Global.Control.SockPath, jobs, reloader)
 	if err != nil {
 		panic(err) // FIXME
 	}
@@ -87,6 +109,21 @@ func Run(conf *config.Config) error {
 		jobs.start(ctx, j, false)
 	}
 
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-hupChan:
+				log.Info("SIGHUP received, reloading config")
+				// errors and results are logged by reload
+				reloader.reload(&ReloadReq{})
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
 	select {
 	case <-jobs.wait():
 		log.Info("all jobs finished")
@@ -104,13 +141,29 @@ type jobs struct {
 	m       sync.RWMutex
 	wakeups map[string]wakeup.Func // by Job.Name
 	resets map[string]reset.Func // by Job.Name
+	stops   map[string]stop.Func // by Job.Name
+	cancels map[string]context.CancelFunc // by Job.Name
+	dones   map[string]<-chan struct{}    // by Job.Name, closed once the job's Run returned
 	jobs    map[string]job.Job
+	// by Job.Name, outlives the job when it is restarted
+	pauses     map[string]*pause.State
+	pauseStore *pauseStore
+	// shared by all jobs
+	limiter   *transferlimit.Limiter
+	bandwidth *ratelimit.Bucket // nil if unlimited
 }
 
-func newJobs() *jobs {
+func newJobs(pauseStore *pauseStore, limiter *transferlimit.Limiter, globalBandwidth *ratelimit.Bucket) *jobs {
 	return &jobs{
+		pauses:     make(map[string]*pause.State),
+		pauseStore: pauseStore,
+		limiter:    limiter,
+		bandwidth:  globalBandwidth,
 		wakeups: make(map[string]wakeup.Func),
 		resets:  make(map[string]reset.Func),
+		stops:   make(map[string]stop.Func),
+		cancels: make(map[string]context.CancelFunc),
+		dones:   make(map[string]<-chan struct{}),
 		jobs:    make(map[string]job.Job),
 	}
 }
@@ -150,6 +203,11 @@ func (s *jobs) status() map[string]*job.Status {
 	close(c)
 	ret := make(map[string]*job.Status, len(s.jobs))
 	for res := range c {
+		if p, ok := s.pauses[res.name]; ok {
+			if st := p.Status(); st.Paused {
+				res.status.Paused = &st
+			}
+		}
 		ret[res.name] = res.status
 	}
 	return ret
@@ -163,9 +221,94 @@ func (s *jobs) wakeup(job string) error {
 	if !ok {
 		return errors.Errorf("Job %s does not exist", job)
 	}
+	if p, ok := s.pauses[job]; ok && p.Status().Paused {
+		return errors.Errorf("Job %s is paused", job)
+	}
 	return wu()
 }
 
+// loadPauses restores the pause state of confJobs persisted by a previous daemon, it must be called before jobs are started.
+// The pauses of jobs that are no longer configured are dropped.
+func (s *jobs) loadPauses(confJobs []job.Job) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	persisted, err := s.pauseStore.load()
+	if err != nil {
+		return err
+	}
+	for _, j := range confJobs {
+		if st, ok := persisted[j.Name()]; ok {
+			s.pauses[j.Name()] = pause.NewState(st)
+		}
+	}
+	return nil
+}
+
+// savePauses persists the pause state of all jobs, with next instead of the current state for job.
+// s.m must be held.
+func (s *jobs) savePauses(job string, next pause.Status) error {
+	m := make(map[string]pause.Status, len(s.pauses))
+	for name, p := range s.pauses {
+		if st := p.Status(); st.Paused {
+			m[name] = st
+		}
+	}
+	delete(m, job)
+	if next.Paused {
+		m[job] = next
+	}
+	return s.pauseStore.save(m)
+}
+
+// pause keeps job from starting new snapshot, replication and prune cycles until until or, if until is zero,
+// until it is resumed. The pause is persisted, it outlives daemon restarts.
+func (s *jobs) pause(job string, until time.Time) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if _, ok := s.jobs[job]; !ok || IsInternalJobName(job) {
+		return errors.Errorf("Job %s does not exist", job)
+	}
+	if !until.IsZero() && !until.After(time.Now()) {
+		return errors.Errorf("pause expiry %s is in the past", until)
+	}
+	if err := s.savePauses(job, pause.Status{Paused: true, Until: until}); err != nil {
+		return err
+	}
+	s.pauses[job].Pause(until)
+	return nil
+}
+
+func (s *jobs) resume(job string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if _, ok := s.jobs[job]; !ok || IsInternalJobName(job) {
+		return errors.Errorf("Job %s does not exist", job)
+	}
+	if err := s.savePauses(job, pause.Status{}); err != nil {
+		return err
+	}
+	s.pauses[job].Resume()
+	return nil
+}
+
+// forgetPause drops the pause state of a job that has been removed from the config.
+func (s *jobs) forgetPause(job string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if _, ok := s.pauses[job]; !ok {
+		return nil
+	}
+	if err := s.savePauses(job, pause.Status{}); err != nil {
+		return err
+	}
+	delete(s.pauses, job)
+	return nil
+}
+
 func (s *jobs) reset(job string) error {
 	s.m.RLock()
 	defer s.m.RUnlock()
@@ -177,6 +320,92 @@ func (s *jobs) reset(job string) error {
 	return wu()
 }
 
+// stop asks job to exit, either after its current invocation or, if cancelInvocation is set, immediately.
+// The returned channel is closed once the job has exited, the caller must then remove the job.
+func (s *jobs) stop(job string, cancelInvocation bool) (<-chan struct{}, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	done, ok := s.dones[job]
+	if !ok {
+		return nil, errors.Errorf("Job %s does not exist", job)
+	}
+	if cancelInvocation {
+		s.cancels[job]()
+	} else {
+		s.stops[job]()
+	}
+	return done, nil
+}
+
+// remove removes an exited job so that a job with the same name can be started.
+func (s *jobs) remove(job string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	delete(s.wakeups, job)
+	delete(s.resets, job)
+	delete(s.stops, job)
+	delete(s.cancels, job)
+	delete(s.dones, job)
+	delete(s.jobs, job)
+}
+
+func (s *jobs) snapshot(ctx context.Context, req *SnapshotReq) (*SnapshotRes, error) {
+	// do not hold the lock while the snapshots are taken
+	s.m.RLock()
+	j, ok := s.jobs[req.Name]
+	wu := s.wakeups[req.Name]
+	s.m.RUnlock()
+
+	if !ok {
+		return nil, errors.Errorf("Job %s does not exist", req.Name)
+	}
+	snapshotter, ok := j.(job.Snapshotter)
+	if !ok {
+		return nil, errors.Errorf("Job %s does not take snapshots", req.Name)
+	}
+
+	ctx = snapper.WithLogger(ctx, job.GetLogger(ctx).WithField(logJobField, req.Name))
+	results, err := snapshotter.SnapshotNow(ctx, &req.ManualRequest)
+	if err != nil {
+		return nil, err
+	}
+	res := &SnapshotRes{Results: results}
+
+	if req.Wakeup {
+		if err := wu(); err != nil {
+			res.WakeupError = err.Error()
+		}
+	}
+	return res, nil
+}
+
+func (s *jobs) pruneDryRun(ctx context.Context, req *PruneReq) (*PruneRes, error) {
+	if !req.DryRun {
+		return nil, errors.New("only dry runs are supported, the job prunes during its invocations")
+	}
+
+	s.m.RLock()
+	j, ok := s.jobs[req.Name]
+	s.m.RUnlock()
+
+	if !ok {
+		return nil, errors.Errorf("Job %s does not exist", req.Name)
+	}
+	dryRunner, ok := j.(job.PruneDryRunner)
+	if !ok {
+		return nil, errors.Errorf("Job %s does not prune", req.Name)
+	}
+
+	ctx = logging.WithSubsystemLoggers(ctx, job.GetLogger(ctx).WithField(logJobField, req.Name))
+	sides, err := dryRunner.PruneDryRun(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &PruneRes{Sides: sides}, nil
+}
+
 const (
 	jobNamePrometheus = "_prometheus"
 	jobNameControl    = "_control"
@@ -204,18 +433,37 @@ func (s *jobs) start(ctx context.Context, j job.Job, internal bool) {
 		panic(fmt.Sprintf("duplicate job name %s", jobName))
 	}
 
-	j.RegisterMetrics(prometheus.DefaultRegisterer)
+	// unregistered when the job exits so that it can be restarted
+	registerer := newJobRegisterer(prometheus.DefaultRegisterer)
+	j.RegisterMetrics(registerer)
 
 	s.jobs[jobName] = j
 	ctx = job.WithLogger(ctx, jobLog)
+	ctx, cancel := context.WithCancel(ctx)
 	ctx, wakeup := wakeup.Context(ctx)
 	ctx, resetFunc := reset.Context(ctx)
+	ctx, stopFunc := stop.Context(ctx)
+	ctx = transferlimit.WithLimiter(ctx, s.limiter)
+	ctx = bandwidth.WithGlobal(ctx, s.bandwidth)
+	if !internal {
+		if _, ok := s.pauses[jobName]; !ok {
+			s.pauses[jobName] = pause.NewState(pause.Status{})
+		}
+		ctx = pause.Context(ctx, s.pauses[jobName])
+	}
+	done := make(chan struct{})
 	s.wakeups[jobName] = wakeup
 	s.resets[jobName] = resetFunc
+	s.stops[jobName] = stopFunc
+	s.cancels[jobName] = cancel
+	s.dones[jobName] = done
 
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
+		defer close(done)
+		defer registerer.unregisterAll()
+		defer cancel()
 		jobLog.Info("starting job")
 		defer jobLog.Info("job exited")
 		j.Run(ctx)
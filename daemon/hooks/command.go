@@ -0,0 +1,141 @@
+package hooks
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// CommandHook runs an executable with the hook's parameters in its environment.
+// stdout and stderr of the executable are logged line by line.
+type CommandHook struct {
+	path       string
+	timeout    time.Duration
+	filter     *filters.DatasetMapFilter // nil => all filesystems
+	errIsFatal bool
+}
+
+func CommandHookFromConfig(in *config.HookCommand) (*CommandHook, error) {
+	if !filepath.IsAbs(in.Path) {
+		return nil, fmt.Errorf("command hook path must be absolute: %q", in.Path)
+	}
+	filter, err := filterFromConfig(in.Filesystems)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse filesystem filter: %s", err)
+	}
+	return &CommandHook{
+		path:       in.Path,
+		timeout:    in.Timeout,
+		filter:     filter,
+		errIsFatal: in.ErrIsFatal,
+	}, nil
+}
+
+func (h *CommandHook) String() string { return fmt.Sprintf("command %s", h.path) }
+
+func (h *CommandHook) ErrIsFatal() bool { return h.errIsFatal }
+
+func (h *CommandHook) Filter(fs *zfs.DatasetPath) (bool, error) {
+	if h.filter == nil {
+		return true, nil
+	}
+	return h.filter.Filter(fs)
+}
+
+// Run runs the executable in its own process group.
+// On timeout, the whole group is killed so that processes started by the executable
+// do not keep Run waiting for its stdout and stderr.
+// Run also waits for background processes that inherited stdout or stderr, at most until the timeout.
+func (h *CommandHook) Run(ctx context.Context, log Logger, typ Type, fs *zfs.DatasetPath, snapname string, dryRun bool) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	cmd := exec.Command(h.path)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("%s=%s", EnvType, typ),
+		fmt.Sprintf("%s=%s", EnvFS, fs.ToString()),
+		fmt.Sprintf("%s=%s", EnvSnapName, snapname),
+		fmt.Sprintf("%s=%t", EnvDryRun, dryRun),
+	)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	// os.Pipe instead of cmd.StdoutPipe so that cmd.Wait does not wait for the output
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer stdoutR.Close()
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		stdoutW.Close()
+		return err
+	}
+	defer stderrR.Close()
+	cmd.Stdout, cmd.Stderr = stdoutW, stderrW
+
+	err = cmd.Start()
+	stdoutW.Close()
+	stderrW.Close()
+	if err != nil {
+		return err
+	}
+
+	exited := make(chan struct{})
+	defer close(exited)
+	go func() {
+		select {
+		case <-ctx.Done():
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-exited:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	logLines := func(r io.Reader, stream string) {
+		defer wg.Done()
+		l := log.WithField("fs", fs.ToString()).WithField("stream", stream)
+		s := bufio.NewScanner(r)
+		for s.Scan() {
+			l.Info(s.Text())
+		}
+		if err := s.Err(); err != nil && ctx.Err() == nil {
+			l.WithError(err).Warn("cannot log output, discarding the rest")
+		}
+		// the executable must not block on writing its output
+		io.Copy(ioutil.Discard, r)
+	}
+	wg.Add(2)
+	go logLines(stdoutR, "stdout")
+	go logLines(stderrR, "stderr")
+	logged := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(logged)
+	}()
+
+	err = cmd.Wait()
+	select {
+	case <-logged:
+	case <-ctx.Done():
+		// processes that left the process group may still hold the pipes open
+		stdoutR.Close()
+		stderrR.Close()
+		<-logged
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", h.timeout)
+	}
+	return err
+}
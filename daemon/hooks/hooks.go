@@ -0,0 +1,138 @@
+// Package hooks implements user-defined hooks that are run before and after snapshots are taken.
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+type Logger = logger.Logger
+
+type Type string
+
+const (
+	PreSnapshot  Type = "pre_snapshot"
+	PostSnapshot Type = "post_snapshot"
+)
+
+// Environment variables passed to hooks
+const (
+	EnvType     = "ZREPL_HOOKTYPE"
+	EnvFS       = "ZREPL_FS"
+	EnvSnapName = "ZREPL_SNAPNAME"
+	EnvDryRun   = "ZREPL_DRYRUN"
+)
+
+type Hook interface {
+	String() string
+	// whether a failing pre_snapshot hook prevents the snapshot from being taken
+	ErrIsFatal() bool
+	// whether the hook applies to filesystem fs
+	Filter(fs *zfs.DatasetPath) (bool, error)
+	Run(ctx context.Context, log Logger, typ Type, fs *zfs.DatasetPath, snapname string, dryRun bool) error
+}
+
+// List is an ordered list of hooks.
+type List []Hook
+
+func ListFromConfig(in config.HookList) (List, error) {
+	l := make(List, len(in))
+	for i, h := range in {
+		var err error
+		switch v := h.Ret.(type) {
+		case *config.HookCommand:
+			l[i], err = CommandHookFromConfig(v)
+		default:
+			err = fmt.Errorf("unknown hook type %T", v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("hook %d: %s", i, err)
+		}
+	}
+	return l, nil
+}
+
+func filterFromConfig(in config.FilesystemsFilter) (*filters.DatasetMapFilter, error) {
+	if len(in) == 0 {
+		return nil, nil // all filesystems
+	}
+	return filters.DatasetMapFilterFromConfig(in)
+}
+
+type Report struct {
+	Hook       string
+	Type       Type
+	ErrIsFatal bool
+	StartAt    time.Time
+	DoneAt     time.Time
+	Error      string
+}
+
+// Invocation tracks the hooks run for the snapshot of a single filesystem.
+type Invocation struct {
+	fs       *zfs.DatasetPath
+	snapname string
+	dryRun   bool
+	// hooks whose pre_snapshot hook has been run, in order
+	ran     List
+	Reports []*Report
+}
+
+func (i *Invocation) run(ctx context.Context, log Logger, h Hook, typ Type) error {
+	r := &Report{
+		Hook:       h.String(),
+		Type:       typ,
+		ErrIsFatal: h.ErrIsFatal(),
+		StartAt:    time.Now(),
+	}
+	l := log.WithField("hook", h.String()).WithField("hook_type", string(typ))
+	l.Debug("run hook")
+	err := h.Run(ctx, l, typ, i.fs, i.snapname, i.dryRun)
+	r.DoneAt = time.Now()
+	if err != nil {
+		r.Error = err.Error()
+		l.WithError(err).WithField("err_is_fatal", h.ErrIsFatal()).Error("hook failed")
+	}
+	i.Reports = append(i.Reports, r)
+	return err
+}
+
+// RunPre runs the pre_snapshot hooks of l that apply to fs, in order.
+// If a hook with err_is_fatal fails, the remaining hooks are not run and RunPre returns that hook's error,
+// in which case the caller must not take the snapshot.
+// Regardless of the error, the caller must call RunPost on the returned Invocation.
+func (l List) RunPre(ctx context.Context, log Logger, fs *zfs.DatasetPath, snapname string, dryRun bool) (*Invocation, error) {
+	i := &Invocation{fs: fs, snapname: snapname, dryRun: dryRun}
+	for _, h := range l {
+		applies, err := h.Filter(fs)
+		if err != nil {
+			return i, fmt.Errorf("cannot evaluate filesystem filter of hook %s: %s", h, err)
+		}
+		if !applies {
+			continue
+		}
+		i.ran = append(i.ran, h)
+		if err := i.run(ctx, log, h, PreSnapshot); err != nil && h.ErrIsFatal() {
+			return i, fmt.Errorf("pre_snapshot hook %s failed: %s", h, err)
+		}
+	}
+	return i, nil
+}
+
+// RunPost runs the post_snapshot hooks of those hooks whose pre_snapshot hook has been run, in reverse order.
+// Returns the error of the first failing hook with err_is_fatal.
+func (i *Invocation) RunPost(ctx context.Context, log Logger) (fatal error) {
+	for j := len(i.ran) - 1; j >= 0; j-- {
+		h := i.ran[j]
+		if err := i.run(ctx, log, h, PostSnapshot); err != nil && h.ErrIsFatal() && fatal == nil {
+			fatal = fmt.Errorf("post_snapshot hook %s failed: %s", h, err)
+		}
+	}
+	return fatal
+}
@@ -0,0 +1,161 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// writeScript creates an executable shell script in dir that appends its name and the hook environment to out
+// and then executes body.
+func writeScript(t *testing.T, dir, name, out, body string) string {
+	path := filepath.Join(dir, name)
+	script := fmt.Sprintf(`#!/bin/sh
+echo "%s $ZREPL_HOOKTYPE $ZREPL_FS $ZREPL_SNAPNAME $ZREPL_DRYRUN" >> %s
+%s
+`, name, out, body)
+	require.NoError(t, ioutil.WriteFile(path, []byte(script), 0755))
+	return path
+}
+
+func readLines(t *testing.T, path string) []string {
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
+
+func TestHooks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-hooks-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	log := logger.NewTestLogger(t)
+	fs, err := zfs.NewDatasetPath("pool/db")
+	require.NoError(t, err)
+	other, err := zfs.NewDatasetPath("pool/other")
+	require.NoError(t, err)
+
+	hook := func(name, body string, errIsFatal bool, filter config.FilesystemsFilter) *CommandHook {
+		h, err := CommandHookFromConfig(&config.HookCommand{
+			Path:        writeScript(t, dir, name, filepath.Join(dir, "out"), body),
+			Timeout:     time.Second,
+			Filesystems: filter,
+			ErrIsFatal:  errIsFatal,
+		})
+		require.NoError(t, err)
+		return h
+	}
+	reset := func() { os.Remove(filepath.Join(dir, "out")) }
+
+	t.Run("order-and-env", func(t *testing.T) {
+		defer reset()
+		l := List{hook("a", "", false, nil), hook("b", "", false, config.FilesystemsFilter{"pool/db": true})}
+
+		inv, err := l.RunPre(ctx, log, fs, "zrepl_1", false)
+		require.NoError(t, err)
+		assert.NoError(t, inv.RunPost(ctx, log))
+		assert.Equal(t, []string{
+			"a pre_snapshot pool/db zrepl_1 false",
+			"b pre_snapshot pool/db zrepl_1 false",
+			"b post_snapshot pool/db zrepl_1 false",
+			"a post_snapshot pool/db zrepl_1 false",
+		}, readLines(t, filepath.Join(dir, "out")))
+		require.Len(t, inv.Reports, 4)
+		assert.Equal(t, PreSnapshot, inv.Reports[0].Type)
+		assert.Equal(t, PostSnapshot, inv.Reports[3].Type)
+		assert.Empty(t, inv.Reports[0].Error)
+	})
+
+	t.Run("filter-and-dryrun", func(t *testing.T) {
+		defer reset()
+		l := List{hook("a", "", false, config.FilesystemsFilter{"pool/db": true})}
+
+		inv, err := l.RunPre(ctx, log, other, "zrepl_1", true)
+		require.NoError(t, err)
+		assert.NoError(t, inv.RunPost(ctx, log))
+		assert.Empty(t, inv.Reports)
+
+		inv, err = l.RunPre(ctx, log, fs, "zrepl_1", true)
+		require.NoError(t, err)
+		assert.NoError(t, inv.RunPost(ctx, log))
+		assert.Equal(t, []string{
+			"a pre_snapshot pool/db zrepl_1 true",
+			"a post_snapshot pool/db zrepl_1 true",
+		}, readLines(t, filepath.Join(dir, "out")))
+	})
+
+	t.Run("non-fatal-error", func(t *testing.T) {
+		defer reset()
+		l := List{hook("a", "exit 1", false, nil), hook("b", "", false, nil)}
+
+		inv, err := l.RunPre(ctx, log, fs, "zrepl_1", false)
+		assert.NoError(t, err)
+		assert.NoError(t, inv.RunPost(ctx, log))
+		assert.Len(t, readLines(t, filepath.Join(dir, "out")), 4)
+		assert.NotEmpty(t, inv.Reports[0].Error)
+	})
+
+	t.Run("fatal-error", func(t *testing.T) {
+		defer reset()
+		l := List{hook("a", "", true, nil), hook("b", "exit 1", true, nil), hook("c", "", true, nil)}
+
+		inv, err := l.RunPre(ctx, log, fs, "zrepl_1", false)
+		assert.Error(t, err)
+		assert.Error(t, inv.RunPost(ctx, log)) // b's post hook fails, too
+		assert.Equal(t, []string{
+			"a pre_snapshot pool/db zrepl_1 false",
+			"b pre_snapshot pool/db zrepl_1 false",
+			"b post_snapshot pool/db zrepl_1 false",
+			"a post_snapshot pool/db zrepl_1 false",
+		}, readLines(t, filepath.Join(dir, "out")))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		defer reset()
+		l := List{hook("a", "exec sleep 10", true, nil)}
+
+		start := time.Now()
+		_, err := l.RunPre(ctx, log, fs, "zrepl_1", false)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "timed out")
+		assert.True(t, time.Since(start) < 5*time.Second)
+	})
+
+	t.Run("timeout-kills-children", func(t *testing.T) {
+		defer reset()
+		// sleep is a child of the shell and inherits its stdout
+		l := List{hook("a", "echo hi; sleep 10", true, nil)}
+
+		start := time.Now()
+		_, err := l.RunPre(ctx, log, fs, "zrepl_1", false)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "timed out")
+		assert.True(t, time.Since(start) < 5*time.Second, "took %s", time.Since(start))
+	})
+
+	t.Run("long-output-line", func(t *testing.T) {
+		defer reset()
+		// longer than the line limit of the logger, followed by more output than fits into the pipe
+		l := List{hook("a", `head -c 100000 /dev/zero | tr '\0' x; echo; head -c 1000000 /dev/zero`, true, nil)}
+
+		_, err := l.RunPre(ctx, log, fs, "zrepl_1", false)
+		assert.NoError(t, err)
+	})
+}
+
+func TestCommandHookFromConfig(t *testing.T) {
+	_, err := CommandHookFromConfig(&config.HookCommand{Path: "relative/path", Timeout: time.Second})
+	assert.Error(t, err)
+}
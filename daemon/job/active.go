@@ -2,21 +2,31 @@ package job
 
 import (
 	"context"
+	"fmt"
 	"github.com/pkg/errors"
 	"github.com/problame/go-streamrpc"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/bandwidth"
 	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/daemon/job/pause"
 	"github.com/zrepl/zrepl/daemon/job/reset"
+	"github.com/zrepl/zrepl/daemon/job/stop"
 	"github.com/zrepl/zrepl/daemon/job/wakeup"
 	"github.com/zrepl/zrepl/daemon/logging"
 	"github.com/zrepl/zrepl/daemon/pruner"
 	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/daemon/transferlimit"
 	"github.com/zrepl/zrepl/daemon/transport/connecter"
 	"github.com/zrepl/zrepl/endpoint"
 	"github.com/zrepl/zrepl/replication"
+	"github.com/zrepl/zrepl/replication/fsrep"
 	"github.com/zrepl/zrepl/util/envconst"
+	"github.com/zrepl/zrepl/util/ratelimit"
+	"github.com/zrepl/zrepl/util/window"
 	"github.com/zrepl/zrepl/zfs"
+	"math"
+	"strings"
 	"sync"
 	"time"
 )
@@ -24,13 +34,18 @@ import (
 type ActiveSide struct {
 	mode          activeMode
 	name          string
-	clientFactory *connecter.ClientFactory
+	clientFactory *connecter.ClientFactory // nil for local jobs
+	remote        string                   // name of the passive side for transfer limits, empty for local jobs
 
 	prunerFactory *pruner.PrunerFactory
 
+	replicationOptions replication.Options
+	windows            *replicationWindows // nil if replication is allowed at any time
+	bandwidth          *ratelimit.Bucket   // nil if the job's send streams are not limited
 
 	promRepStateSecs *prometheus.HistogramVec // labels: state
 	promPruneSecs *prometheus.HistogramVec // labels: prune_side
+	promPruneSpace pruner.SpaceMetrics     // labels: prune_side
 	promBytesReplicated *prometheus.CounterVec // labels: filesystem
 
 	tasksMtx sync.Mutex
@@ -52,6 +67,9 @@ const (
 type activeSideTasks struct {
 	state ActiveSideState
 
+	// an invocation is waiting for the replication window to open
+	deferred bool
+
 	// valid for state ActiveSideReplicating, ActiveSidePruneSender, ActiveSidePruneReceiver, ActiveSideDone
 	replication *replication.Replication
 	replicationCancel context.CancelFunc
@@ -77,24 +95,43 @@ func (a *ActiveSide) updateTasks(u func(*activeSideTasks)) activeSideTasks {
 }
 
 type activeMode interface {
+	// client is nil for modes that do not connect to a passive side
 	SenderReceiver(client *streamrpc.Client) (replication.Sender, replication.Receiver, error)
 	Type() Type
 	RunPeriodic(ctx context.Context, wakeUpCommon chan<- struct{})
+	// LocalPools returns the pools on this machine that a send/receive of the sender's filesystem fs uses
+	LocalPools(fs string) []string
+	// SendFeatures returns the stream features that the job requires from the sender
+	SendFeatures() fsrep.SendFeatures
+}
+
+// poolOf returns the pool of the filesystem path fs.
+func poolOf(fs string) string {
+	return strings.SplitN(fs, "/", 2)[0]
 }
 
 type modePush struct {
 	fsfilter         endpoint.FSFilter
+	sendFlags        zfs.ZFSSendFlags
+	holdTag          string
+	bookmarkJob      string
 	snapper *snapper.PeriodicOrManual
 }
 
 func (m *modePush) SenderReceiver(client *streamrpc.Client) (replication.Sender, replication.Receiver, error) {
-	sender := endpoint.NewSender(m.fsfilter)
+	sender := endpoint.NewSender(m.fsfilter, m.sendFlags)
+	sender.HoldReplicated(m.holdTag)
+	sender.BookmarkReplicated(m.bookmarkJob)
 	receiver := endpoint.NewRemote(client)
 	return sender, receiver, nil
 }
 
 func (m *modePush) Type() Type { return TypePush }
 
+func (m *modePush) LocalPools(fs string) []string { return []string{poolOf(fs)} }
+
+func (m *modePush) SendFeatures() fsrep.SendFeatures { return sendFeaturesFromFlags(m.sendFlags) }
+
 func (m *modePush) RunPeriodic(ctx context.Context, wakeUpCommon chan <- struct{}) {
 	m.snapper.Run(ctx, wakeUpCommon)
 }
@@ -107,6 +144,9 @@ func modePushFromConfig(g *config.Global, in *config.PushJob) (*modePush, error)
 		return nil, errors.Wrap(err, "cannnot build filesystem filter")
 	}
 	m.fsfilter = fsf
+	m.sendFlags = sendFlagsFromConfig(in.Send)
+	m.holdTag = holdTagFromConfig(in.Name, in.Send)
+	m.bookmarkJob = bookmarkJobFromConfig(in.Name, in.Send)
 
 	if m.snapper, err = snapper.FromConfig(g, fsf, in.Snapshotting); err != nil {
 		return nil, errors.Wrap(err, "cannot build snapper")
@@ -116,18 +156,24 @@ func modePushFromConfig(g *config.Global, in *config.PushJob) (*modePush, error)
 }
 
 type modePull struct {
-	rootFS   *zfs.DatasetPath
-	interval time.Duration
+	rootFS    *zfs.DatasetPath
+	recvProps zfs.ZFSRecvProperties
+	interval  time.Duration
 }
 
 func (m *modePull) SenderReceiver(client *streamrpc.Client) (replication.Sender, replication.Receiver, error) {
 	sender := endpoint.NewRemote(client)
-	receiver, err := endpoint.NewReceiver(m.rootFS)
+	receiver, err := endpoint.NewReceiver(m.rootFS, m.recvProps)
 	return sender, receiver, err
 }
 
 func (*modePull) Type() Type { return TypePull }
 
+func (m *modePull) LocalPools(fs string) []string { return []string{poolOf(m.rootFS.ToString())} }
+
+// SendFeatures returns no features since pull jobs have no send options, the source job decides which features it uses.
+func (m *modePull) SendFeatures() fsrep.SendFeatures { return fsrep.SendFeatures{} }
+
 func (m *modePull) RunPeriodic(ctx context.Context, wakeUpCommon chan<- struct{}) {
 	t := time.NewTicker(m.interval)
 	defer t.Stop()
@@ -163,10 +209,154 @@ func modePullFromConfig(g *config.Global, in *config.PullJob) (m *modePull, err
 		return nil, errors.New("RootFS must not be empty") // duplicates error check of receiver
 	}
 
+	if m.recvProps, err = recvPropertiesFromConfig(in.Recv); err != nil {
+		return nil, err
+	}
+
 	return m, nil
 }
 
-func activeSide(g *config.Global, in *config.ActiveJob, mode activeMode) (j *ActiveSide, err error) {
+// modeLocal replicates in-process from a Sender to a Receiver on the same host,
+// without transport or RPC.
+type modeLocal struct {
+	fsfilter    endpoint.FSFilter
+	rootFS      *zfs.DatasetPath
+	sendFlags   zfs.ZFSSendFlags
+	holdTag     string
+	bookmarkJob string
+	recvProps   zfs.ZFSRecvProperties
+	snapper     *snapper.PeriodicOrManual
+}
+
+func (m *modeLocal) SenderReceiver(_ *streamrpc.Client) (replication.Sender, replication.Receiver, error) {
+	sender := endpoint.NewSender(m.fsfilter, m.sendFlags)
+	sender.HoldReplicated(m.holdTag)
+	sender.BookmarkReplicated(m.bookmarkJob)
+	receiver, err := endpoint.NewReceiver(m.rootFS, m.recvProps)
+	return sender, receiver, err
+}
+
+func (m *modeLocal) Type() Type { return TypeLocal }
+
+func (m *modeLocal) LocalPools(fs string) []string {
+	return []string{poolOf(fs), poolOf(m.rootFS.ToString())}
+}
+
+func (m *modeLocal) SendFeatures() fsrep.SendFeatures { return sendFeaturesFromFlags(m.sendFlags) }
+
+func (m *modeLocal) RunPeriodic(ctx context.Context, wakeUpCommon chan<- struct{}) {
+	m.snapper.Run(ctx, wakeUpCommon)
+}
+
+func modeLocalFromConfig(g *config.Global, in *config.LocalJob) (m *modeLocal, err error) {
+	m = &modeLocal{}
+	fsf, err := filters.DatasetMapFilterFromConfig(in.Filesystems)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannnot build filesystem filter")
+	}
+	m.fsfilter = fsf
+	m.sendFlags = sendFlagsFromConfig(in.Send)
+	m.holdTag = holdTagFromConfig(in.Name, in.Send)
+	m.bookmarkJob = bookmarkJobFromConfig(in.Name, in.Send)
+
+	m.rootFS, err = zfs.NewDatasetPath(in.RootFS)
+	if err != nil {
+		return nil, errors.New("root_fs is not a valid zfs filesystem path")
+	}
+	if m.rootFS.Length() <= 0 {
+		return nil, errors.New("root_fs must not be empty") // duplicates error check of receiver
+	}
+	// otherwise, the received filesystems would be replicated again
+	if pass, err := fsf.Filter(m.rootFS); err != nil {
+		return nil, errors.Wrap(err, "cannot filter root_fs")
+	} else if pass {
+		return nil, errors.New("root_fs must not be matched by the filesystems filter")
+	}
+
+	if m.recvProps, err = recvPropertiesFromConfig(in.Recv); err != nil {
+		return nil, err
+	}
+
+	if m.snapper, err = snapper.FromConfig(g, fsf, in.Snapshotting); err != nil {
+		return nil, errors.Wrap(err, "cannot build snapper")
+	}
+
+	return m, nil
+}
+
+// replicationWindows restricts the invocations of an active job to a schedule of windows.
+type replicationWindows struct {
+	windows       *window.Windows
+	cancelOnClose bool
+}
+
+func replicationWindowsFromConfig(in *config.ReplicationWindows) (*replicationWindows, error) {
+	loc, err := time.LoadLocation(in.TimeZone)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid timezone")
+	}
+	if len(in.Allow) == 0 {
+		return nil, errors.New("allow must contain at least one window")
+	}
+	ws := make([]*window.Window, len(in.Allow))
+	for i, w := range in.Allow {
+		if ws[i], err = window.Parse(w.Days, w.Start, w.End); err != nil {
+			return nil, errors.Wrapf(err, "invalid window #%d", i)
+		}
+	}
+	r := &replicationWindows{windows: window.New(ws, loc)}
+	switch in.OnClose {
+	case "finish":
+	case "cancel":
+		r.cancelOnClose = true
+	default:
+		return nil, errors.Errorf("unknown on_close policy %q", in.OnClose)
+	}
+	return r, nil
+}
+
+func (w *replicationWindows) status(now time.Time) *ReplicationWindowStatus {
+	s := &ReplicationWindowStatus{
+		Open:          w.windows.Contains(now),
+		CancelOnClose: w.cancelOnClose,
+	}
+	if s.Open {
+		s.ClosesAt = w.windows.NextClose(now)
+	} else {
+		s.NextOpen = w.windows.NextOpen(now)
+	}
+	return s
+}
+
+// remoteName identifies the passive side that connect connects to.
+func remoteName(connect *config.ConnectEnum) string {
+	switch v := connect.Ret.(type) {
+	case *config.TCPConnect:
+		return v.Address
+	case *config.TLSConnect:
+		return v.Address
+	case *config.SSHStdinserverConnect:
+		return fmt.Sprintf("%s:%d", v.Host, v.Port)
+	case *config.LocalConnect:
+		return "local:" + v.ListenerName
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// transferSlots acquires slots of the daemon's transfer limiter for the replication steps of an active job.
+type transferSlots struct {
+	limiter *transferlimit.Limiter
+	mode    activeMode
+	remote  string
+}
+
+func (s transferSlots) AcquireSlot(ctx context.Context, fs string) (release func(), err error) {
+	return s.limiter.Acquire(ctx, s.mode.LocalPools(fs), s.remote)
+}
+
+// connect is nil for local jobs
+func activeSide(g *config.Global, in *config.ActiveJob, connect *config.ConnectEnum, mode activeMode) (j *ActiveSide, err error) {
 
 	j = &ActiveSide{mode: mode}
 	j.name = in.Name
@@ -185,9 +375,26 @@ func activeSide(g *config.Global, in *config.ActiveJob, mode activeMode) (j *Act
 		ConstLabels: prometheus.Labels{"zrepl_job":j.name},
 	}, []string{"filesystem"})
 
-	j.clientFactory, err = connecter.FromConfig(g, in.Connect)
+	if connect != nil {
+		j.clientFactory, err = connecter.FromConfig(g, *connect)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot build client")
+		}
+		j.remote = remoteName(connect)
+	}
+
+	j.replicationOptions, err = replicationOptionsFromConfig(in.Replication)
 	if err != nil {
-		return nil, errors.Wrap(err, "cannot build client")
+		return nil, err
+	}
+	j.replicationOptions.SendFeatures = mode.SendFeatures()
+	if in.Replication.Windows != nil {
+		if j.windows, err = replicationWindowsFromConfig(in.Replication.Windows); err != nil {
+			return nil, errors.Wrap(err, "invalid replication windows")
+		}
+	}
+	if j.bandwidth, err = bandwidth.FromConfig(in.BandwidthLimit); err != nil {
+		return nil, errors.Wrap(err, "invalid bandwidth limit")
 	}
 
 	j.promPruneSecs = prometheus.NewHistogramVec(prometheus.HistogramOpts{
@@ -197,7 +404,8 @@ func activeSide(g *config.Global, in *config.ActiveJob, mode activeMode) (j *Act
 		Help:        "seconds spent in pruner",
 		ConstLabels: prometheus.Labels{"zrepl_job":j.name},
 	}, []string{"prune_side"})
-	j.prunerFactory, err = pruner.NewPrunerFactory(in.Pruning, j.promPruneSecs)
+	j.promPruneSpace = pruneSpaceMetrics(j.name)
+	j.prunerFactory, err = pruner.NewPrunerFactory(in.Pruning, j.promPruneSecs, j.promPruneSpace)
 	if err != nil {
 		return nil, err
 	}
@@ -208,21 +416,43 @@ func activeSide(g *config.Global, in *config.ActiveJob, mode activeMode) (j *Act
 func (j *ActiveSide) RegisterMetrics(registerer prometheus.Registerer) {
 	registerer.MustRegister(j.promRepStateSecs)
 	registerer.MustRegister(j.promPruneSecs)
+	registerer.MustRegister(j.promPruneSpace.DestroyedSnapshots, j.promPruneSpace.FreedBytes)
 	registerer.MustRegister(j.promBytesReplicated)
 }
 
 func (j *ActiveSide) Name() string { return j.name }
 
 type ActiveSideStatus struct {
+	Snapshotting *snapper.Report // nil for pull jobs and manual snapshotting
+	ReplicationWindow *ReplicationWindowStatus // nil if replication is allowed at any time
 	Replication *replication.Report
 	PruningSender, PruningReceiver *pruner.Report
 }
 
+type ReplicationWindowStatus struct {
+	Open bool
+	// valid if Open: the window closes at ClosesAt, zero if it never closes
+	ClosesAt time.Time
+	// valid if !Open: the next window opens at NextOpen, zero if no window ever opens
+	NextOpen time.Time
+	// an invocation is waiting for the window to open
+	Deferred bool
+	// the current invocation is cancelled when the window closes
+	CancelOnClose bool
+}
+
 func (j *ActiveSide) Status() *Status {
 	tasks := j.updateTasks(nil)
 
 	s := &ActiveSideStatus{}
 	t := j.mode.Type()
+	if snapper := j.snapper(); snapper != nil {
+		s.Snapshotting = snapper.Report()
+	}
+	if j.windows != nil {
+		s.ReplicationWindow = j.windows.status(time.Now())
+		s.ReplicationWindow.Deferred = tasks.deferred
+	}
 	if tasks.replication != nil {
 		s.Replication = tasks.replication.Report()
 	}
@@ -235,6 +465,53 @@ func (j *ActiveSide) Status() *Status {
 	return &Status{Type: t, JobSpecific: s}
 }
 
+// snapper returns nil if the job does not take snapshots
+func (j *ActiveSide) snapper() *snapper.PeriodicOrManual {
+	switch m := j.mode.(type) {
+	case *modePush:
+		return m.snapper
+	case *modeLocal:
+		return m.snapper
+	default:
+		return nil
+	}
+}
+
+func (j *ActiveSide) SnapshotNow(ctx context.Context, req *snapper.ManualRequest) ([]*snapper.ManualResult, error) {
+	snapper := j.snapper()
+	if snapper == nil {
+		return nil, errors.Errorf("%s job does not take snapshots", j.mode.Type())
+	}
+	return snapper.SnapshotNow(ctx, req)
+}
+
+// PruneDryRun plans the pruning of both sides like an invocation of the job would after replication.
+func (j *ActiveSide) PruneDryRun(ctx context.Context) (map[string]*pruner.Report, error) {
+	var client *streamrpc.Client
+	if j.clientFactory != nil {
+		var err error
+		client, err = j.clientFactory.NewClient()
+		if err != nil {
+			return nil, errors.Wrap(err, "factory cannot instantiate streamrpc client")
+		}
+		defer client.Close(ctx)
+	}
+
+	sender, receiver, err := j.mode.SenderReceiver(client)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot build sender and receiver")
+	}
+
+	prunerSender := j.prunerFactory.BuildSenderPruner(ctx, sender, sender)
+	prunerSender.DryRun()
+	prunerReceiver := j.prunerFactory.BuildReceiverPruner(ctx, receiver, sender)
+	prunerReceiver.DryRun()
+	return map[string]*pruner.Report{
+		"sender":   prunerSender.Report(),
+		"receiver": prunerReceiver.Report(),
+	}, nil
+}
+
 func (j *ActiveSide) Run(ctx context.Context) {
 	log := GetLogger(ctx)
 	ctx = logging.WithSubsystemLoggers(ctx, log)
@@ -249,21 +526,87 @@ func (j *ActiveSide) Run(ctx context.Context) {
 	invocationCount := 0
 outer:
 	for {
+		select {
+		case <-stop.Wait(ctx):
+			log.Info("stop requested")
+			break outer
+		default:
+		}
+
 		log.Info("wait for wakeups")
 		select {
 		case <-ctx.Done():
 			log.WithError(ctx.Err()).Info("context")
 			break outer
 
+		case <-stop.Wait(ctx):
+			log.Info("stop requested")
+			break outer
+
 		case <-wakeup.Wait(ctx):
 		case <-periodicDone:
 		}
+		if !j.waitForWindow(ctx, periodicDone) {
+			break outer
+		}
+		if pause.Paused(ctx) {
+			log.Info("job is paused, skipping invocation")
+			continue
+		}
 		invocationCount++
 		invLog := log.WithField("invocation", invocationCount)
 		j.do(WithLogger(ctx, invLog))
 	}
 }
 
+// waitForWindow defers an invocation until the replication window opens.
+// Wakeups while the invocation is deferred are dropped.
+// Returns false if the job should exit instead.
+func (j *ActiveSide) waitForWindow(ctx context.Context, periodicDone <-chan struct{}) bool {
+	if j.windows == nil {
+		return true
+	}
+	log := GetLogger(ctx)
+	defer j.updateTasks(func(tasks *activeSideTasks) {
+		tasks.deferred = false
+	})
+	for {
+		now := time.Now()
+		if j.windows.windows.Contains(now) {
+			return true
+		}
+		j.updateTasks(func(tasks *activeSideTasks) {
+			tasks.deferred = true
+		})
+
+		sleep := time.Duration(math.MaxInt64) // never fires if no window ever opens
+		next := j.windows.windows.NextOpen(now)
+		if next.IsZero() {
+			log.Warn("replication windows never open, deferring invocation indefinitely")
+		} else {
+			log.WithField("next_window", next).Info("outside replication window, deferring invocation")
+			sleep = next.Sub(now)
+		}
+		t := time.NewTimer(sleep)
+
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			log.WithError(ctx.Err()).Info("context")
+			return false
+		case <-stop.Wait(ctx):
+			t.Stop()
+			log.Info("stop requested")
+			return false
+		case <-wakeup.Wait(ctx):
+			log.Info("invocation is already deferred until the replication window opens")
+		case <-periodicDone:
+		case <-t.C:
+		}
+		t.Stop()
+	}
+}
+
 func (j *ActiveSide) do(ctx context.Context) {
 
 	log := GetLogger(ctx)
@@ -280,6 +623,22 @@ func (j *ActiveSide) do(ctx context.Context) {
 		case <-ctx.Done():
 		}
 	}()
+	if j.windows != nil && j.windows.cancelOnClose {
+		go func() {
+			closesAt := j.windows.windows.NextClose(time.Now())
+			if closesAt.IsZero() {
+				return
+			}
+			t := time.NewTimer(closesAt.Sub(time.Now()))
+			defer t.Stop()
+			select {
+			case <-t.C:
+				log.Warn("replication window closed, cancelling current invocation")
+				cancelThisRun()
+			case <-ctx.Done():
+			}
+		}()
+	}
 
 	// The code after this watchdog goroutine is sequential and transitions the state from
 	//   ActiveSideReplicating -> ActiveSidePruneSender -> ActiveSidePruneReceiver -> ActiveSideDone
@@ -353,13 +712,29 @@ func (j *ActiveSide) do(ctx context.Context) {
 		}
 	}()
 
-	client, err := j.clientFactory.NewClient()
-	if err != nil {
-		log.WithError(err).Error("factory cannot instantiate streamrpc client")
+	var client *streamrpc.Client
+	if j.clientFactory != nil {
+		var err error
+		client, err = j.clientFactory.NewClient()
+		if err != nil {
+			log.WithError(err).Error("factory cannot instantiate streamrpc client")
+		}
+		defer client.Close(ctx)
 	}
-	defer client.Close(ctx)
 
 	sender, receiver, err := j.mode.SenderReceiver(client)
+	if err != nil {
+		log.WithError(err).Error("cannot build sender and receiver")
+		return
+	}
+	switch s := sender.(type) {
+	case *endpoint.Sender:
+		s.LimitBandwidth(bandwidth.Global(ctx), j.bandwidth)
+	case endpoint.Remote:
+		// the passive side applies its own limits to the send streams, ours are applied while receiving
+		// so that the buckets are shared by concurrent streams and follow time-of-day profiles
+		sender = s.LimitBandwidth(bandwidth.Global(ctx), j.bandwidth)
+	}
 
 	{
 		select {
@@ -368,11 +743,15 @@ func (j *ActiveSide) do(ctx context.Context) {
 		default:
 		}
 		ctx, repCancel := context.WithCancel(ctx)
+		opts := j.replicationOptions
+		if limiter := transferlimit.FromContext(ctx); limiter != nil {
+			opts.Slots = transferSlots{limiter: limiter, mode: j.mode, remote: j.remote}
+		}
 		tasks := j.updateTasks(func(tasks *activeSideTasks) {
 			// reset it
 			*tasks = activeSideTasks{}
 			tasks.replicationCancel = repCancel
-			tasks.replication = replication.NewReplication(j.promRepStateSecs, j.promBytesReplicated)
+			tasks.replication = replication.NewReplication(j.promRepStateSecs, j.promBytesReplicated, opts)
 			tasks.state = ActiveSideReplicating
 		})
 		log.Info("start replication")
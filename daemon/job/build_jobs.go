@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/replication"
+	"github.com/zrepl/zrepl/replication/fsrep"
+	"github.com/zrepl/zrepl/zfs"
 )
 
 func JobsFromConfig(c *config.Config) ([]Job, error) {
@@ -50,7 +53,7 @@ func buildJob(c *config.Global, in config.JobEnum) (j Job, err error) {
 		if err != nil {
 			return cannotBuildJob(err, v.Name)
 		}
-		j, err = activeSide(c, &v.ActiveJob, m)
+		j, err = activeSide(c, &v.ActiveJob, &v.Connect, m)
 		if err != nil {
 			return cannotBuildJob(err, v.Name)
 		}
@@ -59,7 +62,21 @@ func buildJob(c *config.Global, in config.JobEnum) (j Job, err error) {
 		if err != nil {
 			return cannotBuildJob(err, v.Name)
 		}
-		j, err = activeSide(c, &v.ActiveJob, m)
+		j, err = activeSide(c, &v.ActiveJob, &v.Connect, m)
+		if err != nil {
+			return cannotBuildJob(err, v.Name)
+		}
+	case *config.LocalJob:
+		m, err := modeLocalFromConfig(c, v)
+		if err != nil {
+			return cannotBuildJob(err, v.Name)
+		}
+		j, err = activeSide(c, &v.ActiveJob, nil, m)
+		if err != nil {
+			return cannotBuildJob(err, v.Name)
+		}
+	case *config.SnapJob:
+		j, err = snapJobFromConfig(c, v)
 		if err != nil {
 			return cannotBuildJob(err, v.Name)
 		}
@@ -69,3 +86,99 @@ func buildJob(c *config.Global, in config.JobEnum) (j Job, err error) {
 	return j, nil
 
 }
+
+func sendFlagsFromConfig(in *config.SendOptions) zfs.ZFSSendFlags {
+	return zfs.ZFSSendFlags{
+		Compressed:   in.Compressed,
+		LargeBlocks:  in.LargeBlocks,
+		EmbeddedData: in.EmbeddedData,
+		Raw:          in.Raw,
+		Properties:   in.SendProperties,
+	}
+}
+
+// sendFeaturesFromFlags returns the stream features that a job using flags requires from the sender.
+func sendFeaturesFromFlags(flags zfs.ZFSSendFlags) fsrep.SendFeatures {
+	return fsrep.SendFeatures{
+		Compress:     flags.Compressed,
+		LargeBlocks:  flags.LargeBlocks,
+		EmbeddedData: flags.EmbeddedData,
+		Raw:          flags.Raw,
+		Properties:   flags.Properties,
+	}
+}
+
+// holdTagFromConfig returns the tag of the holds that job places on replicated snapshots,
+// empty if it does not hold them.
+func holdTagFromConfig(job string, in *config.SendOptions) string {
+	if !in.HoldReplicated {
+		return ""
+	}
+	return zfs.ReplicationHoldTag(job)
+}
+
+// bookmarkJobFromConfig returns the job name for endpoint.Sender.BookmarkReplicated,
+// empty if job does not bookmark replicated snapshots.
+func bookmarkJobFromConfig(job string, in *config.SendOptions) string {
+	if !in.BookmarkReplicated {
+		return ""
+	}
+	return job
+}
+
+func recvPropertiesFromConfig(in *config.RecvOptions) (zfs.ZFSRecvProperties, error) {
+	p := zfs.ZFSRecvProperties{
+		Override: in.Properties.Override,
+		Exclude:  in.Properties.Exclude,
+	}
+	if err := p.Validate(); err != nil {
+		return zfs.ZFSRecvProperties{}, errors.Wrap(err, "invalid recv properties")
+	}
+	return p, nil
+}
+
+func replicationOptionsFromConfig(in *config.ReplicationOptions) (replication.Options, error) {
+	o := replication.Options{Concurrency: in.Concurrency}
+	if o.Concurrency < 1 {
+		return replication.Options{}, errors.New("replication concurrency must be positive")
+	}
+
+	cr := in.ConflictResolution
+	switch cr.InitialReplication {
+	case "most_recent":
+	case "all":
+		o.ConflictResolution.InitialReplicationAll = true
+	default:
+		return replication.Options{}, errors.Errorf("unknown initial_replication policy %q", cr.InitialReplication)
+	}
+	switch cr.Diverged {
+	case "fail":
+	case "rollback":
+		o.ConflictResolution.RollbackDiverged = true
+	default:
+		return replication.Options{}, errors.Errorf("unknown diverged policy %q", cr.Diverged)
+	}
+	if cr.RollbackMaxDestroy < 0 {
+		return replication.Options{}, errors.New("rollback_max_destroy must not be negative")
+	}
+	o.ConflictResolution.RollbackMaxDestroy = cr.RollbackMaxDestroy
+	o.ConflictResolution.RollbackDryRun = cr.RollbackDryRun
+
+	rt := in.Retry
+	if rt.MaxInterval < rt.InitialInterval {
+		return replication.Options{}, errors.New("retry max_interval must not be shorter than initial_interval")
+	}
+	if rt.MaxAttempts < 0 || rt.MaxDuration < 0 {
+		return replication.Options{}, errors.New("retry max_attempts and max_duration must not be negative")
+	}
+	o.Retry = replication.RetryPolicy{
+		InitialInterval: rt.InitialInterval,
+		MaxInterval:     rt.MaxInterval,
+		Budget: fsrep.RetryBudget{
+			MaxAttempts: rt.MaxAttempts,
+			MaxDuration: rt.MaxDuration,
+		},
+	}
+
+	return o, nil
+}
@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zrepl/zrepl/daemon/job/pause"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/daemon/snapper"
 	"github.com/zrepl/zrepl/logger"
 )
 
@@ -35,6 +38,17 @@ type Job interface {
 	RegisterMetrics(registerer prometheus.Registerer)
 }
 
+// A Snapshotter is a Job that takes snapshots on request (zrepl snapshot JOB).
+type Snapshotter interface {
+	SnapshotNow(ctx context.Context, req *snapper.ManualRequest) ([]*snapper.ManualResult, error)
+}
+
+// A PruneDryRunner is a Job that plans its pruning without destroying anything (zrepl prune --dry-run JOB).
+type PruneDryRunner interface {
+	// PruneDryRun returns the plan of each side that the job prunes, by prune side (sender, receiver or local).
+	PruneDryRun(ctx context.Context) (map[string]*pruner.Report, error)
+}
+
 type Type string
 
 const (
@@ -43,11 +57,15 @@ const (
 	TypeSink Type = "sink"
 	TypePull Type  = "pull"
 	TypeSource Type = "source"
+	TypeSnap Type = "snap"
+	TypeLocal Type = "local"
 )
 
 type Status struct {
 	Type Type
 	JobSpecific interface{}
+	// set by the daemon, nil if the job is not paused
+	Paused *pause.Status
 }
 
 func (s *Status) MarshalJSON() ([]byte, error) {
@@ -63,6 +81,11 @@ func (s *Status) MarshalJSON() ([]byte, error) {
 		"type": typeJson,
 		string(s.Type): jobJSON,
 	}
+	if s.Paused != nil {
+		if m["paused"], err = json.Marshal(s.Paused); err != nil {
+			return nil, err
+		}
+	}
 	return json.Marshal(m)
 }
 
@@ -78,6 +101,12 @@ func (s *Status) UnmarshalJSON(in []byte) (err error) {
 	if err := json.Unmarshal(tJSON, &s.Type); err != nil {
 		return err
 	}
+	if pJSON, ok := m["paused"]; ok {
+		s.Paused = &pause.Status{}
+		if err := json.Unmarshal(pJSON, s.Paused); err != nil {
+			return err
+		}
+	}
 	key := string(s.Type)
 	jobJSON, ok := m[key]
 	if !ok {
@@ -85,6 +114,7 @@ func (s *Status) UnmarshalJSON(in []byte) (err error) {
 	}
 	switch s.Type {
 	case TypePull: fallthrough
+	case TypeLocal: fallthrough
 	case TypePush:
 		var st ActiveSideStatus
 		err = json.Unmarshal(jobJSON, &st)
@@ -94,6 +124,10 @@ func (s *Status) UnmarshalJSON(in []byte) (err error) {
 		var st PassiveStatus
 		err = json.Unmarshal(jobJSON, &st)
 		s.JobSpecific = &st
+	case TypeSnap:
+		var st SnapJobStatus
+		err = json.Unmarshal(jobJSON, &st)
+		s.JobSpecific = &st
 	case TypeInternal:
 		// internal jobs do not report specifics
 	default:
@@ -101,3 +135,22 @@ func (s *Status) UnmarshalJSON(in []byte) (err error) {
 	}
 	return err
 }
+
+func pruneSpaceMetrics(job string) pruner.SpaceMetrics {
+	return pruner.SpaceMetrics{
+		DestroyedSnapshots: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "zrepl",
+			Subsystem:   "pruning",
+			Name:        "space_destroyed_snapshots",
+			Help:        "number of snapshots kept by the keep rules but destroyed because the space thresholds were exceeded",
+			ConstLabels: prometheus.Labels{"zrepl_job": job},
+		}, []string{"prune_side"}),
+		FreedBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "zrepl",
+			Subsystem:   "pruning",
+			Name:        "space_freed_bytes",
+			Help:        "estimated number of bytes freed by destroying snapshots because the space thresholds were exceeded",
+			ConstLabels: prometheus.Labels{"zrepl_job": job},
+		}, []string{"prune_side"}),
+	}
+}
@@ -6,13 +6,18 @@ import (
 	"github.com/problame/go-streamrpc"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/bandwidth"
 	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/daemon/job/pause"
+	"github.com/zrepl/zrepl/daemon/job/stop"
 	"github.com/zrepl/zrepl/daemon/logging"
 	"github.com/zrepl/zrepl/daemon/transport/serve"
 	"github.com/zrepl/zrepl/daemon/snapper"
 	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/util/ratelimit"
 	"github.com/zrepl/zrepl/zfs"
 	"path"
+	"sync"
 )
 
 type PassiveSide struct {
@@ -30,6 +35,7 @@ type passiveMode interface {
 
 type modeSink struct {
 	rootDataset *zfs.DatasetPath
+	recvProps   zfs.ZFSRecvProperties
 }
 
 func (m *modeSink) Type() Type { return TypeSink }
@@ -46,7 +52,7 @@ func (m *modeSink) ConnHandleFunc(ctx context.Context, conn serve.AuthenticatedC
 	}
 	log.WithField("client_root", clientRoot).Debug("client root")
 
-	local, err := endpoint.NewReceiver(clientRoot)
+	local, err := endpoint.NewReceiver(clientRoot, m.recvProps)
 	if err != nil {
 		log.WithError(err).Error("unexpected error: cannot convert mapping to filter")
 		return nil
@@ -67,12 +73,19 @@ func modeSinkFromConfig(g *config.Global, in *config.SinkJob) (m *modeSink, err
 	if m.rootDataset.Length() <= 0 {
 		return nil, errors.New("root dataset must not be empty") // duplicates error check of receiver
 	}
+	if m.recvProps, err = recvPropertiesFromConfig(in.Recv); err != nil {
+		return nil, err
+	}
 	return m, nil
 }
 
 type modeSource struct {
 	fsfilter zfs.DatasetFilter
+	sendFlags zfs.ZFSSendFlags
+	holdTag string
+	bookmarkJob string
 	snapper *snapper.PeriodicOrManual
+	bandwidth *ratelimit.Bucket // nil if the job's send streams are not limited
 }
 
 func modeSourceFromConfig(g *config.Global, in *config.SourceJob) (m *modeSource, err error) {
@@ -83,18 +96,28 @@ func modeSourceFromConfig(g *config.Global, in *config.SourceJob) (m *modeSource
 		return nil, errors.Wrap(err, "cannnot build filesystem filter")
 	}
 	m.fsfilter = fsf
+	m.sendFlags = sendFlagsFromConfig(in.Send)
+	m.holdTag = holdTagFromConfig(in.Name, in.Send)
+	m.bookmarkJob = bookmarkJobFromConfig(in.Name, in.Send)
 
 	if m.snapper, err = snapper.FromConfig(g, fsf, in.Snapshotting); err != nil {
 		return nil, errors.Wrap(err, "cannot build snapper")
 	}
 
+	if m.bandwidth, err = bandwidth.FromConfig(in.BandwidthLimit); err != nil {
+		return nil, errors.Wrap(err, "invalid bandwidth limit")
+	}
+
 	return m, nil
 }
 
 func (m *modeSource) Type() Type { return TypeSource }
 
 func (m *modeSource) ConnHandleFunc(ctx context.Context, conn serve.AuthenticatedConn) streamrpc.HandlerFunc {
-	sender := endpoint.NewSender(m.fsfilter)
+	sender := endpoint.NewSender(m.fsfilter, m.sendFlags)
+	sender.LimitBandwidth(bandwidth.Global(ctx), m.bandwidth)
+	sender.HoldReplicated(m.holdTag)
+	sender.BookmarkReplicated(m.bookmarkJob)
 	h := endpoint.NewHandler(sender)
 	return h.Handle
 }
@@ -115,10 +138,24 @@ func passiveSideFromConfig(g *config.Global, in *config.PassiveJob, mode passive
 
 func (j *PassiveSide) Name() string { return j.name }
 
-type PassiveStatus struct {}
+type PassiveStatus struct {
+	Snapshotting *snapper.Report // nil for sink jobs and manual snapshotting
+}
 
 func (s *PassiveSide) Status() *Status {
-	return &Status{Type: s.mode.Type()} // FIXME PassiveStatus
+	st := &PassiveStatus{}
+	if source, ok := s.mode.(*modeSource); ok {
+		st.Snapshotting = source.snapper.Report()
+	}
+	return &Status{Type: s.mode.Type(), JobSpecific: st}
+}
+
+func (j *PassiveSide) SnapshotNow(ctx context.Context, req *snapper.ManualRequest) ([]*snapper.ManualResult, error) {
+	source, ok := j.mode.(*modeSource)
+	if !ok {
+		return nil, errors.Errorf("%s job does not take snapshots", j.mode.Type())
+	}
+	return source.snapper.SnapshotNow(ctx, req)
 }
 
 func (*PassiveSide) RegisterMetrics(registerer prometheus.Registerer) {}
@@ -143,6 +180,7 @@ func (j *PassiveSide) Run(ctx context.Context) {
 
 	log.WithField("addr", l.Addr()).Debug("accepting connections")
 	var connId int
+	var handlers sync.WaitGroup
 outer:
 	for {
 
@@ -156,11 +194,21 @@ outer:
 			connId++
 			connLog := log.
 				WithField("connID", connId)
+			if pause.Paused(ctx) {
+				connLog.
+					WithField("addr", conn.RemoteAddr()).
+					WithField("client_identity", conn.ClientIdentity()).
+					Info("job is paused, closing connection")
+				conn.Close()
+				continue
+			}
 			connLog.
 				WithField("addr", conn.RemoteAddr()).
 				WithField("client_identity", conn.ClientIdentity()).
 				Info("handling connection")
+			handlers.Add(1)
 			go func() {
+				defer handlers.Done()
 				defer connLog.Info("finished handling connection")
 				defer conn.Close()
 				ctx := logging.WithSubsystemLoggers(ctx, connLog)
@@ -173,6 +221,12 @@ outer:
 				}
 			}()
 
+		case <-stop.Wait(ctx):
+			log.Info("stop requested, waiting for connections to finish")
+			l.Close()
+			handlers.Wait()
+			break outer
+
 		case <-ctx.Done():
 			break outer
 		}
@@ -0,0 +1,73 @@
+// Package pause implements a signal that keeps a job from starting new snapshot, replication and prune cycles.
+package pause
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Status describes whether a job is paused.
+type Status struct {
+	Paused bool
+	// The pause expires at Until, zero if it lasts until the job is resumed.
+	Until time.Time
+}
+
+// expired reports whether a pause with the given Until has expired at now.
+func (s Status) expired(now time.Time) bool {
+	return !s.Until.IsZero() && !now.Before(s.Until)
+}
+
+// State is the pause state of a single job.
+// It is safe for concurrent use.
+type State struct {
+	mtx    sync.Mutex
+	status Status
+}
+
+// NewState returns a State initialized to s, e.g. a pause that was persisted before the daemon restarted.
+func NewState(s Status) *State {
+	return &State{status: s}
+}
+
+// Pause pauses the job until until or, if until is zero, until Resume is called.
+func (s *State) Pause(until time.Time) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.status = Status{Paused: true, Until: until}
+}
+
+func (s *State) Resume() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.status = Status{}
+}
+
+// Status returns the current status, an expired pause is reported as not paused.
+func (s *State) Status() Status {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.status.Paused && s.status.expired(time.Now()) {
+		s.status = Status{}
+	}
+	return s.status
+}
+
+type contextKey int
+
+const contextKeyPause contextKey = iota
+
+func Context(ctx context.Context, s *State) context.Context {
+	return context.WithValue(ctx, contextKeyPause, s)
+}
+
+// Paused reports whether the job that ctx belongs to is paused.
+// A job that has no pause state in ctx is never paused.
+func Paused(ctx context.Context) bool {
+	s, ok := ctx.Value(contextKeyPause).(*State)
+	if !ok {
+		return false
+	}
+	return s.Status().Paused
+}
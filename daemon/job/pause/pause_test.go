@@ -0,0 +1,35 @@
+package pause
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaused(t *testing.T) {
+	assert.False(t, Paused(context.Background()))
+
+	s := NewState(Status{})
+	ctx := Context(context.Background(), s)
+	assert.False(t, Paused(ctx))
+
+	s.Pause(time.Time{})
+	assert.True(t, Paused(ctx))
+	assert.Equal(t, Status{Paused: true}, s.Status())
+
+	s.Resume()
+	assert.False(t, Paused(ctx))
+}
+
+func TestPausedExpires(t *testing.T) {
+	s := NewState(Status{Paused: true, Until: time.Now().Add(-time.Second)})
+	assert.False(t, Paused(Context(context.Background(), s)))
+	assert.Equal(t, Status{}, s.Status())
+
+	until := time.Now().Add(time.Hour)
+	s.Pause(until)
+	assert.True(t, Paused(Context(context.Background(), s)))
+	assert.Equal(t, until, s.Status().Until)
+}
@@ -0,0 +1,202 @@
+package job
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/daemon/job/pause"
+	"github.com/zrepl/zrepl/daemon/job/reset"
+	"github.com/zrepl/zrepl/daemon/job/stop"
+	"github.com/zrepl/zrepl/daemon/job/wakeup"
+	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/replication/pdu"
+	"github.com/zrepl/zrepl/zfs"
+	"sync"
+)
+
+// SnapJob takes snapshots and prunes them locally, without replication.
+type SnapJob struct {
+	name     string
+	fsfilter zfs.DatasetFilter
+	snapper  *snapper.PeriodicOrManual
+
+	prunerFactory *pruner.LocalPrunerFactory
+
+	promPruneSecs  *prometheus.HistogramVec // labels: prune_side
+	promPruneSpace pruner.SpaceMetrics      // labels: prune_side
+
+	prunerMtx sync.Mutex
+	pruner    *pruner.Pruner
+}
+
+func snapJobFromConfig(g *config.Global, in *config.SnapJob) (j *SnapJob, err error) {
+	j = &SnapJob{name: in.Name}
+	fsf, err := filters.DatasetMapFilterFromConfig(in.Filesystems)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannnot build filesystem filter")
+	}
+	j.fsfilter = fsf
+
+	if j.snapper, err = snapper.FromConfig(g, fsf, in.Snapshotting); err != nil {
+		return nil, errors.Wrap(err, "cannot build snapper")
+	}
+
+	j.promPruneSecs = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   "zrepl",
+		Subsystem:   "pruning",
+		Name:        "time",
+		Help:        "seconds spent in pruner",
+		ConstLabels: prometheus.Labels{"zrepl_job": j.name},
+	}, []string{"prune_side"})
+	j.promPruneSpace = pruneSpaceMetrics(j.name)
+	j.prunerFactory, err = pruner.NewLocalPrunerFactory(in.Pruning, j.promPruneSecs, j.promPruneSpace)
+	if err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+func (j *SnapJob) Name() string { return j.name }
+
+func (j *SnapJob) RegisterMetrics(registerer prometheus.Registerer) {
+	registerer.MustRegister(j.promPruneSecs)
+	registerer.MustRegister(j.promPruneSpace.DestroyedSnapshots, j.promPruneSpace.FreedBytes)
+}
+
+type SnapJobStatus struct {
+	Snapshotting *snapper.Report // nil for manual snapshotting
+	Pruning      *pruner.Report  // nil until the first pruning run
+}
+
+func (j *SnapJob) Status() *Status {
+	s := &SnapJobStatus{}
+	s.Snapshotting = j.snapper.Report()
+	j.prunerMtx.Lock()
+	if j.pruner != nil {
+		s.Pruning = j.pruner.Report()
+	}
+	j.prunerMtx.Unlock()
+	return &Status{Type: TypeSnap, JobSpecific: s}
+}
+
+func (j *SnapJob) SnapshotNow(ctx context.Context, req *snapper.ManualRequest) ([]*snapper.ManualResult, error) {
+	return j.snapper.SnapshotNow(ctx, req)
+}
+
+func (j *SnapJob) Run(ctx context.Context) {
+	log := GetLogger(ctx)
+	ctx = logging.WithSubsystemLoggers(ctx, log)
+
+	defer log.Info("job exiting")
+
+	periodicDone := make(chan struct{})
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go j.snapper.Run(ctx, periodicDone)
+
+	invocationCount := 0
+outer:
+	for {
+		select {
+		case <-stop.Wait(ctx):
+			log.Info("stop requested")
+			break outer
+		default:
+		}
+
+		log.Info("wait for wakeups")
+		select {
+		case <-ctx.Done():
+			log.WithError(ctx.Err()).Info("context")
+			break outer
+
+		case <-stop.Wait(ctx):
+			log.Info("stop requested")
+			break outer
+
+		case <-wakeup.Wait(ctx):
+		case <-periodicDone:
+		}
+		if pause.Paused(ctx) {
+			log.Info("job is paused, skipping invocation")
+			continue
+		}
+		invocationCount++
+		invLog := log.WithField("invocation", invocationCount)
+		j.doPrune(WithLogger(ctx, invLog))
+	}
+}
+
+func (j *SnapJob) doPrune(ctx context.Context) {
+	log := GetLogger(ctx)
+	ctx = logging.WithSubsystemLoggers(ctx, log)
+
+	// allow cancellation of an invocation (this function)
+	ctx, cancelThisRun := context.WithCancel(ctx)
+	defer cancelThisRun()
+	go func() {
+		select {
+		case <-reset.Wait(ctx):
+			log.Info("reset received, cancelling current invocation")
+			cancelThisRun()
+		case <-ctx.Done():
+		}
+	}()
+
+	sender := endpoint.NewSender(j.fsfilter, zfs.ZFSSendFlags{})
+	p := j.prunerFactory.BuildLocalPruner(ctx, sender, alwaysUpToDateReplicationCursorHistory{sender})
+	j.prunerMtx.Lock()
+	j.pruner = p
+	j.prunerMtx.Unlock()
+
+	log.Info("start pruning")
+	p.Prune()
+	log.Info("finished pruning")
+}
+
+// PruneDryRun plans the pruning like an invocation of the job would after snapshotting.
+func (j *SnapJob) PruneDryRun(ctx context.Context) (map[string]*pruner.Report, error) {
+	sender := endpoint.NewSender(j.fsfilter, zfs.ZFSSendFlags{})
+	p := j.prunerFactory.BuildLocalPruner(ctx, sender, alwaysUpToDateReplicationCursorHistory{sender})
+	p.DryRun()
+	return map[string]*pruner.Report{"local": p.Report()}, nil
+}
+
+// alwaysUpToDateReplicationCursorHistory reports the most recent snapshot of a filesystem as its replication cursor.
+// Since the local pruner does not support the not_replicated keep rule, this only keeps the pruner from
+// treating snapshots of a job without replication as not (yet) replicated.
+type alwaysUpToDateReplicationCursorHistory struct {
+	target pruner.Target
+}
+
+var _ pruner.History = (*alwaysUpToDateReplicationCursorHistory)(nil)
+
+func (h alwaysUpToDateReplicationCursorHistory) ReplicationCursor(ctx context.Context, req *pdu.ReplicationCursorReq) (*pdu.ReplicationCursorRes, error) {
+	if req.GetGet() == nil {
+		return nil, errors.New("local pruning does not support setting the replication cursor")
+	}
+	fsvs, err := h.target.ListFilesystemVersions(ctx, req.GetFilesystem())
+	if err != nil {
+		return nil, err
+	}
+	var mostRecent *pdu.FilesystemVersion
+	for _, fsv := range fsvs {
+		if fsv.Type != pdu.FilesystemVersion_Snapshot {
+			continue
+		}
+		if mostRecent == nil || fsv.CreateTXG > mostRecent.CreateTXG {
+			mostRecent = fsv
+		}
+	}
+	if mostRecent == nil {
+		// no snapshots, nothing to prune
+		return &pdu.ReplicationCursorRes{Result: &pdu.ReplicationCursorRes_Guid{Guid: 0}}, nil
+	}
+	return &pdu.ReplicationCursorRes{Result: &pdu.ReplicationCursorRes_Guid{Guid: mostRecent.Guid}}, nil
+}
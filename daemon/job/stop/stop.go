@@ -0,0 +1,31 @@
+// Package stop implements a signal that asks a job to exit once its current invocation has finished.
+package stop
+
+import (
+	"context"
+	"sync"
+)
+
+type contextKey int
+
+const contextKeyStop contextKey = iota
+
+// Wait returns a channel that is closed when the job should exit after its current invocation.
+func Wait(ctx context.Context) <-chan struct{} {
+	wc, ok := ctx.Value(contextKeyStop).(chan struct{})
+	if !ok {
+		wc = make(chan struct{})
+	}
+	return wc
+}
+
+type Func func()
+
+func Context(ctx context.Context) (context.Context, Func) {
+	wc := make(chan struct{})
+	var once sync.Once
+	stf := func() {
+		once.Do(func() { close(wc) })
+	}
+	return context.WithValue(ctx, contextKeyStop, wc), stf
+}
@@ -11,6 +11,6 @@ var DaemonCmd = &cli.Subcommand {
 	Use:   "daemon",
 	Short: "run the zrepl daemon",
 	Run: func(subcommand *cli.Subcommand, args []string) error {
-		return Run(subcommand.Config())
+		return Run(subcommand.Config(), subcommand.ConfigPath())
 	},
 }
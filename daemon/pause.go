@@ -0,0 +1,74 @@
+package daemon
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"github.com/zrepl/zrepl/daemon/job/pause"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const pauseStateFile = "paused.json"
+
+// pauseStore persists the pause state of jobs in the control state directory
+// so that paused jobs stay paused across daemon restarts.
+type pauseStore struct {
+	dir string
+}
+
+func newPauseStore(stateDir string) *pauseStore {
+	return &pauseStore{dir: stateDir}
+}
+
+func (s *pauseStore) path() string { return filepath.Join(s.dir, pauseStateFile) }
+
+// load returns the pauses by job name that have not expired yet.
+// A missing state file is not an error.
+func (s *pauseStore) load() (map[string]pause.Status, error) {
+	m := make(map[string]pause.Status)
+	b, err := ioutil.ReadFile(s.path())
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "cannot read pause state")
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse pause state file %s", s.path())
+	}
+	now := time.Now()
+	for name, st := range m {
+		if !st.Paused || (!st.Until.IsZero() && !now.Before(st.Until)) {
+			delete(m, name)
+		}
+	}
+	return m, nil
+}
+
+// save atomically replaces the persisted pause state with m.
+func (s *pauseStore) save(m map[string]pause.Status) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return errors.Wrap(err, "cannot create state directory")
+	}
+	tmp, err := ioutil.TempFile(s.dir, pauseStateFile+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "cannot write pause state")
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot write pause state")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), s.path()), "cannot write pause state")
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/zrepl/zrepl/zfs"
 	"net"
 	"net/http"
+	"sync"
 )
 
 type prometheusJob struct {
@@ -87,3 +88,41 @@ func (o prometheusJobOutlet) WriteEntry(entry logger.Entry) error {
 	return nil
 }
 
+
+// jobRegisterer records the collectors registered by a job so that they can be unregistered when the job exits.
+type jobRegisterer struct {
+	prometheus.Registerer
+	mtx        sync.Mutex
+	collectors []prometheus.Collector
+}
+
+func newJobRegisterer(r prometheus.Registerer) *jobRegisterer {
+	return &jobRegisterer{Registerer: r}
+}
+
+func (r *jobRegisterer) Register(c prometheus.Collector) error {
+	if err := r.Registerer.Register(c); err != nil {
+		return err
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.collectors = append(r.collectors, c)
+	return nil
+}
+
+func (r *jobRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (r *jobRegisterer) unregisterAll() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for _, c := range r.collectors {
+		r.Registerer.Unregister(c)
+	}
+	r.collectors = nil
+}
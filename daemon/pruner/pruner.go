@@ -11,6 +11,7 @@ import (
 	"github.com/zrepl/zrepl/replication/pdu"
 	"github.com/zrepl/zrepl/util/envconst"
 	"github.com/zrepl/zrepl/util/watchdog"
+	"github.com/zrepl/zrepl/zfs"
 	"github.com/problame/go-streamrpc"
 	"net"
 	"sort"
@@ -52,9 +53,13 @@ type args struct {
 	target                         Target
 	receiver                       History
 	rules                          []pruning.KeepRule
+	bookmarkRules                  []pruning.KeepRule // replication bookmarks are kept if empty
 	retryWait                      time.Duration
 	considerSnapAtCursorReplicated bool
 	promPruneSecs prometheus.Observer
+	space                          *spacePolicy // nil if space-aware pruning is disabled
+	promSpaceDestroyed             prometheus.Counter
+	promSpaceFreed                 prometheus.Counter
 }
 
 type Pruner struct {
@@ -64,6 +69,9 @@ type Pruner struct {
 
 	mtx sync.RWMutex
 
+	// only plan, do not destroy anything and do not retry on errors
+	dryRun bool
+
 	state State
 
 	// State ErrWait|ErrPerm
@@ -76,10 +84,21 @@ type Pruner struct {
 
 type PrunerFactory struct {
 	senderRules                    []pruning.KeepRule
+	senderBookmarkRules            []pruning.KeepRule
 	receiverRules                  []pruning.KeepRule
 	retryWait                      time.Duration
 	considerSnapAtCursorReplicated bool
 	promPruneSecs *prometheus.HistogramVec
+	senderSpace, receiverSpace     *spacePolicy
+	promSpace                      SpaceMetrics
+}
+
+// SpaceMetrics are the metrics of space-aware pruning, labeled by prune_side.
+type SpaceMetrics struct {
+	// number of snapshots destroyed because the space thresholds were exceeded
+	DestroyedSnapshots *prometheus.CounterVec
+	// space freed by these destroys, as estimated by the space used by each snapshot alone
+	FreedBytes *prometheus.CounterVec
 }
 
 func checkContainsKeep1(rules []pruning.KeepRule) error {
@@ -95,7 +114,7 @@ func checkContainsKeep1(rules []pruning.KeepRule) error {
 	return errors.New("sender keep rules must contain last_n or be empty so that the last snapshot is definitely kept")
 }
 
-func NewPrunerFactory(in config.PruningSenderReceiver, promPruneSecs *prometheus.HistogramVec) (*PrunerFactory, error) {
+func NewPrunerFactory(in config.PruningSenderReceiver, promPruneSecs *prometheus.HistogramVec, promSpace SpaceMetrics) (*PrunerFactory, error) {
 	keepRulesReceiver, err := pruning.RulesFromConfig(in.KeepReceiver)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot build receiver pruning rules")
@@ -106,6 +125,26 @@ func NewPrunerFactory(in config.PruningSenderReceiver, promPruneSecs *prometheus
 		return nil, errors.Wrap(err, "cannot build sender pruning rules")
 	}
 
+	for _, r := range in.KeepSenderBookmarks {
+		if _, ok := r.Ret.(*config.PruneKeepNotReplicated); ok {
+			// bookmarks are only created for replicated snapshots
+			return nil, errors.New("keep rule not_replicated is not supported for sender bookmarks")
+		}
+	}
+	keepRulesSenderBookmarks, err := pruning.RulesFromConfig(in.KeepSenderBookmarks)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot build sender bookmark pruning rules")
+	}
+
+	senderSpace, err := spacePolicyFromConfig(in.SpaceSender)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid space_sender")
+	}
+	receiverSpace, err := spacePolicyFromConfig(in.SpaceReceiver)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid space_receiver")
+	}
+
 	considerSnapAtCursorReplicated := false
 	for _, r := range in.KeepSender {
 		knr, ok := r.Ret.(*config.PruneKeepNotReplicated)
@@ -116,10 +155,14 @@ func NewPrunerFactory(in config.PruningSenderReceiver, promPruneSecs *prometheus
 	}
 	f := &PrunerFactory{
 		senderRules: keepRulesSender,
+		senderBookmarkRules: keepRulesSenderBookmarks,
 		receiverRules: keepRulesReceiver,
 		retryWait: envconst.Duration("ZREPL_PRUNER_RETRY_INTERVAL", 10 * time.Second),
 		considerSnapAtCursorReplicated: considerSnapAtCursorReplicated,
 		promPruneSecs: promPruneSecs,
+		senderSpace: senderSpace,
+		receiverSpace: receiverSpace,
+		promSpace: promSpace,
 	}
 	return f, nil
 }
@@ -131,9 +174,13 @@ func (f *PrunerFactory) BuildSenderPruner(ctx context.Context, target Target, re
 			target,
 			receiver,
 			f.senderRules,
+			f.senderBookmarkRules,
 			f.retryWait,
 			f.considerSnapAtCursorReplicated,
 			f.promPruneSecs.WithLabelValues("sender"),
+			f.senderSpace,
+			f.promSpace.DestroyedSnapshots.WithLabelValues("sender"),
+			f.promSpace.FreedBytes.WithLabelValues("sender"),
 		},
 		state: Plan,
 	}
@@ -147,9 +194,68 @@ func (f *PrunerFactory) BuildReceiverPruner(ctx context.Context, target Target,
 			target,
 			receiver,
 			f.receiverRules,
+			nil,
 			f.retryWait,
 			false, // senseless here anyways
 			f.promPruneSecs.WithLabelValues("receiver"),
+			f.receiverSpace,
+			f.promSpace.DestroyedSnapshots.WithLabelValues("receiver"),
+			f.promSpace.FreedBytes.WithLabelValues("receiver"),
+		},
+		state: Plan,
+	}
+	return p
+}
+
+type LocalPrunerFactory struct {
+	keepRules     []pruning.KeepRule
+	retryWait     time.Duration
+	promPruneSecs *prometheus.HistogramVec
+	space         *spacePolicy
+	promSpace     SpaceMetrics
+}
+
+func NewLocalPrunerFactory(in config.PruningLocal, promPruneSecs *prometheus.HistogramVec, promSpace SpaceMetrics) (*LocalPrunerFactory, error) {
+	for _, r := range in.Keep {
+		if _, ok := r.Ret.(*config.PruneKeepNotReplicated); ok {
+			// there is no replication in jobs with local pruning
+			return nil, errors.New("keep rule not_replicated is not supported for local pruning")
+		}
+	}
+	rules, err := pruning.RulesFromConfig(in.Keep)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot build pruning rules")
+	}
+	space, err := spacePolicyFromConfig(in.Space)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid space")
+	}
+	f := &LocalPrunerFactory{
+		keepRules:     rules,
+		retryWait:     envconst.Duration("ZREPL_PRUNER_RETRY_INTERVAL", 10*time.Second),
+		promPruneSecs: promPruneSecs,
+		space:         space,
+		promSpace:     promSpace,
+	}
+	return f, nil
+}
+
+// BuildLocalPruner builds a pruner for jobs without replication.
+// history must report the most recent snapshot of each filesystem as the replication cursor.
+func (f *LocalPrunerFactory) BuildLocalPruner(ctx context.Context, target Target, history History) *Pruner {
+	p := &Pruner{
+		args: args{
+			WithLogger(ctx, GetLogger(ctx).WithField("prune_side", "local")),
+			target,
+			history,
+			f.keepRules,
+			nil,
+			f.retryWait,
+			false, // not_replicated is not supported
+			f.promPruneSecs.WithLabelValues("local"),
+			f.space,
+			f.promSpace.DestroyedSnapshots.WithLabelValues("local"),
+			f.promSpace.FreedBytes.WithLabelValues("local"),
 		},
 		state: Plan,
 	}
@@ -191,6 +297,16 @@ func (p *Pruner) Prune() {
 	p.prune(p.args)
 }
 
+// DryRun plans like Prune, but stops before destroying any snapshots.
+// The planned destroy lists are listed as pending in the Report of the pruner, which is in state Done afterwards.
+// Errors are not retried.
+func (p *Pruner) DryRun() {
+	p.mtx.Lock()
+	p.dryRun = true
+	p.mtx.Unlock()
+	p.prune(p.args)
+}
+
 func (p *Pruner) prune(args args) {
 	s := p.state.statefunc()
 	for s != nil {
@@ -224,6 +340,8 @@ type Report struct {
 type FSReport struct {
 	Filesystem string
 	SnapshotList, DestroyList []SnapshotReport
+	// replication bookmarks, only if the pruner has bookmark keep rules
+	BookmarkList, BookmarkDestroyList []SnapshotReport
 	ErrorCount int
 	LastError string
 }
@@ -232,6 +350,14 @@ type SnapshotReport struct {
 	Name string
 	Replicated bool
 	Date time.Time
+	// the snapshot has a zfs hold and is never destroyed
+	Held bool
+	// the pruning decision, only set in FSReport.SnapshotList and FSReport.BookmarkList
+	Destroy bool
+	// destroyed despite the keep rules because the space thresholds are exceeded, only set with Destroy
+	DestroyForSpace bool `json:",omitempty"`
+	// the keep rules that keep the snapshot, empty if it is destroyed or there are no keep rules
+	KeptBy []pruning.KeptBy `json:",omitempty"`
 }
 
 func (p *Pruner) Report() *Report {
@@ -284,6 +410,20 @@ type fs struct {
 	// (type snapshot)
 	destroyList []pruning.Snapshot
 
+	// replication bookmarks presented by target, see zfs.ReplicationBookmarkName
+	// (type snapshot)
+	bookmarks []pruning.Snapshot
+	// destroy list returned by pruning.PruneSnapshots(bookmarks), without the most recent bookmark of each job
+	// (type snapshot)
+	bookmarkDestroyList []pruning.Snapshot
+
+	// the keep rules that keep each snapshot or bookmark, by name
+	keptBy map[string][]pruning.KeptBy
+	// snapshots kept by the keep rules but destroyed by space-aware pruning, by name
+	spaceDestroy map[string]bool
+	// snapshots of spaceDestroy that have been destroyed and counted in the metrics, by name
+	spaceDestroyed map[string]bool
+
 	mtx sync.RWMutex
 
 	// only during Exec state, also used by execQueue
@@ -305,21 +445,43 @@ func (f *fs) Report() FSReport {
 		r.LastError = f.execErrLast.Error()
 	}
 
-	r.SnapshotList = make([]SnapshotReport, len(f.snaps))
-	for i, snap := range f.snaps {
-		r.SnapshotList[i] = snap.(snapshot).Report()
-	}
+	r.SnapshotList = f.decisionReports(f.snaps, f.destroyList)
 
 	r.DestroyList = make([]SnapshotReport, len(f.destroyList))
 	for i, snap := range f.destroyList{
 		r.DestroyList[i] = snap.(snapshot).Report()
 	}
 
+	if f.bookmarks != nil {
+		r.BookmarkList = f.decisionReports(f.bookmarks, f.bookmarkDestroyList)
+		r.BookmarkDestroyList = make([]SnapshotReport, len(f.bookmarkDestroyList))
+		for i, bm := range f.bookmarkDestroyList {
+			r.BookmarkDestroyList[i] = bm.(snapshot).Report()
+		}
+	}
+
 	return r
 }
 
+// decisionReports reports list with the pruning decision of each snapshot.
+func (f *fs) decisionReports(list, destroyList []pruning.Snapshot) []SnapshotReport {
+	destroy := make(map[string]bool, len(destroyList))
+	for _, s := range destroyList {
+		destroy[s.Name()] = true
+	}
+	res := make([]SnapshotReport, len(list))
+	for i, s := range list {
+		res[i] = s.(snapshot).Report()
+		res[i].Destroy = destroy[s.Name()]
+		res[i].DestroyForSpace = f.spaceDestroy[s.Name()]
+		res[i].KeptBy = f.keptBy[s.Name()]
+	}
+	return res
+}
+
 type snapshot struct {
 	replicated bool
+	atCursor   bool
 	date       time.Time
 	fsv        *pdu.FilesystemVersion
 }
@@ -329,6 +491,7 @@ func (s snapshot) Report() SnapshotReport {
 		Name:       s.Name(),
 		Replicated: s.Replicated(),
 		Date:       s.Date(),
+		Held:       s.Held(),
 	}
 }
 
@@ -340,6 +503,54 @@ func (s snapshot) Replicated() bool { return s.replicated }
 
 func (s snapshot) Date() time.Time { return s.date }
 
+// Held reports whether the snapshot has a zfs hold, e.g. because it is the most recently replicated snapshot.
+func (s snapshot) Held() bool { return s.fsv.GetUserRefs() > 0 }
+
+// keptBy returns the KeptBy of decisions by snapshot name.
+func keptBy(decisions []pruning.Decision) map[string][]pruning.KeptBy {
+	res := make(map[string][]pruning.KeptBy, len(decisions))
+	for _, d := range decisions {
+		if len(d.KeptBy) > 0 {
+			res[d.Snapshot.Name()] = d.KeptBy
+		}
+	}
+	return res
+}
+
+// withoutHeld returns the snapshots of destroyList that are not held, held snapshots cannot be destroyed.
+func withoutHeld(l Logger, destroyList []pruning.Snapshot) []pruning.Snapshot {
+	res := make([]pruning.Snapshot, 0, len(destroyList))
+	for _, s := range destroyList {
+		if s.(snapshot).Held() {
+			l.WithField("snap", s.Name()).Info("not destroying held snapshot")
+			continue
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+// withoutLatestPerJob returns the bookmarks of destroyList except the most recent replication bookmark of each job in bookmarks,
+// which is the incremental source of that job's next replication if the snapshot has already been destroyed.
+func withoutLatestPerJob(bookmarks, destroyList []pruning.Snapshot) []pruning.Snapshot {
+	latest := make(map[string]pruning.Snapshot)
+	for _, b := range bookmarks {
+		_, job, _ := zfs.ParseReplicationBookmarkName(b.Name())
+		if l, ok := latest[job]; !ok || l.(snapshot).fsv.CreateTXG < b.(snapshot).fsv.CreateTXG {
+			latest[job] = b
+		}
+	}
+	res := make([]pruning.Snapshot, 0, len(destroyList))
+	for _, b := range destroyList {
+		_, job, _ := zfs.ParseReplicationBookmarkName(b.Name())
+		if latest[job] == b {
+			continue
+		}
+		res = append(res, b)
+	}
+	return res
+}
+
 type Error interface {
 	error
 	Temporary() bool
@@ -358,7 +569,7 @@ func shouldRetry(e error) bool {
 func onErr(u updater, e error) state {
 	return u(func(p *Pruner) {
 		p.err = e
-		if !shouldRetry(e) {
+		if !shouldRetry(e) || p.dryRun {
 			p.state = ErrPerm
 			return
 		}
@@ -459,6 +670,7 @@ func statePlan(a *args, u updater) state {
 			preCursor = preCursor && !atCursor
 			pfs.snaps = append(pfs.snaps, snapshot{
 				replicated: preCursor || (a.considerSnapAtCursorReplicated && atCursor),
+				atCursor:   atCursor,
 				date:       creation,
 				fsv:        tfsv,
 			})
@@ -470,7 +682,64 @@ func statePlan(a *args, u updater) state {
 		}
 
 		// Apply prune rules
-		pfs.destroyList = pruning.PruneSnapshots(pfs.snaps, a.rules)
+		destroyList, decisions := pruning.PruneSnapshotsDecisions(pfs.snaps, a.rules)
+		pfs.keptBy = keptBy(decisions)
+		pfs.destroyList = withoutHeld(l, destroyList)
+		if len(pfs.destroyList) < len(destroyList) {
+			for _, s := range destroyList {
+				if s.(snapshot).Held() {
+					pfs.keptBy[s.Name()] = []pruning.KeptBy{{Rule: -1, RuleName: "hold"}}
+				}
+			}
+		}
+
+		if len(a.bookmarkRules) > 0 {
+			pfs.bookmarks = make([]pruning.Snapshot, 0)
+			for _, tfsv := range tfsvs {
+				if tfsv.Type != pdu.FilesystemVersion_Bookmark {
+					continue
+				}
+				if _, _, ok := zfs.ParseReplicationBookmarkName(tfsv.Name); !ok {
+					continue
+				}
+				creation, err := tfsv.CreationAsTime()
+				if err != nil {
+					err := fmt.Errorf("%s%s has invalid creation date: %s", tfs, tfsv.RelName(), err)
+					l.WithError(err).
+						WithField("tfsv", tfsv.RelName()).
+						Error("error with fileesystem version")
+					return onErr(u, err)
+				}
+				pfs.bookmarks = append(pfs.bookmarks, snapshot{
+					replicated: true,
+					date:       creation,
+					fsv:        tfsv,
+				})
+			}
+			destroyList, decisions := pruning.PruneSnapshotsDecisions(pfs.bookmarks, a.bookmarkRules)
+			for name, k := range keptBy(decisions) {
+				pfs.keptBy[name] = k
+			}
+			pfs.bookmarkDestroyList = withoutLatestPerJob(pfs.bookmarks, destroyList)
+			if len(pfs.bookmarkDestroyList) < len(destroyList) {
+				destroy := make(map[string]bool, len(pfs.bookmarkDestroyList))
+				for _, b := range pfs.bookmarkDestroyList {
+					destroy[b.Name()] = true
+				}
+				for _, b := range destroyList {
+					if !destroy[b.Name()] {
+						pfs.keptBy[b.Name()] = []pruning.KeptBy{{Rule: -1, RuleName: "most recent bookmark of job"}}
+					}
+				}
+			}
+		}
+		ka.MadeProgress()
+	}
+
+	if a.space != nil {
+		if err := planSpace(a, pfss); err != nil {
+			return onErr(u, err)
+		}
 		ka.MadeProgress()
 	}
 
@@ -480,6 +749,10 @@ func statePlan(a *args, u updater) state {
 		for _, pfs := range pfss {
 			pruner.execQueue.Put(pfs, nil, false)
 		}
+		if pruner.dryRun {
+			pruner.state = Done
+			return
+		}
 		pruner.state = Exec
 	}).statefunc()
 }
@@ -502,7 +775,7 @@ func stateExec(a *args, u updater) state {
 		return state.statefunc()
 	}
 
-	destroyList := make([]*pdu.FilesystemVersion, len(pfs.destroyList))
+	destroyList := make([]*pdu.FilesystemVersion, len(pfs.destroyList), len(pfs.destroyList)+len(pfs.bookmarkDestroyList))
 	for i := range destroyList {
 		destroyList[i] = pfs.destroyList[i].(snapshot).fsv
 		GetLogger(a.ctx).
@@ -510,6 +783,13 @@ func stateExec(a *args, u updater) state {
 			WithField("destroy_snap", destroyList[i].Name).
 			Debug("policy destroys snapshot")
 	}
+	for _, bm := range pfs.bookmarkDestroyList {
+		destroyList = append(destroyList, bm.(snapshot).fsv)
+		GetLogger(a.ctx).
+			WithField("fs", pfs.path).
+			WithField("destroy_bookmark", bm.Name()).
+			Debug("policy destroys bookmark")
+	}
 	req := pdu.DestroySnapshotsReq{
 		Filesystem: pfs.path,
 		Snapshots:  destroyList,
@@ -556,6 +836,7 @@ func stateExec(a *args, u updater) state {
 			err = fmt.Errorf("destroys failed: %s", strings.Join(pairs, ", "))
 		}
 	}
+	countSpaceDestroys(a, pfs, destroyList, destroyResults)
 	u(func(pruner *Pruner) {
 		pruner.execQueue.Put(pfs, err, err == nil)
 	})
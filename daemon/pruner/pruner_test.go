@@ -8,6 +8,7 @@ import (
 	"github.com/zrepl/zrepl/pruning"
 	"github.com/zrepl/zrepl/replication/pdu"
 	"net"
+	"sort"
 	"testing"
 	"time"
 )
@@ -15,6 +16,10 @@ import (
 type mockFS struct {
 	path  string
 	snaps []string
+	held  map[string]bool   // by snapshot name
+	used  map[string]uint64 // by snapshot name
+	// bookmarks, oldest first
+	bookmarks []string
 }
 
 func (m *mockFS) Filesystem() *pdu.Filesystem {
@@ -31,7 +36,20 @@ func (m *mockFS) FilesystemVersions() []*pdu.FilesystemVersion {
 			Name:     v,
 			Creation: pdu.FilesystemVersionCreation(time.Unix(0, 0)),
 			Guid: uint64(i),
+			Used:     m.used[v],
 		}
+		if m.held[v] {
+			versions[i].UserRefs = 1
+		}
+	}
+	for i, b := range m.bookmarks {
+		versions = append(versions, &pdu.FilesystemVersion{
+			Type:      pdu.FilesystemVersion_Bookmark,
+			Name:      b,
+			Creation:  pdu.FilesystemVersionCreation(time.Unix(0, 0)),
+			Guid:      uint64(len(m.snaps) + i),
+			CreateTXG: uint64(i + 1),
+		})
 	}
 	return versions
 }
@@ -42,6 +60,7 @@ type mockTarget struct {
 	listVersionsErrs   map[string][]error
 	listFilesystemsErr []error
 	destroyErrs        map[string][]error
+	space              map[string]*pdu.FilesystemSpaceRes
 }
 
 func (t *mockTarget) ListFilesystems(ctx context.Context) ([]*pdu.Filesystem, error) {
@@ -90,6 +109,14 @@ func (t *mockTarget) DestroySnapshots(ctx context.Context, req *pdu.DestroySnaps
 	return &pdu.DestroySnapshotsRes{Results: res}, nil
 }
 
+func (t *mockTarget) FilesystemSpace(ctx context.Context, req *pdu.FilesystemSpaceReq) (*pdu.FilesystemSpaceRes, error) {
+	res, ok := t.space[req.Filesystem]
+	if !ok {
+		return nil, fmt.Errorf("filesystem %s does not exist", req.Filesystem)
+	}
+	return res, nil
+}
+
 type mockCursor struct {
 	snapname string
 	guid uint64
@@ -106,7 +133,11 @@ func (r *mockHistory) ReplicationCursor(ctx context.Context, req *pdu.Replicatio
 		r.errs[fs] = r.errs[fs][1:]
 		return nil, e
 	}
-	return &pdu.ReplicationCursorRes{Result: &pdu.ReplicationCursorRes_Guid{Guid: 0}}, nil
+	var guid uint64
+	if c, ok := r.cursors[fs]; ok {
+		guid = c.guid
+	}
+	return &pdu.ReplicationCursorRes{Result: &pdu.ReplicationCursorRes_Guid{Guid: guid}}, nil
 }
 
 type stubNetErr struct {
@@ -203,3 +234,128 @@ func TestPruner_Prune(t *testing.T) {
 	//assert.Equal(t, map[string][]error{}, target.listVersionsErrs, "retried")
 
 }
+
+func TestPruner_HeldSnapshotsAreNotDestroyed(t *testing.T) {
+	target := &mockTarget{
+		destroyed: make(map[string][]string),
+		fss: []mockFS{
+			{
+				path:  "zroot/foo",
+				snaps: []string{"keep_a", "drop_b", "drop_c"},
+				held:  map[string]bool{"drop_c": true},
+			},
+		},
+	}
+	p := Pruner{
+		args: args{
+			ctx:       WithLogger(context.Background(), logger.NewTestLogger(t)),
+			target:    target,
+			receiver:  &mockHistory{},
+			rules:     []pruning.KeepRule{pruning.MustKeepRegex("^keep", false)},
+			retryWait: 10 * time.Millisecond,
+		},
+		state: Plan,
+	}
+	p.Prune()
+
+	assert.Equal(t, map[string][]string{"zroot/foo": {"drop_b"}}, target.destroyed)
+	rep := p.Report()
+	if assert.Len(t, rep.Completed, 1) {
+		held := make(map[string]bool)
+		for _, snap := range rep.Completed[0].SnapshotList {
+			held[snap.Name] = snap.Held
+		}
+		assert.Equal(t, map[string]bool{"keep_a": false, "drop_b": false, "drop_c": true}, held)
+	}
+}
+
+func TestPruner_ReplicationBookmarks(t *testing.T) {
+	target := &mockTarget{
+		destroyed: make(map[string][]string),
+		fss: []mockFS{
+			{
+				path:  "zroot/foo",
+				snaps: []string{"keep_a"},
+				bookmarks: []string{
+					"zrepl_1_zrepl_push",
+					"zrepl_2_zrepl_push",
+					"zrepl_1_zrepl_other",
+					"zrepl_3_zrepl_push",
+					"zrepl_replication_cursor",
+					"manual",
+				},
+			},
+		},
+	}
+	p := Pruner{
+		args: args{
+			ctx:           WithLogger(context.Background(), logger.NewTestLogger(t)),
+			target:        target,
+			receiver:      &mockHistory{},
+			rules:         []pruning.KeepRule{pruning.MustKeepRegex("^keep", false)},
+			bookmarkRules: []pruning.KeepRule{pruning.MustKeepRegex("^keep", false)},
+			retryWait:     10 * time.Millisecond,
+		},
+		state: Plan,
+	}
+	p.Prune()
+
+	// the most recent bookmark of each job is kept, bookmarks not created by zrepl are ignored
+	destroyed := target.destroyed["zroot/foo"]
+	sort.Strings(destroyed)
+	assert.Equal(t, []string{"zrepl_1_zrepl_push", "zrepl_2_zrepl_push"}, destroyed)
+	rep := p.Report()
+	if assert.Len(t, rep.Completed, 1) {
+		assert.Len(t, rep.Completed[0].BookmarkList, 4)
+		assert.Len(t, rep.Completed[0].BookmarkDestroyList, 2)
+		assert.Len(t, rep.Completed[0].SnapshotList, 1)
+	}
+}
+
+func TestPruner_DryRun(t *testing.T) {
+	target := &mockTarget{
+		destroyed: make(map[string][]string),
+		fss: []mockFS{
+			{
+				path:  "zroot/foo",
+				snaps: []string{"keep_a", "drop_b", "drop_c"},
+				held:  map[string]bool{"drop_c": true},
+			},
+		},
+	}
+	p := Pruner{
+		args: args{
+			ctx:      WithLogger(context.Background(), logger.NewTestLogger(t)),
+			target:   target,
+			receiver: &mockHistory{},
+			rules: []pruning.KeepRule{
+				pruning.MustKeepRegex("^keep", false),
+				pruning.MustKeepRegex("^drop", true),
+			},
+			retryWait: 10 * time.Millisecond,
+		},
+		state: Plan,
+	}
+	p.DryRun()
+
+	assert.Empty(t, target.destroyed)
+	rep := p.Report()
+	assert.Equal(t, Done.String(), rep.State)
+	if assert.Len(t, rep.Pending, 1) {
+		fsr := rep.Pending[0]
+		if assert.Len(t, fsr.DestroyList, 1) {
+			assert.Equal(t, "drop_b", fsr.DestroyList[0].Name)
+		}
+		keptBy := make(map[string][]string)
+		for _, snap := range fsr.SnapshotList {
+			assert.Equal(t, snap.Name == "drop_b", snap.Destroy, snap.Name)
+			for _, k := range snap.KeptBy {
+				keptBy[snap.Name] = append(keptBy[snap.Name], k.String())
+			}
+		}
+		assert.Equal(t, map[string][]string{
+			"keep_a": {"#0 regex(^keep)", "#1 regex(negate ^drop)"},
+			"drop_c": {"hold"},
+		}, keptBy)
+	}
+}
@@ -0,0 +1,229 @@
+package pruner
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/replication/pdu"
+	"sort"
+)
+
+// SpaceTarget is a Target that reports the space usage of its filesystems, required for space-aware pruning.
+type SpaceTarget interface {
+	Target
+	FilesystemSpace(ctx context.Context, req *pdu.FilesystemSpaceReq) (*pdu.FilesystemSpaceRes, error)
+}
+
+// spacePolicy destroys the oldest snapshots kept by the keep rules
+// while the used percentage or the available space exceed the thresholds (high-water marks),
+// until both are within the targets (low-water marks).
+// Zero thresholds are not checked.
+type spacePolicy struct {
+	pool                              bool // check the pool of each filesystem, not the filesystem itself
+	maxUsedPercent, targetUsedPercent float64
+	minAvailable, targetAvailable     uint64
+}
+
+func spacePolicyFromConfig(in *config.PruneSpace) (*spacePolicy, error) {
+	if in == nil {
+		return nil, nil
+	}
+	p := &spacePolicy{
+		maxUsedPercent:    float64(in.MaxUsedPercent),
+		targetUsedPercent: float64(in.TargetUsedPercent),
+		minAvailable:      uint64(in.MinAvailable),
+		targetAvailable:   uint64(in.TargetAvailable),
+	}
+	switch in.Of {
+	case "pool":
+		p.pool = true
+	case "dataset":
+	default:
+		return nil, errors.Errorf("of must be pool or dataset, got %q", in.Of)
+	}
+	if p.maxUsedPercent == 0 && p.minAvailable == 0 {
+		return nil, errors.New("must specify max_used_percent or min_available")
+	}
+	if p.maxUsedPercent != 0 || p.targetUsedPercent != 0 {
+		if !(0 < p.targetUsedPercent && p.targetUsedPercent < p.maxUsedPercent && p.maxUsedPercent <= 100) {
+			return nil, errors.New("must specify 0 < target_used_percent < max_used_percent <= 100")
+		}
+	}
+	if p.minAvailable != 0 || p.targetAvailable != 0 {
+		if !(0 < p.minAvailable && p.minAvailable < p.targetAvailable) {
+			return nil, errors.New("must specify 0 < min_available < target_available")
+		}
+	}
+	return p, nil
+}
+
+// usage returns the space usage of res that p checks and the key of the pool or filesystem it belongs to.
+func (p *spacePolicy) usage(fs string, res *pdu.FilesystemSpaceRes) (key string, u spaceUsage) {
+	if p.pool {
+		return res.GetPool(), spaceUsage{res.GetPoolUsed(), res.GetPoolAvailable()}
+	}
+	return fs, spaceUsage{res.GetUsed(), res.GetAvailable()}
+}
+
+type spaceUsage struct {
+	used, available uint64
+}
+
+func (u spaceUsage) usedPercent() float64 {
+	if u.used+u.available == 0 {
+		return 0
+	}
+	return 100 * float64(u.used) / float64(u.used+u.available)
+}
+
+func (u spaceUsage) free(bytes uint64) spaceUsage {
+	if bytes > u.used {
+		bytes = u.used
+	}
+	return spaceUsage{u.used - bytes, u.available + bytes}
+}
+
+func (p *spacePolicy) exceeded(u spaceUsage) bool {
+	return (p.maxUsedPercent != 0 && u.usedPercent() > p.maxUsedPercent) ||
+		(p.minAvailable != 0 && u.available < p.minAvailable)
+}
+
+func (p *spacePolicy) satisfied(u spaceUsage) bool {
+	return (p.maxUsedPercent == 0 || u.usedPercent() <= p.targetUsedPercent) &&
+		(p.minAvailable == 0 || u.available >= p.targetAvailable)
+}
+
+// spaceCandidate reports whether s may be destroyed to free space:
+// held snapshots, snapshots that have not been replicated and the snapshot at the replication cursor are never destroyed.
+func spaceCandidate(s snapshot) bool {
+	return !s.Held() && s.Replicated() && !s.atCursor
+}
+
+// selectOldest returns the oldest candidates that need to be destroyed
+// in addition to the planned destroys to bring u within the targets of p,
+// as estimated by the space used by each snapshot alone.
+// The returned usage is the estimated usage after all destroys.
+func (p *spacePolicy) selectOldest(u spaceUsage, planned, candidates []snapshot) (destroy []snapshot, after spaceUsage) {
+	for _, s := range planned {
+		u = u.free(s.fsv.GetUsed())
+	}
+	candidates = append([]snapshot(nil), candidates...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].date.Before(candidates[j].date)
+	})
+	for _, s := range candidates {
+		if p.satisfied(u) {
+			break
+		}
+		destroy = append(destroy, s)
+		u = u.free(s.fsv.GetUsed())
+	}
+	return destroy, u
+}
+
+// planSpace extends the destroy lists of pfss by the oldest snapshots kept by the keep rules
+// where the space thresholds of a.space are exceeded.
+func planSpace(a *args, pfss []*fs) error {
+	ctx, p := a.ctx, a.space
+	target, ok := a.target.(SpaceTarget)
+	if !ok {
+		return errors.Errorf("prune target does not support space-aware pruning")
+	}
+
+	type group struct {
+		usage spaceUsage
+		fss   []*fs
+	}
+	groups := make(map[string]*group)
+	var keys []string
+	for _, pfs := range pfss {
+		if pfs.planErr != nil {
+			continue
+		}
+		res, err := target.FilesystemSpace(ctx, &pdu.FilesystemSpaceReq{Filesystem: pfs.path})
+		if err != nil {
+			GetLogger(ctx).WithField("fs", pfs.path).WithError(err).Error("cannot get space usage")
+			return err
+		}
+		key, usage := p.usage(pfs.path, res)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{usage: usage}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		g.fss = append(g.fss, pfs)
+	}
+
+	for _, key := range keys {
+		g := groups[key]
+		l := GetLogger(ctx).
+			WithField("space", key).
+			WithField("used_percent", fmt.Sprintf("%.1f", g.usage.usedPercent())).
+			WithField("available", g.usage.available)
+		if !p.exceeded(g.usage) {
+			l.Debug("space thresholds not exceeded")
+			continue
+		}
+
+		var planned, candidates []snapshot
+		fsOf := make(map[snapshot]*fs)
+		for _, pfs := range g.fss {
+			destroy := make(map[string]bool, len(pfs.destroyList))
+			for _, s := range pfs.destroyList {
+				planned = append(planned, s.(snapshot))
+				destroy[s.Name()] = true
+			}
+			for _, s := range pfs.snaps {
+				if !destroy[s.Name()] && spaceCandidate(s.(snapshot)) {
+					candidates = append(candidates, s.(snapshot))
+					fsOf[s.(snapshot)] = pfs
+				}
+			}
+		}
+
+		destroy, after := p.selectOldest(g.usage, planned, candidates)
+		for _, s := range destroy {
+			pfs := fsOf[s]
+			l.WithField("fs", pfs.path).
+				WithField("snap", s.Name()).
+				WithField("used", s.fsv.GetUsed()).
+				Info("destroying snapshot kept by keep rules because space thresholds are exceeded")
+			pfs.destroyList = append(pfs.destroyList, s)
+			if pfs.spaceDestroy == nil {
+				pfs.spaceDestroy = make(map[string]bool)
+			}
+			pfs.spaceDestroy[s.Name()] = true
+			delete(pfs.keptBy, s.Name())
+		}
+		l = l.WithField("destroy_count", len(destroy)).
+			WithField("estimated_used_percent", fmt.Sprintf("%.1f", after.usedPercent())).
+			WithField("estimated_available", after.available)
+		if p.satisfied(after) {
+			l.Info("space thresholds exceeded, destroying oldest snapshots")
+		} else {
+			l.Warn("space thresholds exceeded, cannot destroy enough snapshots to reach the targets")
+		}
+	}
+	return nil
+}
+
+// countSpaceDestroys adds the snapshots of pfs.spaceDestroy that destroyList destroyed to the metrics.
+// Snapshots destroyed by an earlier attempt are not counted again.
+func countSpaceDestroys(a *args, pfs *fs, destroyList []*pdu.FilesystemVersion, results map[string]*pdu.DestroySnapshotRes) {
+	for _, v := range destroyList {
+		if !pfs.spaceDestroy[v.Name] || pfs.spaceDestroyed[v.Name] {
+			continue
+		}
+		if res, ok := results[v.Name]; !ok || res.Error != "" {
+			continue
+		}
+		if pfs.spaceDestroyed == nil {
+			pfs.spaceDestroyed = make(map[string]bool)
+		}
+		pfs.spaceDestroyed[v.Name] = true
+		a.promSpaceDestroyed.Inc()
+		a.promSpaceFreed.Add(float64(v.GetUsed()))
+	}
+}
@@ -0,0 +1,164 @@
+package pruner
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/pruning"
+	"github.com/zrepl/zrepl/replication/pdu"
+	"testing"
+	"time"
+)
+
+func TestSpacePolicyFromConfig(t *testing.T) {
+
+	p, err := spacePolicyFromConfig(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+
+	valid := []config.PruneSpace{
+		{Of: "pool", MaxUsedPercent: 90, TargetUsedPercent: 80},
+		{Of: "dataset", MinAvailable: 100, TargetAvailable: 200},
+		{Of: "pool", MaxUsedPercent: 100, TargetUsedPercent: 99, MinAvailable: 100, TargetAvailable: 200},
+	}
+	for _, in := range valid {
+		in := in
+		_, err := spacePolicyFromConfig(&in)
+		assert.NoError(t, err, "%#v", in)
+	}
+
+	invalid := []config.PruneSpace{
+		{Of: "volume", MaxUsedPercent: 90, TargetUsedPercent: 80},
+		{Of: "pool"},
+		{Of: "pool", MaxUsedPercent: 90},
+		{Of: "pool", MaxUsedPercent: 80, TargetUsedPercent: 90},
+		{Of: "pool", MaxUsedPercent: 101, TargetUsedPercent: 90},
+		{Of: "pool", TargetUsedPercent: 80, MinAvailable: 100, TargetAvailable: 200},
+		{Of: "pool", MinAvailable: 200, TargetAvailable: 100},
+		{Of: "pool", MaxUsedPercent: 90, TargetUsedPercent: 80, TargetAvailable: 200},
+	}
+	for _, in := range invalid {
+		in := in
+		_, err := spacePolicyFromConfig(&in)
+		assert.Error(t, err, "%#v", in)
+	}
+}
+
+func TestSpacePolicy_selectOldest(t *testing.T) {
+
+	snap := func(name string, minutes int, used uint64) snapshot {
+		return snapshot{
+			replicated: true,
+			date:       time.Unix(0, 0).Add(time.Duration(minutes) * time.Minute),
+			fsv:        &pdu.FilesystemVersion{Name: name, Used: used},
+		}
+	}
+	names := func(snaps []snapshot) []string {
+		res := make([]string, len(snaps))
+		for i, s := range snaps {
+			res[i] = s.Name()
+		}
+		return res
+	}
+
+	p := &spacePolicy{maxUsedPercent: 90, targetUsedPercent: 80, minAvailable: 5, targetAvailable: 10}
+	candidates := []snapshot{snap("c", 3, 10), snap("a", 1, 5), snap("b", 2, 10), snap("d", 4, 10)}
+
+	t.Run("oldestFirst", func(t *testing.T) {
+		destroy, after := p.selectOldest(spaceUsage{used: 95, available: 5}, nil, candidates)
+		assert.Equal(t, []string{"a", "b"}, names(destroy))
+		assert.Equal(t, spaceUsage{used: 80, available: 20}, after)
+		assert.True(t, p.satisfied(after))
+	})
+
+	t.Run("plannedDestroysCount", func(t *testing.T) {
+		planned := []snapshot{snap("x", 0, 10)}
+		destroy, _ := p.selectOldest(spaceUsage{used: 95, available: 5}, planned, candidates)
+		assert.Equal(t, []string{"a"}, names(destroy))
+	})
+
+	t.Run("notEnoughCandidates", func(t *testing.T) {
+		destroy, after := p.selectOldest(spaceUsage{used: 995, available: 5}, nil, candidates)
+		assert.Len(t, destroy, len(candidates))
+		assert.False(t, p.satisfied(after))
+	})
+
+	t.Run("availableThreshold", func(t *testing.T) {
+		p := &spacePolicy{minAvailable: 50, targetAvailable: 60}
+		u := spaceUsage{used: 100, available: 45}
+		assert.True(t, p.exceeded(u))
+		destroy, after := p.selectOldest(u, nil, candidates)
+		assert.Equal(t, []string{"a", "b"}, names(destroy))
+		assert.Equal(t, uint64(60), after.available)
+	})
+}
+
+func TestPruner_Space(t *testing.T) {
+	target := &mockTarget{
+		destroyed: make(map[string][]string),
+		fss: []mockFS{
+			{
+				path:  "zroot/foo",
+				snaps: []string{"drop_0", "keep_1", "keep_2", "keep_3", "keep_4", "keep_5"},
+				held:  map[string]bool{"keep_1": true},
+				used: map[string]uint64{
+					"drop_0": 5, "keep_1": 10, "keep_2": 10, "keep_3": 10, "keep_4": 10, "keep_5": 10,
+				},
+			},
+		},
+		space: map[string]*pdu.FilesystemSpaceRes{
+			"zroot/foo": {Used: 60, Available: 5, Pool: "zroot", PoolUsed: 95, PoolAvailable: 5},
+		},
+		destroyErrs: map[string][]error{
+			"zroot/foo": {stubNetErr{msg: "fakeerror", temporary: true}},
+		},
+	}
+	// keep_4 is at the replication cursor, keep_5 has not been replicated yet
+	receiver := &mockHistory{cursors: map[string]*mockCursor{"zroot/foo": {snapname: "keep_4", guid: 4}}}
+
+	destroyedCount := prometheus.NewCounter(prometheus.CounterOpts{Name: "space_destroyed_snapshots"})
+	freedBytes := prometheus.NewCounter(prometheus.CounterOpts{Name: "space_freed_bytes"})
+	p := Pruner{
+		args: args{
+			ctx:                WithLogger(context.Background(), logger.NewTestLogger(t)),
+			target:             target,
+			receiver:           receiver,
+			rules:              []pruning.KeepRule{pruning.MustKeepRegex("^keep", false)},
+			retryWait:          10 * time.Millisecond,
+			space:              &spacePolicy{pool: true, maxUsedPercent: 90, targetUsedPercent: 80},
+			promSpaceDestroyed: destroyedCount,
+			promSpaceFreed:     freedBytes,
+		},
+		state: Plan,
+	}
+	p.Prune()
+
+	// drop_0 frees 5 bytes, keep_1 is held, keep_2 frees the remaining 10 bytes to reach 80%
+	assert.Equal(t, map[string][]string{"zroot/foo": {"drop_0", "keep_2"}}, target.destroyed)
+	rep := p.Report()
+	if assert.Len(t, rep.Completed, 1) {
+		forSpace := make(map[string]bool)
+		for _, snap := range rep.Completed[0].SnapshotList {
+			if snap.DestroyForSpace {
+				forSpace[snap.Name] = true
+				assert.True(t, snap.Destroy)
+				assert.Empty(t, snap.KeptBy)
+			}
+		}
+		assert.Equal(t, map[string]bool{"keep_2": true}, forSpace)
+	}
+
+	// only keep_2 is counted, once, although the first destroy attempt failed
+	counterValue := func(c prometheus.Counter) float64 {
+		var m dto.Metric
+		if err := c.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		return m.GetCounter().GetValue()
+	}
+	assert.Equal(t, float64(1), counterValue(destroyedCount))
+	assert.Equal(t, float64(10), counterValue(freedBytes))
+}
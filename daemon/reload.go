@@ -0,0 +1,194 @@
+package daemon
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ReloadReq is the request body of ControlJobEndpointReload.
+type ReloadReq struct {
+	// Cancel the current invocation of removed and changed jobs instead of waiting for it to finish.
+	CancelInvocations bool
+}
+
+// ReloadRes is the response body of ControlJobEndpointReload.
+type ReloadRes struct {
+	Added, Removed, Restarted, Unchanged []string
+	// Removed and restarted jobs that are still finishing their current invocation.
+	// Added jobs are started once the removed jobs have exited.
+	Pending []string
+	// Changes to the global section are not applied by a reload.
+	GlobalChanged bool
+}
+
+type reloader struct {
+	ctx        context.Context
+	configPath string
+	jobs       *jobs
+
+	// mtx serializes reloads and protects the fields below it
+	mtx sync.Mutex
+	// the config the running jobs were built from
+	conf *config.Config
+	// jobs of the previous reload that are still finishing their current invocation
+	pending     []string
+	pendingDone <-chan struct{}
+}
+
+func newReloader(ctx context.Context, configPath string, conf *config.Config, jobs *jobs) *reloader {
+	return &reloader{ctx: ctx, configPath: configPath, conf: conf, jobs: jobs}
+}
+
+// reload re-reads the config file and starts added jobs, stops removed jobs and restarts jobs whose config changed.
+// Jobs are stopped once they finish their current invocation unless req.CancelInvocations is set.
+func (r *reloader) reload(req *ReloadReq) (res *ReloadRes, err error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	log := job.GetLogger(r.ctx).WithField(logSubsysField, "reload")
+	defer func() {
+		if err != nil {
+			log.WithError(err).Error("cannot reload config")
+			return
+		}
+		log.
+			WithField("added", res.Added).
+			WithField("removed", res.Removed).
+			WithField("restarted", res.Restarted).
+			WithField("pending", res.Pending).
+			Info("config reloaded")
+		if res.GlobalChanged {
+			log.Warn("changes to the global section require a daemon restart")
+		}
+	}()
+
+	if err := r.waitPending(req.CancelInvocations); err != nil {
+		return nil, err
+	}
+
+	newConf, err := config.ParseConfig(r.configPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot parse config")
+	}
+	parsedGlobal := newConf.Global
+	// jobs are built with the running global config since changes to it are not applied
+	newConf = &config.Config{Global: r.conf.Global, Jobs: newConf.Jobs}
+	newJobs, err := job.JobsFromConfig(newConf)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot build jobs from config")
+	}
+	newJobsByName := make(map[string]job.Job, len(newJobs))
+	for _, j := range newJobs {
+		if IsInternalJobName(j.Name()) {
+			return nil, errors.Errorf("internal job name used for config job %q", j.Name())
+		}
+		if _, ok := newJobsByName[j.Name()]; ok {
+			return nil, errors.Errorf("duplicate job name %q", j.Name())
+		}
+		newJobsByName[j.Name()] = j
+	}
+
+	res = &ReloadRes{GlobalChanged: !reflect.DeepEqual(r.conf.Global, parsedGlobal)}
+
+	oldConfByName := make(map[string]config.JobEnum, len(r.conf.Jobs))
+	for _, jc := range r.conf.Jobs {
+		oldConfByName[jc.Name()] = jc
+		if _, ok := newJobsByName[jc.Name()]; !ok {
+			res.Removed = append(res.Removed, jc.Name())
+		}
+	}
+	restart := make(map[string]job.Job)
+	for _, jc := range newConf.Jobs {
+		name := jc.Name()
+		old, ok := oldConfByName[name]
+		switch {
+		case !ok:
+			res.Added = append(res.Added, name)
+		case reflect.DeepEqual(old.Ret, jc.Ret):
+			res.Unchanged = append(res.Unchanged, name)
+		default:
+			res.Restarted = append(res.Restarted, name)
+			restart[name] = newJobsByName[name]
+		}
+	}
+
+	// Added jobs are started once the removed jobs have exited, a renamed job may serve on the same address.
+	var stopped, removed sync.WaitGroup
+	for _, name := range append(append([]string{}, res.Removed...), res.Restarted...) {
+		done, err := r.jobs.stop(name, req.CancelInvocations)
+		if err != nil {
+			log.WithError(err).WithField(logJobField, name).Error("cannot stop job")
+			continue
+		}
+		stopped.Add(1)
+		if restart[name] == nil {
+			removed.Add(1)
+		}
+		go func(name string, done <-chan struct{}, restart job.Job) {
+			defer stopped.Done()
+			<-done
+			r.jobs.remove(name)
+			if restart != nil {
+				log.WithField(logJobField, name).Info("restart job")
+				r.jobs.start(r.ctx, restart, false)
+				return
+			}
+			defer removed.Done()
+			if err := r.jobs.forgetPause(name); err != nil {
+				log.WithError(err).WithField(logJobField, name).Error("cannot drop pause state of removed job")
+			}
+		}(name, done, restart[name])
+	}
+	stopped.Add(1)
+	go func(added []string) {
+		defer stopped.Done()
+		removed.Wait()
+		for _, name := range added {
+			r.jobs.start(r.ctx, newJobsByName[name], false)
+		}
+	}(res.Added)
+	r.conf = newConf
+
+	if req.CancelInvocations {
+		stopped.Wait()
+		return res, nil
+	}
+
+	res.Pending = append(append([]string{}, res.Removed...), res.Restarted...)
+	sort.Strings(res.Pending)
+	pendingDone := make(chan struct{})
+	go func() {
+		stopped.Wait()
+		close(pendingDone)
+	}()
+	r.pending, r.pendingDone = res.Pending, pendingDone
+	return res, nil
+}
+
+// waitPending waits for the jobs of the previous reload to exit if cancel is set, otherwise fails if they have not exited yet.
+// r.mtx must be held.
+func (r *reloader) waitPending(cancel bool) error {
+	if r.pendingDone == nil {
+		return nil
+	}
+	select {
+	case <-r.pendingDone:
+	default:
+		if !cancel {
+			return errors.Errorf("jobs of the previous reload are still finishing their current invocation: %s",
+				strings.Join(r.pending, ", "))
+		}
+		for _, name := range r.pending {
+			r.jobs.stop(name, true) // error means that the job has already been removed
+		}
+		<-r.pendingDone
+	}
+	r.pending, r.pendingDone = nil, nil
+	return nil
+}
@@ -0,0 +1,247 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/transferlimit"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const reloadTestJobs = `
+jobs:
+- name: snapjob
+  type: snap
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+    - type: last_n
+      count: 10
+`
+
+const reloadTestGlobal = `
+global:
+  logging:
+  - type: stdout
+    level: debug
+    format: human
+`
+
+func TestReloader_GlobalChanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-reload-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "zrepl.yml")
+
+	reloadWith := func(r *reloader, conf string) *ReloadRes {
+		require.NoError(t, ioutil.WriteFile(path, []byte(conf), 0600))
+		res, err := r.reload(&ReloadReq{CancelInvocations: true})
+		require.NoError(t, err)
+		return res
+	}
+
+	conf, err := config.ParseConfigBytes([]byte(reloadTestJobs))
+	require.NoError(t, err)
+	r := newReloader(context.Background(), path, conf, nil)
+
+	res := reloadWith(r, reloadTestJobs)
+	assert.False(t, res.GlobalChanged)
+	assert.Equal(t, []string{"snapjob"}, res.Unchanged)
+
+	res = reloadWith(r, reloadTestGlobal+reloadTestJobs)
+	assert.True(t, res.GlobalChanged)
+	assert.Equal(t, []string{"snapjob"}, res.Unchanged)
+
+	// the running global section is unchanged by the reload
+	res = reloadWith(r, reloadTestGlobal+reloadTestJobs)
+	assert.True(t, res.GlobalChanged)
+}
+
+// reloadTestConfig returns a config with a manually snapshotting snap job for each name,
+// name=count changes the job's config.
+func reloadTestConfig(jobs ...string) string {
+	var b strings.Builder
+	b.WriteString("jobs:\n")
+	for _, j := range jobs {
+		name, count := j, "10"
+		if i := strings.Index(j, "="); i >= 0 {
+			name, count = j[:i], j[i+1:]
+		}
+		fmt.Fprintf(&b, `- name: %s
+  type: snap
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+    - type: last_n
+      count: %s
+`, name, count)
+	}
+	return b.String()
+}
+
+// newTestReloader starts the jobs of conf and returns a reloader for the config file path.
+func newTestReloader(t *testing.T, dir, path, conf string) *reloader {
+	c, err := config.ParseConfigBytes([]byte(conf))
+	require.NoError(t, err)
+	limiter, err := transferlimit.FromConfig(c.Global.Transfers)
+	require.NoError(t, err)
+	confJobs, err := job.JobsFromConfig(c)
+	require.NoError(t, err)
+
+	jobs := newJobs(newPauseStore(dir), limiter, nil)
+	for _, j := range confJobs {
+		jobs.start(context.Background(), j, false)
+	}
+	return newReloader(context.Background(), path, c, jobs)
+}
+
+func (s *jobs) running() []string {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	var names []string
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *jobs) stopAll() {
+	s.m.RLock()
+	var dones []<-chan struct{}
+	for name, cancel := range s.cancels {
+		cancel()
+		dones = append(dones, s.dones[name])
+	}
+	s.m.RUnlock()
+	for _, done := range dones {
+		<-done
+	}
+}
+
+func TestReloader_Jobs(t *testing.T) {
+	tcs := []struct {
+		name      string
+		from, to  []string
+		expect    ReloadRes
+		expectRun []string
+	}{
+		{
+			name:      "unchanged",
+			from:      []string{"a", "b"},
+			to:        []string{"a", "b"},
+			expect:    ReloadRes{Unchanged: []string{"a", "b"}},
+			expectRun: []string{"a", "b"},
+		},
+		{
+			name:      "edit",
+			from:      []string{"a", "b"},
+			to:        []string{"a=5", "b"},
+			expect:    ReloadRes{Restarted: []string{"a"}, Unchanged: []string{"b"}},
+			expectRun: []string{"a", "b"},
+		},
+		{
+			name:      "add",
+			from:      []string{"a"},
+			to:        []string{"a", "b"},
+			expect:    ReloadRes{Added: []string{"b"}, Unchanged: []string{"a"}},
+			expectRun: []string{"a", "b"},
+		},
+		{
+			name:      "remove",
+			from:      []string{"a", "b"},
+			to:        []string{"a"},
+			expect:    ReloadRes{Removed: []string{"b"}, Unchanged: []string{"a"}},
+			expectRun: []string{"a"},
+		},
+		{
+			name:      "rename",
+			from:      []string{"a", "b"},
+			to:        []string{"a", "c"},
+			expect:    ReloadRes{Added: []string{"c"}, Removed: []string{"b"}, Unchanged: []string{"a"}},
+			expectRun: []string{"a", "c"},
+		},
+		{
+			name:      "all",
+			from:      []string{"a", "b", "c"},
+			to:        []string{"d", "b=5", "c"},
+			expect:    ReloadRes{Added: []string{"d"}, Removed: []string{"a"}, Restarted: []string{"b"}, Unchanged: []string{"c"}},
+			expectRun: []string{"b", "c", "d"},
+		},
+	}
+
+	for _, tc := range tcs {
+		for _, cancel := range []bool{true, false} {
+			tc, cancel := tc, cancel
+			t.Run(fmt.Sprintf("%s/cancel=%v", tc.name, cancel), func(t *testing.T) {
+				dir, err := ioutil.TempDir("", "zrepl-reload-test")
+				require.NoError(t, err)
+				defer os.RemoveAll(dir)
+				path := filepath.Join(dir, "zrepl.yml")
+
+				r := newTestReloader(t, dir, path, reloadTestConfig(tc.from...))
+				defer r.jobs.stopAll()
+
+				require.NoError(t, ioutil.WriteFile(path, []byte(reloadTestConfig(tc.to...)), 0600))
+				res, err := r.reload(&ReloadReq{CancelInvocations: cancel})
+				require.NoError(t, err)
+
+				expect := tc.expect
+				if !cancel {
+					expect.Pending = append(append([]string{}, expect.Removed...), expect.Restarted...)
+					sort.Strings(expect.Pending)
+					// the jobs are idle, they exit without finishing an invocation
+					<-r.pendingDone
+				}
+				assert.Equal(t, &expect, res)
+				assert.Equal(t, tc.expectRun, r.jobs.running())
+			})
+		}
+	}
+}
+
+func TestReloader_WaitPending(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-reload-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "zrepl.yml")
+
+	// a was removed by the previous reload and is still finishing its invocation
+	r := newTestReloader(t, dir, path, reloadTestConfig("a"))
+	defer r.jobs.stopAll()
+	r.conf.Jobs = nil
+	r.jobs.m.RLock()
+	done := r.jobs.dones["a"]
+	r.jobs.m.RUnlock()
+	pendingDone := make(chan struct{})
+	go func() {
+		<-done
+		r.jobs.remove("a")
+		close(pendingDone)
+	}()
+	r.pending, r.pendingDone = []string{"a"}, pendingDone
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(reloadTestConfig("b")), 0600))
+	_, err = r.reload(&ReloadReq{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "still finishing")
+	}
+	assert.Equal(t, []string{"a"}, r.jobs.running())
+
+	res, err := r.reload(&ReloadReq{CancelInvocations: true})
+	require.NoError(t, err)
+	assert.Equal(t, &ReloadRes{Added: []string{"b"}}, res)
+	assert.Equal(t, []string{"b"}, r.jobs.running())
+}
@@ -9,7 +9,11 @@ import (
 	"fmt"
 	"github.com/zrepl/zrepl/zfs"
 	"sort"
+	"strings"
 	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/util/cron"
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/job/pause"
 	"sync"
 )
 
@@ -34,6 +38,9 @@ type snapProgress struct {
 	// SnapDone
 	doneAt time.Time
 
+	// SnapDone, SnapError
+	hooks []*hooks.Report
+
 	// SnapErr
 	err error
 }
@@ -42,11 +49,22 @@ type args struct {
 	ctx            context.Context
 	log            Logger
 	prefix         string
-	interval       time.Duration
+	interval       time.Duration  // periodic snapshotting
+	cron           cron.Schedules // cron snapshotting, interval is unused if non-nil
+	cronLocation   *time.Location
 	fsf            *filters.DatasetMapFilter
+	hooks          hooks.List
 	snapshotsTaken chan<-struct{}
 }
 
+// nextTick returns the time of the next snapshot after the snapshots taken at lastTick.
+func (a args) nextTick(lastTick time.Time) time.Time {
+	if a.cron != nil {
+		return a.cron.Next(lastTick.In(a.cronLocation))
+	}
+	return lastTick.Add(a.interval)
+}
+
 type Snapper struct {
 	args args
 
@@ -119,11 +137,51 @@ func PeriodicFromConfig(g *config.Global, fsf *filters.DatasetMapFilter, in *con
 	if in.Interval <= 0 {
 		return nil, errors.New("interval must be positive")
 	}
+	hs, err := hooks.ListFromConfig(in.Hooks)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid hooks")
+	}
 
 	args := args{
 		prefix: in.Prefix,
 		interval: in.Interval,
 		fsf: fsf,
+		hooks: hs,
+		// ctx and log is set in Run()
+	}
+
+	return &Snapper{state: SyncUp, args: args}, nil
+}
+
+func CronFromConfig(g *config.Global, fsf *filters.DatasetMapFilter, in *config.SnapshottingCron) (*Snapper, error) {
+	if in.Prefix == "" {
+		return nil, errors.New("prefix must not be empty")
+	}
+	if len(in.Cron) == 0 {
+		return nil, errors.New("cron must contain at least one schedule")
+	}
+	schedules, err := cron.ParseAll(in.Cron)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(in.TimeZone)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid timezone")
+	}
+	if schedules.Next(time.Now().In(loc)).IsZero() {
+		return nil, errors.New("cron schedules never fire")
+	}
+	hs, err := hooks.ListFromConfig(in.Hooks)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid hooks")
+	}
+
+	args := args{
+		prefix: in.Prefix,
+		cron: schedules,
+		cronLocation: loc,
+		fsf: fsf,
+		hooks: hs,
 		// ctx and log is set in Run()
 	}
 
@@ -162,6 +220,63 @@ func (s *Snapper) Run(ctx context.Context, snapshotsTaken chan<- struct{}) {
 
 }
 
+type Report struct {
+	State State
+	// valid in State SyncUp, Waiting and ErrorWait: time of the next snapshots
+	SleepUntil time.Time
+	Error      string
+	Progress   []*ReportFilesystem
+}
+
+type ReportFilesystem struct {
+	Path  string
+	State SnapState
+
+	// Valid in SnapStarted and later
+	SnapName string
+	StartAt  time.Time
+
+	// Valid in SnapDone | SnapError
+	DoneAt time.Time
+	Error  string
+	Hooks  []*hooks.Report
+}
+
+// Report is safe to be called asynchronously while Run is running.
+func (s *Snapper) Report() *Report {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	r := &Report{
+		State:      s.state,
+		SleepUntil: s.sleepUntil,
+	}
+	if s.err != nil {
+		r.Error = s.err.Error()
+	}
+
+	r.Progress = make([]*ReportFilesystem, 0, len(s.plan))
+	for fs, p := range s.plan {
+		rfs := &ReportFilesystem{
+			Path:     fs.ToString(),
+			State:    p.state,
+			SnapName: p.name,
+			StartAt:  p.startAt,
+			Hooks:    p.hooks,
+			DoneAt:   p.doneAt,
+		}
+		if p.err != nil {
+			rfs.Error = p.err.Error()
+		}
+		r.Progress = append(r.Progress, rfs)
+	}
+	sort.Slice(r.Progress, func(i, j int) bool {
+		return r.Progress[i].Path < r.Progress[j].Path
+	})
+
+	return r
+}
+
 func onErr(err error, u updater) state {
 	return u(func(s *Snapper) {
 		s.err = err
@@ -177,13 +292,19 @@ func onMainCtxDone(ctx context.Context, u updater) state {
 }
 
 func syncUp(a args, u updater) state {
-	fss, err := listFSes(a.fsf)
-	if err != nil {
-		return onErr(err, u)
-	}
-	syncPoint, err := findSyncPoint(a.log, fss, a.prefix, a.interval)
-	if err != nil {
-		return onErr(err, u)
+	var syncPoint time.Time
+	if a.cron != nil {
+		// the schedule is absolute, existing snapshots do not matter
+		syncPoint = a.nextTick(time.Now())
+	} else {
+		fss, err := listFSes(a.fsf)
+		if err != nil {
+			return onErr(err, u)
+		}
+		syncPoint, err = findSyncPoint(a.log, fss, a.prefix, a.interval)
+		if err != nil {
+			return onErr(err, u)
+		}
 	}
 	u(func(s *Snapper){
 		s.sleepUntil = syncPoint
@@ -204,6 +325,12 @@ func plan(a args, u updater) state {
 	u(func(snapper *Snapper) {
 		snapper.lastInvocation = time.Now()
 	})
+	if pause.Paused(a.ctx) {
+		a.log.Info("job is paused, skipping snapshots")
+		return u(func(s *Snapper) {
+			s.state = Waiting
+		}).sf()
+	}
 	fss, err := listFSes(a.fsf)
 	if err != nil {
 		return onErr(err, u)
@@ -221,44 +348,47 @@ func plan(a args, u updater) state {
 
 func snapshot(a args, u updater) state {
 
-	var plan map[*zfs.DatasetPath]snapProgress
+	var fss []*zfs.DatasetPath
 	u(func(snapper *Snapper) {
-		plan = snapper.plan
+		for fs := range snapper.plan {
+			fss = append(fss, fs)
+		}
 	})
 
-	hadErr := false
-	// TODO channel programs -> allow a little jitter?
-	for fs, progress := range plan {
-		suffix := time.Now().In(time.UTC).Format("20060102_150405_000")
-		snapname := fmt.Sprintf("%s%s", a.prefix, suffix)
-
-		l := a.log.
-			WithField("fs", fs.ToString()).
-			WithField("snap", snapname)
+	// all snapshots of this invocation share the same name
+	snapname := fmt.Sprintf("%s%s", a.prefix, timestampSuffix())
 
+	hadErr := false
+	started := func(fss []*zfs.DatasetPath) {
+		startAt := time.Now()
 		u(func(snapper *Snapper) {
-			progress.name = snapname
-			progress.startAt = time.Now()
-			progress.state = SnapStarted
+			for _, fs := range fss {
+				progress := snapper.plan[fs]
+				progress.name = snapname
+				progress.startAt = startAt
+				progress.state = SnapStarted
+				snapper.plan[fs] = progress
+			}
 		})
-
-		l.Debug("create snapshot")
-		err := zfs.ZFSSnapshot(fs, snapname, false)
+	}
+	done := func(fs *zfs.DatasetPath, hookReports []*hooks.Report, err error) {
+		doneAt := time.Now()
 		if err != nil {
 			hadErr = true
-			l.WithError(err).Error("cannot create snapshot")
 		}
-		doneAt := time.Now()
-
 		u(func(snapper *Snapper) {
+			progress := snapper.plan[fs]
 			progress.doneAt = doneAt
+			progress.hooks = hookReports
 			progress.state = SnapDone
 			if err != nil {
 				progress.state = SnapError
 				progress.err = err
 			}
+			snapper.plan[fs] = progress
 		})
 	}
+	createSnapshots(a.ctx, a.log, a.hooks, fss, snapname, false, started, done)
 
 	select {
 	case a.snapshotsTaken <- struct{}{}:
@@ -282,7 +412,7 @@ func wait(a args, u updater) state {
 	var sleepUntil time.Time
 	u(func(snapper *Snapper) {
 		lastTick := snapper.lastInvocation
-		snapper.sleepUntil = lastTick.Add(a.interval)
+		snapper.sleepUntil = a.nextTick(lastTick)
 		sleepUntil = snapper.sleepUntil
 	})
 
@@ -299,6 +429,100 @@ func wait(a args, u updater) state {
 	}
 }
 
+// createSnapshots creates snapshot snapname of all fss.
+// The snapshots of each pool are created atomically in a single zfs snapshot invocation.
+// If that fails, the snapshots of the pool are created one by one so that the error can be attributed
+// to the filesystem(s) that caused it.
+// The pre_snapshot hooks of all filesystems of a pool are run before and the post_snapshot hooks after
+// the pool's snapshots are created. If a fatal pre_snapshot hook fails, no snapshot of that filesystem is created.
+// If dryRun is set, only the hooks are run.
+// started is called before the snapshots of fss are created, done is called for each filesystem afterwards.
+func createSnapshots(ctx context.Context, l Logger, hs hooks.List, fss []*zfs.DatasetPath, snapname string, dryRun bool,
+	started func(fss []*zfs.DatasetPath), done func(fs *zfs.DatasetPath, hooks []*hooks.Report, err error)) {
+
+	for _, pool := range groupByPool(fss) {
+		pl := l.WithField("snap", snapname).WithField("pool", poolName(pool[0]))
+		started(pool)
+
+		errs := make(map[*zfs.DatasetPath]error, len(pool))
+		invocations := make(map[*zfs.DatasetPath]*hooks.Invocation, len(pool))
+		snap := make([]*zfs.DatasetPath, 0, len(pool))
+		for _, fs := range pool {
+			var err error
+			invocations[fs], err = hs.RunPre(ctx, pl.WithField("fs", fs.ToString()), fs, snapname, dryRun)
+			if err != nil {
+				errs[fs] = err
+				continue
+			}
+			snap = append(snap, fs)
+		}
+
+		if !dryRun {
+			snapshotPool(pl, snap, snapname, errs)
+		}
+
+		for _, fs := range pool {
+			if err := invocations[fs].RunPost(ctx, pl.WithField("fs", fs.ToString())); err != nil && errs[fs] == nil {
+				errs[fs] = err
+			}
+			done(fs, invocations[fs].Reports, errs[fs])
+		}
+	}
+}
+
+// snapshotPool creates snapshot snapname of fss, which must be in the same pool, and records errors in errs.
+func snapshotPool(pl Logger, fss []*zfs.DatasetPath, snapname string, errs map[*zfs.DatasetPath]error) {
+	if len(fss) == 0 {
+		return
+	}
+	pl.WithField("count", len(fss)).Debug("create snapshots atomically")
+	err := zfs.ZFSSnapshotAtomic(fss, snapname)
+	if err == nil {
+		return
+	}
+	if len(fss) == 1 {
+		pl.WithField("fs", fss[0].ToString()).WithError(err).Error("cannot create snapshot")
+		errs[fss[0]] = err
+		return
+	}
+
+	// zfs snapshot does not create any of the snapshots if one of them fails
+	pl.WithError(err).Warn("cannot create snapshots atomically, falling back to creating them one by one")
+	for _, fs := range fss {
+		fl := pl.WithField("fs", fs.ToString())
+		fl.Debug("create snapshot")
+		if err := zfs.ZFSSnapshot(fs, snapname, false); err != nil {
+			fl.WithError(err).Error("cannot create snapshot")
+			errs[fs] = err
+		}
+	}
+}
+
+func poolName(fs *zfs.DatasetPath) string {
+	return strings.SplitN(fs.ToString(), "/", 2)[0]
+}
+
+// groupByPool groups fss by pool, sorted by pool name and path
+func groupByPool(fss []*zfs.DatasetPath) (pools [][]*zfs.DatasetPath) {
+	sorted := make([]*zfs.DatasetPath, len(fss))
+	copy(sorted, fss)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ToString() < sorted[j].ToString()
+	})
+	for _, fs := range sorted {
+		if len(pools) > 0 && poolName(pools[len(pools)-1][0]) == poolName(fs) {
+			pools[len(pools)-1] = append(pools[len(pools)-1], fs)
+		} else {
+			pools = append(pools, []*zfs.DatasetPath{fs})
+		}
+	}
+	return pools
+}
+
+func timestampSuffix() string {
+	return time.Now().In(time.UTC).Format("20060102_150405_000")
+}
+
 func listFSes(mf *filters.DatasetMapFilter) (fss []*zfs.DatasetPath, err error) {
 	return zfs.ZFSListMapping(mf)
 }
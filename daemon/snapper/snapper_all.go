@@ -3,18 +3,26 @@ package snapper
 import (
 	"context"
 	"fmt"
+	"github.com/pkg/errors"
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/zfs"
+	"regexp"
 )
 
 // FIXME: properly abstract snapshotting:
 //   - split up things that trigger snapshotting from the mechanism
-//     - timer-based trigger (periodic)
+//     - timer-based trigger (periodic, cron)
 //     - call from control socket (manual)
 //     - mixed modes?
-//   - support a `zrepl snapshot JOBNAME` subcommand for config.SnapshottingManual
 type PeriodicOrManual struct {
 	s *Snapper
+
+	// for SnapshotNow
+	fsf    *filters.DatasetMapFilter
+	prefix string
+	hooks  hooks.List
 }
 
 func (s *PeriodicOrManual) Run(ctx context.Context, wakeUpCommon chan <- struct{}) {
@@ -23,6 +31,90 @@ func (s *PeriodicOrManual) Run(ctx context.Context, wakeUpCommon chan <- struct{
 	}
 }
 
+// Report returns nil for manual snapshotting.
+func (s *PeriodicOrManual) Report() *Report {
+	if s.s == nil {
+		return nil
+	}
+	return s.s.Report()
+}
+
+// ManualRequest is the argument to SnapshotNow.
+type ManualRequest struct {
+	// Subset of the job's filesystems to be snapshotted. All filesystems if empty.
+	Filesystems []string
+	// Snapshot name suffix (appended to the job's prefix). A timestamp if empty.
+	Suffix string
+	// Only run the hooks (with ZREPL_DRYRUN=true), do not take snapshots.
+	DryRun bool
+}
+
+// ManualResult is the outcome of SnapshotNow for a single filesystem.
+type ManualResult struct {
+	Filesystem string
+	Snapshot   string
+	Error      string // empty if the snapshot was created
+	Hooks      []*hooks.Report
+}
+
+// characters allowed in ZFS snapshot names besides alphanumerics, see zfs_namecheck.c
+var manualSuffixRegex = regexp.MustCompile(`^[a-zA-Z0-9_\-:.]+$`)
+
+// SnapshotNow takes a snapshot of the job's filesystems (or req.Filesystems) immediately,
+// independent of the snapshotting type.
+// A non-nil error means that no snapshots were taken,
+// errors for individual filesystems are reported in the results.
+func (s *PeriodicOrManual) SnapshotNow(ctx context.Context, req *ManualRequest) ([]*ManualResult, error) {
+	log := getLogger(ctx)
+
+	if req.Suffix != "" && !manualSuffixRegex.MatchString(req.Suffix) {
+		return nil, errors.Errorf("invalid snapshot name suffix %q", req.Suffix)
+	}
+
+	fss, err := listFSes(s.fsf)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list filesystems")
+	}
+	if len(req.Filesystems) > 0 {
+		byName := make(map[string]*zfs.DatasetPath, len(fss))
+		for _, fs := range fss {
+			byName[fs.ToString()] = fs
+		}
+		fss = make([]*zfs.DatasetPath, 0, len(req.Filesystems))
+		for _, name := range req.Filesystems {
+			fs, ok := byName[name]
+			if !ok {
+				return nil, errors.Errorf("filesystem %q does not exist or is not matched by the job's filesystem filter", name)
+			}
+			fss = append(fss, fs)
+		}
+	}
+
+	suffix := req.Suffix
+	if suffix == "" {
+		suffix = timestampSuffix()
+	}
+	snapname := fmt.Sprintf("%s%s", s.prefix, suffix)
+
+	results := make(map[*zfs.DatasetPath]*ManualResult, len(fss))
+	started := func(fss []*zfs.DatasetPath) {}
+	done := func(fs *zfs.DatasetPath, hookReports []*hooks.Report, err error) {
+		r := &ManualResult{Filesystem: fs.ToString(), Snapshot: snapname, Hooks: hookReports}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		results[fs] = r
+	}
+	log.WithField("snap", snapname).WithField("count", len(fss)).WithField("dry_run", req.DryRun).Info("create snapshots")
+	createSnapshots(ctx, log, s.hooks, fss, snapname, req.DryRun, started, done)
+
+	res := make([]*ManualResult, 0, len(fss))
+	for _, fs := range fss {
+		res = append(res, results[fs])
+	}
+	return res, nil
+}
+
 func FromConfig(g *config.Global, fsf *filters.DatasetMapFilter, in config.SnapshottingEnum) (*PeriodicOrManual, error) {
 	switch v := in.Ret.(type) {
 	case *config.SnapshottingPeriodic:
@@ -30,9 +122,22 @@ func FromConfig(g *config.Global, fsf *filters.DatasetMapFilter, in config.Snaps
 		if err != nil {
 			return nil, err
 		}
-		return &PeriodicOrManual{snapper}, nil
+		return &PeriodicOrManual{snapper, fsf, v.Prefix, snapper.args.hooks}, nil
+	case *config.SnapshottingCron:
+		snapper, err := CronFromConfig(g, fsf, v)
+		if err != nil {
+			return nil, err
+		}
+		return &PeriodicOrManual{snapper, fsf, v.Prefix, snapper.args.hooks}, nil
 	case *config.SnapshottingManual:
-		return &PeriodicOrManual{}, nil
+		if v.Prefix == "" {
+			return nil, errors.New("prefix must not be empty")
+		}
+		hs, err := hooks.ListFromConfig(v.Hooks)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid hooks")
+		}
+		return &PeriodicOrManual{nil, fsf, v.Prefix, hs}, nil
 	default:
 		return nil, fmt.Errorf("unknown snapshotting type %T", v)
 	}
@@ -0,0 +1,150 @@
+// Package transferlimit limits the number of concurrent zfs send/recv streams across all jobs of the daemon.
+package transferlimit
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/zrepl/zrepl/config"
+	"strings"
+	"sync"
+)
+
+// Limiter is a counting semaphore for transfers with a total limit and sub-limits per pool and per remote.
+// A limit of 0 means unlimited.
+type Limiter struct {
+	maxTotal, maxPerPool, maxPerRemote int
+
+	promInUse *prometheus.GaugeVec // labels: scope
+
+	mtx   sync.Mutex
+	total int
+	inUse map[string]int // by scope, see poolScope and remoteScope
+	// closed and replaced whenever slots are released
+	released chan struct{}
+}
+
+const scopeTotal = "total"
+
+func poolScope(pool string) string { return "pool:" + pool }
+
+func remoteScope(remote string) string { return "remote:" + remote }
+
+func FromConfig(in *config.GlobalTransfers) (*Limiter, error) {
+	if in.MaxConcurrent < 0 || in.PerPool < 0 || in.PerRemote < 0 {
+		return nil, errors.New("transfer limits must not be negative")
+	}
+	l := &Limiter{
+		maxTotal:     in.MaxConcurrent,
+		maxPerPool:   in.PerPool,
+		maxPerRemote: in.PerRemote,
+		inUse:        make(map[string]int),
+		released:     make(chan struct{}),
+	}
+	l.promInUse = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "zrepl",
+		Subsystem: "transfers",
+		Name:      "slots_in_use",
+		Help:      "number of concurrent zfs send/recv streams, in total and per pool and remote",
+	}, []string{"scope"})
+	return l, nil
+}
+
+func (l *Limiter) RegisterMetrics(registerer prometheus.Registerer) {
+	registerer.MustRegister(l.promInUse)
+}
+
+// scopes returns the scopes that a transfer touching pools and remote counts against.
+func scopes(pools []string, remote string) []string {
+	s := make([]string, 0, len(pools)+1)
+	seen := make(map[string]bool, len(pools))
+	for _, p := range pools {
+		if !seen[p] {
+			seen[p] = true
+			s = append(s, poolScope(p))
+		}
+	}
+	if remote != "" {
+		s = append(s, remoteScope(remote))
+	}
+	return s
+}
+
+// available reports whether a slot is available in all scopes. l.mtx must be held.
+func (l *Limiter) available(scopes []string) bool {
+	if l.maxTotal > 0 && l.total >= l.maxTotal {
+		return false
+	}
+	for _, s := range scopes {
+		max := l.maxPerPool
+		if strings.HasPrefix(s, "remote:") {
+			max = l.maxPerRemote
+		}
+		if max > 0 && l.inUse[s] >= max {
+			return false
+		}
+	}
+	return true
+}
+
+// add adds delta to the usage of all scopes. l.mtx must be held.
+func (l *Limiter) add(scopes []string, delta int) {
+	l.total += delta
+	l.promInUse.WithLabelValues(scopeTotal).Set(float64(l.total))
+	for _, s := range scopes {
+		l.inUse[s] += delta
+		l.promInUse.WithLabelValues(s).Set(float64(l.inUse[s]))
+		if l.inUse[s] == 0 {
+			delete(l.inUse, s)
+		}
+	}
+}
+
+// Acquire blocks until a slot is available in total, in each of pools and for remote (empty for local transfers),
+// or until ctx is done. The slot must be returned by calling release exactly once.
+// A nil Limiter does not limit transfers.
+func (l *Limiter) Acquire(ctx context.Context, pools []string, remote string) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	ss := scopes(pools, remote)
+	for {
+		l.mtx.Lock()
+		if l.available(ss) {
+			l.add(ss, 1)
+			l.mtx.Unlock()
+			var once sync.Once
+			return func() {
+				once.Do(func() {
+					l.mtx.Lock()
+					defer l.mtx.Unlock()
+					l.add(ss, -1)
+					close(l.released)
+					l.released = make(chan struct{})
+				})
+			}, nil
+		}
+		released := l.released
+		l.mtx.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+type contextKey int
+
+const contextKeyLimiter contextKey = iota
+
+func WithLimiter(ctx context.Context, l *Limiter) context.Context {
+	return context.WithValue(ctx, contextKeyLimiter, l)
+}
+
+// FromContext returns the Limiter in ctx, nil if there is none.
+func FromContext(ctx context.Context) *Limiter {
+	l, _ := ctx.Value(contextKeyLimiter).(*Limiter)
+	return l
+}
@@ -0,0 +1,83 @@
+package transferlimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zrepl/zrepl/config"
+)
+
+// acquired reports whether Acquire returns within a short time.
+func acquired(l *Limiter, pools []string, remote string) (release func(), ok bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	release, err := l.Acquire(ctx, pools, remote)
+	return release, err == nil
+}
+
+func TestLimiter(t *testing.T) {
+	l, err := FromConfig(&config.GlobalTransfers{MaxConcurrent: 3, PerPool: 2, PerRemote: 1})
+	require.NoError(t, err)
+
+	r1, ok := acquired(l, []string{"tank"}, "")
+	require.True(t, ok)
+	r2, ok := acquired(l, []string{"tank"}, "backup:8888")
+	require.True(t, ok)
+
+	// per pool
+	_, ok = acquired(l, []string{"tank"}, "")
+	assert.False(t, ok)
+	// per remote
+	_, ok = acquired(l, []string{"zroot"}, "backup:8888")
+	assert.False(t, ok)
+	// a pool used twice by the same transfer counts once
+	r3, ok := acquired(l, []string{"zroot", "zroot"}, "")
+	require.True(t, ok)
+	// total
+	_, ok = acquired(l, []string{"other"}, "")
+	assert.False(t, ok)
+
+	// a waiting Acquire proceeds once a slot is released
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		release, err := l.Acquire(context.Background(), []string{"tank"}, "")
+		assert.NoError(t, err)
+		release()
+	}()
+	time.Sleep(20 * time.Millisecond)
+	r1()
+	r1() // releasing twice is a no-op
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Acquire did not proceed after release")
+	}
+
+	r2()
+	r3()
+	l.mtx.Lock()
+	assert.Equal(t, 0, l.total)
+	assert.Empty(t, l.inUse)
+	l.mtx.Unlock()
+}
+
+func TestLimiterUnlimited(t *testing.T) {
+	l, err := FromConfig(&config.GlobalTransfers{})
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, ok := acquired(l, []string{"tank"}, "backup:8888")
+		assert.True(t, ok)
+	}
+
+	var nilLimiter *Limiter
+	release, err := nilLimiter.Acquire(context.Background(), []string{"tank"}, "")
+	assert.NoError(t, err)
+	release()
+
+	_, err = FromConfig(&config.GlobalTransfers{PerPool: -1})
+	assert.Error(t, err)
+}
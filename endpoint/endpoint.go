@@ -10,17 +10,34 @@ import (
 	"github.com/problame/go-streamrpc"
 	"github.com/zrepl/zrepl/replication"
 	"github.com/zrepl/zrepl/replication/pdu"
+	"github.com/zrepl/zrepl/util/ratelimit"
 	"github.com/zrepl/zrepl/zfs"
 	"io"
+	"strings"
 )
 
 // Sender implements replication.ReplicationEndpoint for a sending side
 type Sender struct {
 	FSFilter                zfs.DatasetFilter
+	sendFlags               zfs.ZFSSendFlags
+	bandwidth               []*ratelimit.Bucket
+	holdTag                 string // empty if replicated snapshots are not held
+	bookmarkJob             string // empty if replicated snapshots are not bookmarked
 }
 
-func NewSender(fsf zfs.DatasetFilter) *Sender {
-	return &Sender{FSFilter: fsf}
+// NewSender returns a Sender that uses sendFlags for every send.
+func NewSender(fsf zfs.DatasetFilter, sendFlags zfs.ZFSSendFlags) *Sender {
+	return &Sender{FSFilter: fsf, sendFlags: sendFlags}
+}
+
+// LimitBandwidth limits every send stream to the rates of buckets, nil buckets are ignored.
+// Buckets may be shared with other Senders, which then share the bucket's rate.
+func (s *Sender) LimitBandwidth(buckets ...*ratelimit.Bucket) {
+	for _, b := range buckets {
+		if b != nil {
+			s.bandwidth = append(s.bandwidth, b)
+		}
+	}
 }
 
 func (s *Sender) filterCheckFS(fs string) (*zfs.DatasetPath, error) {
@@ -50,7 +67,7 @@ func (p *Sender) ListFilesystems(ctx context.Context) ([]*pdu.Filesystem, error)
 	for i := range fss {
 		rfss[i] = &pdu.Filesystem{
 			Path: fss[i].ToString(),
-			// FIXME: not supporting ResumeToken yet
+			// ResumeToken does not make sense from Sender
 		}
 	}
 	return rfss, nil
@@ -72,14 +89,55 @@ func (p *Sender) ListFilesystemVersions(ctx context.Context, fs string) ([]*pdu.
 	return rfsvs, nil
 }
 
+// HoldReplicated makes the Sender hold the snapshot that the replication cursor is advanced to with tag
+// and release tag from the snapshot that it previously held, see zfs.ReplicationHoldTag.
+func (s *Sender) HoldReplicated(tag string) {
+	s.holdTag = tag
+}
+
+// BookmarkReplicated makes the Sender create the bookmark zfs.ReplicationBookmarkName(snapshot, job)
+// for every snapshot that the replication cursor is advanced to.
+// Unlike the replication cursor, these bookmarks are kept until they are pruned.
+func (s *Sender) BookmarkReplicated(job string) {
+	s.bookmarkJob = job
+}
+
 func (p *Sender) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.ReadCloser, error) {
-	_, err := p.filterCheckFS(r.Filesystem)
+	dp, err := p.filterCheckFS(r.Filesystem)
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := p.checkSendFlags(r); err != nil {
+		return nil, nil, err
+	}
+
+	// Per protocol, the GUIDs in the token must match From and To, otherwise
+	// we could be tricked into sending a filesystem that is not permitted by FSFilter.
+	// A token that cannot be decoded or that was created with different send flags is not used,
+	// the receiver will then discard its partial state.
+	token := ""
+	if r.ResumeToken != "" {
+		rt, err := zfs.ParseResumeToken(ctx, r.ResumeToken)
+		if err != nil {
+			getLogger(ctx).
+				WithError(err).
+				WithField("fs", r.Filesystem).
+				Warn("cannot decode resume token, falling back to non-resumable send")
+		} else if err := checkResumeTokenFlags(rt, p.sendFlags); err != nil {
+			getLogger(ctx).
+				WithError(err).
+				WithField("fs", r.Filesystem).
+				Warn("cannot use resume token, falling back to non-resumable send")
+		} else {
+			if err := checkResumeToken(dp, r, rt); err != nil {
+				return nil, nil, err
+			}
+			token = r.ResumeToken
+		}
+	}
 
 	if r.DryRun {
-		si, err := zfs.ZFSSendDry(r.Filesystem, r.From, r.To, "")
+		si, err := zfs.ZFSSendDry(r.Filesystem, r.From, r.To, token, p.sendFlags)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -89,12 +147,97 @@ func (p *Sender) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.Rea
 		}
 		return &pdu.SendRes{ExpectedSize: expSize}, nil, nil
 	} else {
-		stream, err := zfs.ZFSSend(ctx, r.Filesystem, r.From, r.To, "")
+		stream, err := zfs.ZFSSend(ctx, r.Filesystem, r.From, r.To, token, p.sendFlags)
 		if err != nil {
 			return nil, nil, err
 		}
-		return &pdu.SendRes{}, stream, nil
+		if len(p.bandwidth) > 0 {
+			stream = ratelimit.NewReader(ctx, stream, p.bandwidth...)
+		}
+		return &pdu.SendRes{UsedResumeToken: token != ""}, stream, nil
+	}
+}
+
+// checkSendFlags returns an error if r requires stream features that p is not configured to use.
+func (p *Sender) checkSendFlags(r *pdu.SendReq) error {
+	if r.Dedup {
+		return errors.New("deduplicated send streams are not supported")
 	}
+	var missing []string
+	check := func(required, configured bool, feature string) {
+		if required && !configured {
+			missing = append(missing, feature)
+		}
+	}
+	check(r.Compress, p.sendFlags.Compressed, "compressed")
+	check(r.LargeBlocks, p.sendFlags.LargeBlocks, "large_blocks")
+	check(r.EmbeddedData, p.sendFlags.EmbeddedData, "embedded_data")
+	check(r.Raw, p.sendFlags.Raw, "raw")
+	check(r.Properties, p.sendFlags.Properties, "send_properties")
+	if len(missing) > 0 {
+		return errors.Errorf("sender is not configured to send with %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkResumeToken returns nil if rt resumes a send from r.From to r.To on fs.
+func checkResumeToken(fs *zfs.DatasetPath, r *pdu.SendReq, rt *zfs.ResumeToken) error {
+	fsvs, err := zfs.ZFSListFilesystemVersions(fs, nil)
+	if err != nil {
+		return err
+	}
+	var from, to *zfs.FilesystemVersion
+	for i := range fsvs {
+		switch fsvs[i].String() {
+		case r.From:
+			from = &fsvs[i]
+		case r.To:
+			to = &fsvs[i]
+		}
+	}
+
+	if to == nil || to.Type != zfs.Snapshot {
+		return errors.Errorf("snapshot %q does not exist", r.To)
+	}
+	if !rt.HasToGUID || rt.ToGUID != to.Guid {
+		return errors.Errorf("resume token does not refer to %q", r.To)
+	}
+	if r.From == "" {
+		if rt.HasFromGUID {
+			return errors.New("resume token is for an incremental send, but full send was requested")
+		}
+		return nil
+	}
+	if from == nil {
+		return errors.Errorf("version %q does not exist", r.From)
+	}
+	if !rt.HasFromGUID || rt.FromGUID != from.Guid {
+		return errors.Errorf("resume token does not refer to incremental send from %q", r.From)
+	}
+	return nil
+}
+
+// checkResumeTokenFlags returns an error if the send resumed by rt uses stream features that differ from flags.
+// zfs send -t ignores the configured flags and continues with the features of the interrupted send.
+// Raw must match since it decides whether encrypted data is sent in plaintext.
+// The other features are only recorded in the token if the dataset uses them, so they must be allowed by flags
+// (raw sends imply compressed and embedded data).
+func checkResumeTokenFlags(rt *zfs.ResumeToken, flags zfs.ZFSSendFlags) error {
+	var differ []string
+	check := func(differs bool, feature string) {
+		if differs {
+			differ = append(differ, feature)
+		}
+	}
+	check(rt.RawOK != flags.Raw, "raw")
+	check(rt.CompressOK && !(flags.Compressed || flags.Raw), "compressed")
+	check(rt.LargeBlockOK && !flags.LargeBlocks, "large_blocks")
+	check(rt.EmbedOK && !(flags.EmbeddedData || flags.Raw), "embedded_data")
+	if len(differ) > 0 {
+		return errors.Errorf("resume token was created with different send flags than the sender is configured to use: %s",
+			strings.Join(differ, ", "))
+	}
+	return nil
 }
 
 func (p *Sender) DestroySnapshots(ctx context.Context, req *pdu.DestroySnapshotsReq) (*pdu.DestroySnapshotsRes, error) {
@@ -102,7 +245,15 @@ func (p *Sender) DestroySnapshots(ctx context.Context, req *pdu.DestroySnapshots
 	if err != nil {
 		return nil, err
 	}
-	return doDestroySnapshots(ctx, dp, req.Snapshots)
+	return doDestroySnapshots(ctx, dp, req.Snapshots, true)
+}
+
+func (p *Sender) FilesystemSpace(ctx context.Context, req *pdu.FilesystemSpaceReq) (*pdu.FilesystemSpaceRes, error) {
+	dp, err := p.filterCheckFS(req.Filesystem)
+	if err != nil {
+		return nil, err
+	}
+	return doFilesystemSpace(dp)
 }
 
 func (p *Sender) ReplicationCursor(ctx context.Context, req *pdu.ReplicationCursorReq) (*pdu.ReplicationCursorRes, error) {
@@ -122,16 +273,61 @@ func (p *Sender) ReplicationCursor(ctx context.Context, req *pdu.ReplicationCurs
 		}
 		return &pdu.ReplicationCursorRes{Result: &pdu.ReplicationCursorRes_Guid{Guid: cursor.Guid}}, nil
 	case *pdu.ReplicationCursorReq_Set:
+		// hold before the cursor is advanced so that the replicated snapshot is never unprotected
+		if p.holdTag != "" {
+			if err := zfs.ZFSHold(dp, op.Set.Snapshot, p.holdTag); err != nil {
+				return nil, errors.Wrap(err, "cannot hold replicated snapshot")
+			}
+		}
+		if p.bookmarkJob != "" {
+			if err := zfs.ZFSBookmarkReplicated(dp, op.Set.Snapshot, p.bookmarkJob); err != nil {
+				return nil, errors.Wrap(err, "cannot bookmark replicated snapshot")
+			}
+		}
 		guid, err := zfs.ZFSSetReplicationCursor(dp, op.Set.Snapshot)
 		if err != nil {
 			return nil, err
 		}
+		if p.holdTag != "" {
+			if err := releaseStaleHolds(dp, op.Set.Snapshot, p.holdTag); err != nil {
+				getLogger(ctx).
+					WithError(err).
+					WithField("fs", dp.ToString()).
+					WithField("tag", p.holdTag).
+					Warn("cannot release hold on previously replicated snapshot")
+			}
+		}
 		return &pdu.ReplicationCursorRes{Result: &pdu.ReplicationCursorRes_Guid{Guid: guid}}, nil
 	default:
 		return nil, errors.Errorf("unknown op %T", op)
 	}
 }
 
+// releaseStaleHolds releases tag from all snapshots of fs except current.
+func releaseStaleHolds(fs *zfs.DatasetPath, current, tag string) error {
+	versions, err := zfs.ZFSListFilesystemVersions(fs, nil)
+	if err != nil {
+		return err
+	}
+	var held []string
+	for _, v := range versions {
+		if v.Type == zfs.Snapshot && v.UserRefs > 0 && v.Name != current {
+			held = append(held, v.ToAbsPath(fs))
+		}
+	}
+	holds, err := zfs.ZFSHolds(held...)
+	if err != nil {
+		return err
+	}
+	var stale []string
+	for _, h := range holds {
+		if h.Tag == tag {
+			stale = append(stale, h.Snapshot)
+		}
+	}
+	return zfs.ZFSRelease(tag, stale...)
+}
+
 type FSFilter interface { // FIXME unused
 	Filter(path *zfs.DatasetPath) (pass bool, err error)
 }
@@ -146,14 +342,19 @@ type FSMap interface { // FIXME unused
 
 // Receiver implements replication.ReplicationEndpoint for a receiving side
 type Receiver struct {
-	root *zfs.DatasetPath
+	root       *zfs.DatasetPath
+	recvProps  zfs.ZFSRecvProperties
 }
 
-func NewReceiver(rootDataset *zfs.DatasetPath) (*Receiver, error) {
+// NewReceiver returns a Receiver that applies recvProps to every received stream.
+func NewReceiver(rootDataset *zfs.DatasetPath, recvProps zfs.ZFSRecvProperties) (*Receiver, error) {
 	if rootDataset.Length() <= 0 {
 		return nil, errors.New("root dataset must not be an empty path")
 	}
-	return &Receiver{root: rootDataset.Copy()}, nil
+	if err := recvProps.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid receive properties")
+	}
+	return &Receiver{root: rootDataset.Copy(), recvProps: recvProps}, nil
 }
 
 type subroot struct {
@@ -199,8 +400,16 @@ func (e *Receiver) ListFilesystems(ctx context.Context) ([]*pdu.Filesystem, erro
 		if ph {
 			continue
 		}
+		token, err := zfs.ZFSGetReceiveResumeToken(a)
+		if err != nil {
+			getLogger(ctx).
+				WithError(err).
+				WithField("fs", a).
+				Error("cannot get receive resume token")
+			return nil, errors.New("server error, see logs") // don't leak path
+		}
 		a.TrimPrefix(e.root)
-		fss = append(fss, &pdu.Filesystem{Path: a.ToString()})
+		fss = append(fss, &pdu.Filesystem{Path: a.ToString(), ResumeToken: token})
 	}
 	return fss, nil
 }
@@ -272,10 +481,33 @@ func (e *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, sendStream
 		}
 	}
 
-	args := make([]string, 0, 1)
+	if req.Rollback {
+		// the client destroyed the snapshots that made this filesystem diverge from the sender
+		getLogger(ctx).Info("roll back to most recent snapshot before receive")
+		needForceRecv = true
+	}
+
+	if req.ClearResumeToken {
+		// The sender did not resume from our partially received state,
+		// zfs recv would refuse the stream if we kept it around.
+		if token, err := zfs.ZFSGetReceiveResumeToken(lp); err == nil && token != "" {
+			getLogger(ctx).Info("abort partially received state")
+			if err := zfs.ZFSRecvClearResumeToken(lp.ToString()); err != nil {
+				getLogger(ctx).
+					WithError(err).
+					Error("cannot clear receive resume token")
+				return err
+			}
+		}
+	}
+
+	// always receive resumable so that interrupted sends can be resumed in the next attempt
+	args := make([]string, 0, 2)
+	args = append(args, "-s")
 	if needForceRecv {
 		args = append(args, "-F")
 	}
+	args = append(args, e.recvProps.Args()...)
 
 	getLogger(ctx).Debug("start receive command")
 
@@ -295,13 +527,43 @@ func (e *Receiver) DestroySnapshots(ctx context.Context, req *pdu.DestroySnapsho
 	if err != nil {
 		return nil, err
 	}
-	return doDestroySnapshots(ctx, lp, req.Snapshots)
+	return doDestroySnapshots(ctx, lp, req.Snapshots, false)
+}
+
+func (e *Receiver) FilesystemSpace(ctx context.Context, req *pdu.FilesystemSpaceReq) (*pdu.FilesystemSpaceRes, error) {
+	lp, err := subroot{e.root}.MapToLocal(req.Filesystem)
+	if err != nil {
+		return nil, err
+	}
+	return doFilesystemSpace(lp)
+}
+
+func doFilesystemSpace(lp *zfs.DatasetPath) (*pdu.FilesystemSpaceRes, error) {
+	space, err := zfs.ZFSGetSpace(lp)
+	if err != nil {
+		return nil, err
+	}
+	return &pdu.FilesystemSpaceRes{
+		Used:          space.Used,
+		Available:     space.Available,
+		PoolUsed:      space.PoolUsed,
+		PoolAvailable: space.PoolAvailable,
+		Pool:          space.Pool,
+	}, nil
 }
 
-func doDestroySnapshots(ctx context.Context, lp *zfs.DatasetPath, snaps []*pdu.FilesystemVersion) (*pdu.DestroySnapshotsRes, error) {
+// doDestroySnapshots destroys snaps, which may include bookmarks if allowBookmarks is set.
+// The replication cursor bookmark is never destroyed.
+func doDestroySnapshots(ctx context.Context, lp *zfs.DatasetPath, snaps []*pdu.FilesystemVersion, allowBookmarks bool) (*pdu.DestroySnapshotsRes, error) {
 	fsvs := make([]*zfs.FilesystemVersion, len(snaps))
 	for i, fsv := range snaps {
-		if fsv.Type != pdu.FilesystemVersion_Snapshot {
+		switch {
+		case fsv.Type == pdu.FilesystemVersion_Snapshot:
+		case fsv.Type == pdu.FilesystemVersion_Bookmark && allowBookmarks:
+			if fsv.Name == zfs.ReplicationCursorBookmarkName {
+				return nil, fmt.Errorf("bookmark %q is the replication cursor", fsv.Name)
+			}
+		default:
 			return nil, fmt.Errorf("version %q is not a snapshot", fsv.Name)
 		}
 		var err error
@@ -338,15 +600,29 @@ const (
 	RPCSend                   = "Send"
 	RPCSDestroySnapshots      = "DestroySnapshots"
 	RPCReplicationCursor      = "ReplicationCursor"
+	RPCFilesystemSpace        = "FilesystemSpace"
 )
 
 // Remote implements an endpoint stub that uses streamrpc as a transport.
 type Remote struct {
-	c *streamrpc.Client
+	c         *streamrpc.Client
+	bandwidth []*ratelimit.Bucket // empty if received send streams are not limited
 }
 
 func NewRemote(c *streamrpc.Client) Remote {
-	return Remote{c}
+	return Remote{c: c}
+}
+
+// LimitBandwidth returns a copy of s that limits every received send stream to the rates of buckets,
+// nil buckets are ignored.
+func (s Remote) LimitBandwidth(buckets ...*ratelimit.Bucket) Remote {
+	s.bandwidth = nil
+	for _, b := range buckets {
+		if b != nil {
+			s.bandwidth = append(s.bandwidth, b)
+		}
+	}
+	return s
 }
 
 func (s Remote) ListFilesystems(ctx context.Context) ([]*pdu.Filesystem, error) {
@@ -414,6 +690,9 @@ func (s Remote) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.Read
 		rs.Close()
 		return nil, nil, err
 	}
+	if rs != nil && len(s.bandwidth) > 0 {
+		return &res, ratelimit.NewReader(ctx, rs, s.bandwidth...), nil
+	}
 	return &res, rs, nil
 }
 
@@ -479,6 +758,35 @@ func (s Remote) ReplicationCursor(ctx context.Context, req *pdu.ReplicationCurso
 	return &res, nil
 }
 
+func (s Remote) FilesystemSpace(ctx context.Context, req *pdu.FilesystemSpaceReq) (*pdu.FilesystemSpaceRes, error) {
+	b, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	rb, rs, err := s.c.RequestReply(ctx, RPCFilesystemSpace, bytes.NewBuffer(b), nil)
+	if err != nil {
+		return nil, err
+	}
+	if rs != nil {
+		rs.Close()
+		return nil, errors.New("response contains unexpected stream")
+	}
+	var res pdu.FilesystemSpaceRes
+	if err := proto.Unmarshal(rb.Bytes(), &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// spaceEndpoint is implemented by Sender, Receiver and Remote for space-aware pruning.
+type spaceEndpoint interface {
+	FilesystemSpace(ctx context.Context, req *pdu.FilesystemSpaceReq) (*pdu.FilesystemSpaceRes, error)
+}
+
+var _ spaceEndpoint = &Sender{}
+var _ spaceEndpoint = &Receiver{}
+var _ spaceEndpoint = Remote{}
+
 // Handler implements the server-side streamrpc.HandlerFunc for a Remote endpoint stub.
 type Handler struct {
 	ep replication.Endpoint
@@ -608,6 +916,27 @@ func (a *Handler) Handle(ctx context.Context, endpoint string, reqStructured *by
 		}
 		return bytes.NewBuffer(b), nil, nil
 
+	case RPCFilesystemSpace:
+
+		spaceEP, ok := a.ep.(spaceEndpoint)
+		if !ok {
+			goto Err
+		}
+
+		var req pdu.FilesystemSpaceReq
+		if err := proto.Unmarshal(reqStructured.Bytes(), &req); err != nil {
+			return nil, nil, err
+		}
+		res, err := spaceEP.FilesystemSpace(ctx, &req)
+		if err != nil {
+			return nil, nil, err
+		}
+		b, err := proto.Marshal(res)
+		if err != nil {
+			return nil, nil, err
+		}
+		return bytes.NewBuffer(b), nil, nil
+
 	}
 Err:
 	return nil, nil, errors.New("no handler for given endpoint")
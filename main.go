@@ -11,11 +11,14 @@ func init() {
 	cli.AddSubcommand(daemon.DaemonCmd)
 	cli.AddSubcommand(client.StatusCmd)
 	cli.AddSubcommand(client.SignalCmd)
+	cli.AddSubcommand(client.SnapshotCmd)
 	cli.AddSubcommand(client.StdinserverCmd)
 	cli.AddSubcommand(client.ConfigcheckCmd)
 	cli.AddSubcommand(client.VersionCmd)
 	cli.AddSubcommand(client.PprofCmd)
 	cli.AddSubcommand(client.TestCmd)
+	cli.AddSubcommand(client.HoldsCmd)
+	cli.AddSubcommand(client.PruneCmd)
 }
 
 func main() {
@@ -18,6 +18,10 @@ type KeepGrid struct {
 	re *regexp.Regexp
 }
 
+func (p *KeepGrid) String() string {
+	return fmt.Sprintf("grid(%s)", p.re)
+}
+
 func NewKeepGrid(in *config.PruneGrid) (p *KeepGrid, err error) {
 
 	if in.Regex == "" {
@@ -68,14 +72,43 @@ func (a retentionGridAdaptor) LessThan(b retentiongrid.Entry) bool {
 	return a.Date().Before(b.Date())
 }
 
+// InScope returns whether s matches the regex: other snapshots are neither kept nor destroyed by the grid.
+func (p *KeepGrid) InScope(s Snapshot) bool {
+	return p.re.MatchString(s.Name())
+}
+
 // Prune filters snapshots with the retention grid.
 func (p *KeepGrid) KeepRule(snaps []Snapshot) (destroyList []Snapshot) {
+	destroyList, _ = p.fit(snaps)
+	return destroyList
+}
+
+var _ KeepReasoner = (*KeepGrid)(nil)
+
+// KeepReasons returns the retention grid interval that each kept snapshot is kept in,
+// counting from 0 for the most recent interval.
+func (p *KeepGrid) KeepReasons(snaps []Snapshot) map[Snapshot]string {
+	_, bucketOf := p.fit(snaps)
+	reasons := make(map[Snapshot]string, len(bucketOf))
+	for s, b := range bucketOf {
+		if b == retentiongrid.BucketNow {
+			reasons[s] = "most recent"
+		} else {
+			reasons[s] = fmt.Sprintf("bucket %d", b)
+		}
+	}
+	return reasons
+}
+
+// fit returns the snapshots to destroy and the retention grid interval of the snapshots that are kept.
+// Snapshots that do not match the regex are neither.
+func (p *KeepGrid) fit(snaps []Snapshot) (destroyList []Snapshot, bucketOf map[Snapshot]int) {
 
 	snaps = filterSnapList(snaps, func(snapshot Snapshot) bool {
-		return p.re.MatchString(snapshot.Name())
+		return p.InScope(snapshot)
 	})
 	if len(snaps) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	// Build adaptors for retention grid
@@ -91,12 +124,16 @@ func (p *KeepGrid) KeepRule(snaps []Snapshot) (destroyList []Snapshot) {
 	now := adaptors[len(adaptors)-1].Date()
 
 	// Evaluate retention grid
-	_, removea := p.retentionGrid.FitEntries(now, adaptors)
+	_, removea, bucketOfa := p.retentionGrid.FitEntriesBuckets(now, adaptors)
 
 	// Revert adaptors
 	destroyList = make([]Snapshot, len(removea))
 	for i := range removea {
 		destroyList[i] = removea[i].(retentionGridAdaptor).Snapshot
 	}
-	return destroyList
+	bucketOf = make(map[Snapshot]int, len(bucketOfa))
+	for e, b := range bucketOfa {
+		bucketOf[e.(retentionGridAdaptor).Snapshot] = b
+	}
+	return destroyList, bucketOf
 }
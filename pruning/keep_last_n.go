@@ -1,6 +1,7 @@
 package pruning
 
 import (
+	"fmt"
 	"github.com/pkg/errors"
 	"sort"
 )
@@ -16,6 +17,10 @@ func NewKeepLastN(n int) (*KeepLastN, error) {
 	return &KeepLastN{n}, nil
 }
 
+func (k KeepLastN) String() string {
+	return fmt.Sprintf("last_n(%d)", k.n)
+}
+
 func (k KeepLastN) KeepRule(snaps []Snapshot) (destroyList []Snapshot) {
 
 	if k.n > len(snaps) {
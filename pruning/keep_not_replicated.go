@@ -10,6 +10,8 @@ func (*KeepNotReplicated) KeepRule(snaps []Snapshot) (destroyList []Snapshot) {
 	})
 }
 
+func (*KeepNotReplicated) String() string { return "not_replicated" }
+
 func NewKeepNotReplicated() *KeepNotReplicated {
 	return &KeepNotReplicated{}
 }
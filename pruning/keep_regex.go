@@ -1,6 +1,7 @@
 package pruning
 
 import (
+	"fmt"
 	"regexp"
 )
 
@@ -27,6 +28,13 @@ func MustKeepRegex(expr string, negate bool) *KeepRegex {
 	return k
 }
 
+func (k *KeepRegex) String() string {
+	if k.negate {
+		return fmt.Sprintf("regex(negate %s)", k.expr)
+	}
+	return fmt.Sprintf("regex(%s)", k.expr)
+}
+
 func (k *KeepRegex) KeepRule(snaps []Snapshot) []Snapshot {
 	return filterSnapList(snaps, func(s Snapshot) bool {
 		if k.negate {
@@ -0,0 +1,80 @@
+package pruning
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/zrepl/zrepl/config"
+	"regexp"
+)
+
+// KeepScoped applies a KeepRule only to the snapshots whose name matches a regex.
+type KeepScoped struct {
+	re   *regexp.Regexp
+	rule KeepRule
+}
+
+var _ ScopedKeepRule = &KeepScoped{}
+
+// NewKeepScoped scopes rule to the snapshots matching regex.
+// rule is returned unchanged if regex is empty.
+func NewKeepScoped(regex string, rule KeepRule) (KeepRule, error) {
+	if regex == "" {
+		return rule, nil
+	}
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid regex")
+	}
+	return &KeepScoped{re, rule}, nil
+}
+
+func (k *KeepScoped) String() string {
+	return fmt.Sprintf("%s matching %s", k.rule, k.re)
+}
+
+func (k *KeepScoped) InScope(s Snapshot) bool {
+	return k.re.MatchString(s.Name())
+}
+
+func (k *KeepScoped) KeepRule(snaps []Snapshot) (destroyList []Snapshot) {
+	return k.rule.KeepRule(filterSnapList(snaps, k.InScope))
+}
+
+// ScopeMigrationNotes describes the grid rules of in that keep fewer snapshots
+// than before all keep rules were scoped: a grid used to keep all snapshots
+// that do not match its regex, now it neither keeps nor destroys them.
+// The notes are empty if in behaves as before.
+func ScopeMigrationNotes(in []config.PruningEnum) (notes []string) {
+	for i := range in {
+		grid, ok := in[i].Ret.(*config.PruneGrid)
+		if !ok {
+			continue
+		}
+		affected, migrated := false, false
+		for j := range in {
+			if j == i {
+				continue
+			}
+			switch v := in[j].Ret.(type) {
+			case *config.PruneGrid:
+				affected = affected || v.Regex != grid.Regex
+			case *config.PruneKeepRegex:
+				if v.Negate && v.Regex == grid.Regex {
+					// keeps the snapshots that the grid does not match
+					migrated = true
+				} else {
+					affected = true
+				}
+			default:
+				affected = true
+			}
+		}
+		if affected && !migrated {
+			notes = append(notes, fmt.Sprintf(
+				"rule #%d (grid) no longer keeps snapshots that do not match regex %q, "+
+					"add rule {type: regex, negate: true, regex: %q} to keep them",
+				i, grid.Regex, grid.Regex))
+		}
+	}
+	return notes
+}
@@ -0,0 +1,113 @@
+package pruning
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/zrepl/zrepl/config"
+	"testing"
+	"time"
+)
+
+func TestKeepScoped(t *testing.T) {
+
+	o := func(minutes int) time.Time {
+		return time.Unix(123, 0).Add(time.Duration(minutes) * time.Minute)
+	}
+
+	inputs := []Snapshot{
+		stubSnap{name: "zrepl_1", date: o(10), replicated: true},
+		stubSnap{name: "pre-upgrade_2", date: o(20), replicated: true},
+		stubSnap{name: "zrepl_3", date: o(30), replicated: true},
+		stubSnap{name: "zrepl_4", date: o(40), replicated: true},
+		stubSnap{name: "zrepl_5", date: o(50)},
+	}
+
+	mustScoped := func(regex string, rule KeepRule) KeepRule {
+		k, err := NewKeepScoped(regex, rule)
+		if err != nil {
+			panic(err)
+		}
+		return k
+	}
+
+	tcs := map[string]testCase{
+		"unscopedCountsAll": {
+			inputs: inputs,
+			rules: []KeepRule{
+				KeepLastN{2},
+			},
+			expDestroy: map[string]bool{
+				"zrepl_1": true, "pre-upgrade_2": true, "zrepl_3": true,
+			},
+		},
+		"scopedPerPrefix": {
+			inputs: inputs,
+			rules: []KeepRule{
+				mustScoped("^zrepl_", KeepLastN{2}),
+				mustScoped("^pre-upgrade_", KeepLastN{1}),
+			},
+			expDestroy: map[string]bool{
+				"zrepl_1": true, "zrepl_3": true,
+			},
+		},
+		"outOfScopeIsNotDestroyed": {
+			inputs: inputs,
+			rules: []KeepRule{
+				mustScoped("^zrepl_", KeepLastN{1}),
+			},
+			expDestroy: map[string]bool{
+				"zrepl_1": true, "zrepl_3": true, "zrepl_4": true,
+			},
+		},
+		"outOfScopeIsNotKept": {
+			inputs: inputs,
+			rules: []KeepRule{
+				NewKeepNotReplicated(),
+				mustScoped("^zrepl_", KeepLastN{2}),
+			},
+			expDestroy: map[string]bool{
+				"zrepl_1": true, "pre-upgrade_2": true, "zrepl_3": true,
+			},
+		},
+	}
+
+	testTable(tcs, t)
+
+	t.Run("emptyRegexIsUnscoped", func(t *testing.T) {
+		rule := KeepLastN{2}
+		k, err := NewKeepScoped("", rule)
+		assert.NoError(t, err)
+		assert.Equal(t, rule, k)
+	})
+
+	t.Run("invalidRegex", func(t *testing.T) {
+		_, err := NewKeepScoped("(", KeepLastN{2})
+		assert.Error(t, err)
+	})
+
+	t.Run("decisions", func(t *testing.T) {
+		rules := []KeepRule{mustScoped("^pre-upgrade_", KeepLastN{1})}
+		_, decisions := PruneSnapshotsDecisions(inputs[:2], rules)
+		assert.Equal(t, []Decision{
+			{Snapshot: inputs[0]},
+			{Snapshot: inputs[1], KeptBy: []KeptBy{{0, "last_n(1) matching ^pre-upgrade_", ""}}},
+		}, decisions)
+	})
+
+}
+
+func TestScopeMigrationNotes(t *testing.T) {
+	grid := func(regex string) config.PruningEnum {
+		return config.PruningEnum{Ret: &config.PruneGrid{Regex: regex}}
+	}
+	lastN := config.PruningEnum{Ret: &config.PruneKeepLastN{Count: 10}}
+	negated := func(regex string) config.PruningEnum {
+		return config.PruningEnum{Ret: &config.PruneKeepRegex{Regex: regex, Negate: true}}
+	}
+
+	assert.Empty(t, ScopeMigrationNotes(nil))
+	assert.Empty(t, ScopeMigrationNotes([]config.PruningEnum{grid("^zrepl_")}))
+	assert.Empty(t, ScopeMigrationNotes([]config.PruningEnum{grid("^zrepl_"), grid("^zrepl_")}))
+	assert.Len(t, ScopeMigrationNotes([]config.PruningEnum{lastN, grid("^zrepl_")}), 1)
+	assert.Len(t, ScopeMigrationNotes([]config.PruningEnum{grid("^zrepl_"), grid("^manual_")}), 2)
+	assert.Empty(t, ScopeMigrationNotes([]config.PruningEnum{lastN, grid("^zrepl_"), negated("^zrepl_")}))
+}
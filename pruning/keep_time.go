@@ -0,0 +1,86 @@
+package pruning
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"regexp"
+	"time"
+)
+
+// keepTimeWindow keeps the snapshots whose age is (not) within d.
+// If re is not nil, it only applies to snapshots that match re.
+type keepTimeWindow struct {
+	d      time.Duration
+	re     *regexp.Regexp
+	within bool
+}
+
+// KeepWithin keeps snapshots that are younger than a duration.
+type KeepWithin struct{ keepTimeWindow }
+
+// KeepOlderThan keeps snapshots that are older than a duration.
+type KeepOlderThan struct{ keepTimeWindow }
+
+var _ ScopedKeepRule = &KeepWithin{}
+var _ ScopedKeepRule = &KeepOlderThan{}
+
+func newKeepTimeWindow(d time.Duration, regex string, within bool) (keepTimeWindow, error) {
+	k := keepTimeWindow{d: d, within: within}
+	if d <= 0 {
+		return k, errors.Errorf("must specify positive duration, got %s", d)
+	}
+	if regex != "" {
+		re, err := regexp.Compile(regex)
+		if err != nil {
+			return k, errors.Wrap(err, "invalid regex")
+		}
+		k.re = re
+	}
+	return k, nil
+}
+
+// NewKeepWithin returns a rule that keeps the snapshots that are younger than d,
+// scoped to the snapshots matching regex (all snapshots if regex is empty).
+func NewKeepWithin(d time.Duration, regex string) (*KeepWithin, error) {
+	k, err := newKeepTimeWindow(d, regex, true)
+	if err != nil {
+		return nil, err
+	}
+	return &KeepWithin{k}, nil
+}
+
+// NewKeepOlderThan returns a rule that keeps the snapshots that are older than d,
+// scoped to the snapshots matching regex (all snapshots if regex is empty).
+func NewKeepOlderThan(d time.Duration, regex string) (*KeepOlderThan, error) {
+	k, err := newKeepTimeWindow(d, regex, false)
+	if err != nil {
+		return nil, err
+	}
+	return &KeepOlderThan{k}, nil
+}
+
+func (k *KeepWithin) String() string { return k.format("within") }
+
+func (k *KeepOlderThan) String() string { return k.format("older_than") }
+
+func (k *keepTimeWindow) format(name string) string {
+	if k.re == nil {
+		return fmt.Sprintf("%s(%s)", name, k.d)
+	}
+	return fmt.Sprintf("%s(%s %s)", name, k.d, k.re)
+}
+
+func (k *keepTimeWindow) InScope(s Snapshot) bool {
+	return k.re == nil || k.re.MatchString(s.Name())
+}
+
+func (k *keepTimeWindow) KeepRule(snaps []Snapshot) (destroyList []Snapshot) {
+	return k.keepRule(time.Now(), snaps)
+}
+
+func (k *keepTimeWindow) keepRule(now time.Time, snaps []Snapshot) (destroyList []Snapshot) {
+	cutoff := now.Add(-k.d)
+	return filterSnapList(snaps, func(s Snapshot) bool {
+		return k.InScope(s) && s.Date().After(cutoff) != k.within
+	})
+}
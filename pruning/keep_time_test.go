@@ -0,0 +1,122 @@
+package pruning
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestKeepTimeWindow(t *testing.T) {
+
+	now := time.Now()
+	ago := func(hours int) time.Time {
+		return now.Add(-time.Duration(hours) * time.Hour)
+	}
+
+	inputs := []Snapshot{
+		stubSnap{name: "zrepl_1", date: ago(100)},
+		stubSnap{name: "manual_2", date: ago(72)},
+		stubSnap{name: "zrepl_3", date: ago(24)},
+		stubSnap{name: "manual_4", date: ago(1)},
+	}
+
+	mustWithin := func(d time.Duration, regex string) KeepRule {
+		k, err := NewKeepWithin(d, regex)
+		if err != nil {
+			panic(err)
+		}
+		return k
+	}
+	mustOlderThan := func(d time.Duration, regex string) KeepRule {
+		k, err := NewKeepOlderThan(d, regex)
+		if err != nil {
+			panic(err)
+		}
+		return k
+	}
+
+	tcs := map[string]testCase{
+		"within": {
+			inputs: inputs,
+			rules: []KeepRule{
+				mustWithin(48*time.Hour, ""),
+			},
+			expDestroy: map[string]bool{
+				"zrepl_1": true, "manual_2": true,
+			},
+		},
+		"withinRegex": {
+			inputs: inputs,
+			rules: []KeepRule{
+				mustWithin(48*time.Hour, "^zrepl_"),
+			},
+			expDestroy: map[string]bool{
+				"zrepl_1": true,
+			},
+		},
+		"olderThan": {
+			inputs: inputs,
+			rules: []KeepRule{
+				mustOlderThan(48*time.Hour, ""),
+			},
+			expDestroy: map[string]bool{
+				"zrepl_3": true, "manual_4": true,
+			},
+		},
+		"olderThanRegex": {
+			inputs: inputs,
+			rules: []KeepRule{
+				mustOlderThan(48*time.Hour, "^manual_"),
+			},
+			expDestroy: map[string]bool{
+				"manual_4": true,
+			},
+		},
+		"withinOrOlderThan": {
+			inputs: inputs,
+			rules: []KeepRule{
+				mustWithin(2*time.Hour, ""),
+				mustOlderThan(96*time.Hour, ""),
+			},
+			expDestroy: map[string]bool{
+				"manual_2": true, "zrepl_3": true,
+			},
+		},
+		"empty": {
+			inputs: []Snapshot{},
+			rules: []KeepRule{
+				mustWithin(48*time.Hour, ""),
+			},
+			expDestroy: map[string]bool{},
+		},
+	}
+
+	testTable(tcs, t)
+
+	t.Run("cutoff", func(t *testing.T) {
+		snaps := []Snapshot{stubSnap{name: "a", date: ago(48)}}
+		within := mustWithin(48*time.Hour, "").(*KeepWithin)
+		olderThan := mustOlderThan(48*time.Hour, "").(*KeepOlderThan)
+		assert.Len(t, within.keepRule(now, snaps), 1)
+		assert.Len(t, olderThan.keepRule(now, snaps), 0)
+	})
+
+	t.Run("mustBePositive", func(t *testing.T) {
+		var err error
+		_, err = NewKeepWithin(0, "")
+		assert.Error(t, err)
+		_, err = NewKeepOlderThan(-time.Hour, "")
+		assert.Error(t, err)
+	})
+
+	t.Run("invalidRegex", func(t *testing.T) {
+		_, err := NewKeepWithin(time.Hour, "(")
+		assert.Error(t, err)
+	})
+
+	t.Run("String", func(t *testing.T) {
+		assert.Equal(t, "within(48h0m0s)", mustWithin(48*time.Hour, "").(*KeepWithin).String())
+		assert.Equal(t, "older_than(1h0m0s ^manual_)", mustOlderThan(time.Hour, "^manual_").(*KeepOlderThan).String())
+	})
+
+}
@@ -17,29 +17,115 @@ type Snapshot interface {
 	Date() time.Time
 }
 
+// A ScopedKeepRule only applies to the snapshots in its scope:
+// snapshots outside of it are neither kept nor destroyed by the rule.
+// Rules that do not implement it apply to all snapshots.
+type ScopedKeepRule interface {
+	KeepRule
+	InScope(s Snapshot) bool
+}
+
+func inScope(r KeepRule, s Snapshot) bool {
+	scoped, ok := r.(ScopedKeepRule)
+	return !ok || scoped.InScope(s)
+}
+
+// A KeepReasoner is a KeepRule that explains why it keeps snapshots.
+// Rules that do not implement it are described by their String method.
+type KeepReasoner interface {
+	// KeepReasons returns a detail for every snapshot of snaps that KeepRule(snaps) keeps,
+	// e.g. the retention grid interval it is kept in.
+	KeepReasons(snaps []Snapshot) map[Snapshot]string
+}
+
+// KeptBy identifies a keep rule that keeps a snapshot.
+type KeptBy struct {
+	// the index of the rule in the keep rules, -1 if the snapshot is kept for another reason than a keep rule
+	Rule int
+	// e.g. "grid(^zrepl_.*)" or "last_n(10)"
+	RuleName string
+	// e.g. "bucket 3" for the retention grid, may be empty
+	Detail string `json:",omitempty"`
+}
+
+func (k KeptBy) String() string {
+	s := k.RuleName
+	if k.Rule >= 0 {
+		s = fmt.Sprintf("#%d %s", k.Rule, s)
+	}
+	if k.Detail != "" {
+		s += " " + k.Detail
+	}
+	return s
+}
+
+// Decision records whether a snapshot is destroyed and which keep rules keep it.
+type Decision struct {
+	Snapshot Snapshot
+	Destroy  bool
+	// empty if Destroy or if no keep rule applies to the snapshot
+	KeptBy []KeptBy
+}
+
+// PruneSnapshots returns the snapshots of snaps that are destroyed by at least one of keepRules
+// and kept by none of them.
 // The returned snapshot list is guaranteed to only contains elements of input parameter snaps
 func PruneSnapshots(snaps []Snapshot, keepRules []KeepRule) []Snapshot {
+	remove, _ := PruneSnapshotsDecisions(snaps, keepRules)
+	return remove
+}
+
+// PruneSnapshotsDecisions is like PruneSnapshots, but also returns a Decision for each snapshot of snaps, in the same order.
+func PruneSnapshotsDecisions(snaps []Snapshot, keepRules []KeepRule) (remove []Snapshot, decisions []Decision) {
 
+	decisions = make([]Decision, len(snaps))
+	for i := range snaps {
+		decisions[i].Snapshot = snaps[i]
+	}
 	if keepRules == nil || len(keepRules) == 0 {
-		return []Snapshot{}
+		return []Snapshot{}, decisions
 	}
 
-	remCount := make(map[Snapshot]int, len(snaps))
-	for _, r := range keepRules {
+	removedBy := make([]map[Snapshot]bool, len(keepRules))
+	for i, r := range keepRules {
 		ruleRems := r.KeepRule(snaps)
+		removedBy[i] = make(map[Snapshot]bool, len(ruleRems))
 		for _, ruleRem := range ruleRems {
-			remCount[ruleRem]++
+			if inScope(r, ruleRem) {
+				removedBy[i][ruleRem] = true
+			}
 		}
 	}
 
-	remove := make([]Snapshot, 0, len(snaps))
-	for snap, rc := range remCount {
-		if rc == len(keepRules) {
-			remove = append(remove, snap)
+	remove = make([]Snapshot, 0, len(snaps))
+	details := make([]map[Snapshot]string, len(keepRules))
+	for i := range decisions {
+		d := &decisions[i]
+		removed := false
+		for ri, r := range keepRules {
+			if removedBy[ri][d.Snapshot] {
+				removed = true
+				continue
+			}
+			if !inScope(r, d.Snapshot) {
+				continue
+			}
+			if reasoner, ok := r.(KeepReasoner); ok && details[ri] == nil {
+				details[ri] = reasoner.KeepReasons(snaps)
+			}
+			d.KeptBy = append(d.KeptBy, KeptBy{
+				Rule:     ri,
+				RuleName: fmt.Sprint(r),
+				Detail:   details[ri][d.Snapshot],
+			})
+		}
+		if removed && len(d.KeptBy) == 0 {
+			d.Destroy = true
+			remove = append(remove, d.Snapshot)
 		}
 	}
 
-	return remove
+	return remove, decisions
 }
 
 func RulesFromConfig(in []config.PruningEnum) (rules []KeepRule, err error) {
@@ -56,13 +142,21 @@ func RulesFromConfig(in []config.PruningEnum) (rules []KeepRule, err error) {
 func RuleFromConfig(in config.PruningEnum) (KeepRule, error) {
 	switch v := in.Ret.(type) {
 	case *config.PruneKeepNotReplicated:
-		return NewKeepNotReplicated(), nil
+		return NewKeepScoped(v.Regex, NewKeepNotReplicated())
 	case *config.PruneKeepLastN:
-		return NewKeepLastN(v.Count)
+		k, err := NewKeepLastN(v.Count)
+		if err != nil {
+			return nil, err
+		}
+		return NewKeepScoped(v.Regex, k)
 	case *config.PruneKeepRegex:
 		return NewKeepRegex(v.Regex, v.Negate)
 	case *config.PruneGrid:
 		return NewKeepGrid(v)
+	case *config.PruneKeepWithin:
+		return NewKeepWithin(time.Duration(v.Duration), v.Regex)
+	case *config.PruneKeepOlderThan:
+		return NewKeepOlderThan(time.Duration(v.Duration), v.Regex)
 	default:
 		return nil, fmt.Errorf("unknown keep rule type %T", v)
 	}
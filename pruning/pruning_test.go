@@ -1,6 +1,7 @@
 package pruning
 
 import (
+	"github.com/stretchr/testify/assert"
 	"testing"
 	"time"
 )
@@ -144,3 +145,78 @@ func TestPruneSnapshots(t *testing.T) {
 
 	testTable(tcs, t)
 }
+
+func TestPruneSnapshotsDecisions(t *testing.T) {
+	snaps := []Snapshot{
+		stubSnap{name: "foo_123"},
+		stubSnap{name: "foo_456", replicated: true},
+		stubSnap{name: "bar_123", replicated: true},
+	}
+	rules := []KeepRule{
+		MustKeepRegex("foo_", false),
+		NewKeepNotReplicated(),
+	}
+	remove, decisions := PruneSnapshotsDecisions(snaps, rules)
+	assert.Equal(t, []string{"bar_123"}, snapshotList(remove).NameList())
+	assert.Equal(t, []Decision{
+		{Snapshot: snaps[0], KeptBy: []KeptBy{{0, "regex(foo_)", ""}, {1, "not_replicated", ""}}},
+		{Snapshot: snaps[1], KeptBy: []KeptBy{{0, "regex(foo_)", ""}}},
+		{Snapshot: snaps[2], Destroy: true},
+	}, decisions)
+	assert.Equal(t, "#1 not_replicated", decisions[0].KeptBy[1].String())
+
+	remove, decisions = PruneSnapshotsDecisions(snaps, nil)
+	assert.Empty(t, remove)
+	for _, d := range decisions {
+		assert.False(t, d.Destroy)
+		assert.Empty(t, d.KeptBy)
+	}
+}
+
+// keepReasonerStub keeps the snapshots in reasons
+type keepReasonerStub struct {
+	reasons map[string]string
+}
+
+func (k keepReasonerStub) KeepRule(snaps []Snapshot) []Snapshot {
+	return filterSnapList(snaps, func(s Snapshot) bool {
+		_, ok := k.reasons[s.Name()]
+		return !ok
+	})
+}
+
+func (k keepReasonerStub) KeepReasons(snaps []Snapshot) map[Snapshot]string {
+	res := make(map[Snapshot]string)
+	for _, s := range snaps {
+		if r, ok := k.reasons[s.Name()]; ok {
+			res[s] = r
+		}
+	}
+	return res
+}
+
+func (k keepReasonerStub) String() string { return "stub" }
+
+func TestPruneSnapshotsDecisions_KeepReasoner(t *testing.T) {
+	snaps := []Snapshot{
+		stubSnap{name: "a"},
+		stubSnap{name: "b"},
+		stubSnap{name: "c"},
+	}
+	rules := []KeepRule{
+		keepReasonerStub{map[string]string{"a": "bucket 0", "b": "bucket 2"}},
+		MustKeepRegex("^b", false),
+	}
+	_, decisions := PruneSnapshotsDecisions(snaps, rules)
+	keptBy := make(map[string][]string)
+	for _, d := range decisions {
+		for _, k := range d.KeptBy {
+			keptBy[d.Snapshot.Name()] = append(keptBy[d.Snapshot.Name()], k.String())
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"a": {"#0 stub bucket 0"},
+		"b": {"#0 stub bucket 2", "#1 regex(^b)"},
+	}, keptBy)
+	assert.True(t, decisions[2].Destroy)
+}
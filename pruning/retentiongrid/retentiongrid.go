@@ -12,6 +12,9 @@ type Interval interface {
 
 const RetentionGridKeepCountAll int = -1
 
+// BucketNow is the bucket of entries that are kept by FitEntriesBuckets because they are not older than `now`.
+const BucketNow int = -1
+
 type Grid struct {
 	intervals []Interval
 }
@@ -41,6 +44,13 @@ func NewGrid(l []Interval) *Grid {
 // Entries that are younger than `now` are always kept.
 // Those that are older than the earliest beginning of an interval are removed.
 func (g Grid) FitEntries(now time.Time, entries []Entry) (keep, remove []Entry) {
+	keep, remove, _ = g.FitEntriesBuckets(now, entries)
+	return keep, remove
+}
+
+// FitEntriesBuckets is like FitEntries, but also returns the index of the interval
+// that each entry of keep was kept in, BucketNow for entries that are not older than `now`.
+func (g Grid) FitEntriesBuckets(now time.Time, entries []Entry) (keep, remove []Entry, bucketOf map[Entry]int) {
 
 	type bucket struct {
 		entries []Entry
@@ -49,6 +59,7 @@ func (g Grid) FitEntries(now time.Time, entries []Entry) (keep, remove []Entry)
 
 	keep = make([]Entry, 0)
 	remove = make([]Entry, 0)
+	bucketOf = make(map[Entry]int)
 
 	oldestIntervalStart := now
 	for i := range g.intervals {
@@ -62,6 +73,7 @@ func (g Grid) FitEntries(now time.Time, entries []Entry) (keep, remove []Entry)
 
 		if date == now || date.After(now) {
 			keep = append(keep, e)
+			bucketOf[e] = BucketNow
 			continue
 		} else if date.Before(oldestIntervalStart) {
 			remove = append(remove, e)
@@ -88,6 +100,7 @@ func (g Grid) FitEntries(now time.Time, entries []Entry) (keep, remove []Entry)
 		i := 0
 		for ; (interval.KeepCount() == RetentionGridKeepCountAll || i < interval.KeepCount()) && i < len(b.entries); i++ {
 			keep = append(keep, b.entries[i])
+			bucketOf[b.entries[i]] = bi
 		}
 		for ; i < len(b.entries); i++ {
 			remove = append(remove, b.entries[i])
@@ -158,3 +158,28 @@ func TestRetentionGridFitEntries(t *testing.T) {
 	validateRetentionGridFitEntries(t, now, snaps, keep, remove)
 
 }
+
+func TestRetentionGridFitEntriesBuckets(t *testing.T) {
+
+	g := gridFromString("10m,-1|10m|10m,2|1h")
+
+	now := time.Unix(0, 0)
+
+	snaps := []Entry{
+		dummySnap{"1", true, now},
+		dummySnap{"b1", true, now.Add(-6 * time.Minute)},
+		dummySnap{"a", false, now.Add(-11 * time.Minute)},
+		dummySnap{"c", true, now.Add(-19 * time.Minute)},
+		dummySnap{"bar", true, now.Add(-26 * time.Minute)},
+		dummySnap{"d", true, now.Add(-1*time.Hour - 15*time.Minute)},
+		dummySnap{"f", false, now.Add(-2 * time.Hour)},
+	}
+	keep, remove, bucketOf := g.FitEntriesBuckets(now, snaps)
+	validateRetentionGridFitEntries(t, now, snaps, keep, remove)
+
+	buckets := make(map[string]int)
+	for e, b := range bucketOf {
+		buckets[e.(dummySnap).Name] = b
+	}
+	assert.Equal(t, map[string]int{"1": BucketNow, "b1": 0, "c": 1, "bar": 2, "d": 3}, buckets)
+}
@@ -56,6 +56,13 @@ type Receiver interface {
 	Receive(ctx context.Context, r *pdu.ReceiveReq, sendStream io.ReadCloser) error
 }
 
+// A SlotAcquirer limits the number of concurrent send/receive streams.
+type SlotAcquirer interface {
+	// AcquireSlot blocks until a send/receive of fs may start or ctx is done.
+	// release must be called once the send/receive has finished.
+	AcquireSlot(ctx context.Context, fs string) (release func(), err error)
+}
+
 type StepReport struct {
 	From, To string
 	Status   StepState
@@ -69,6 +76,12 @@ type Report struct {
 	Status             string
 	Problem            string
 	Completed, Pending []*StepReport
+	// the next step is waiting for a transfer slot
+	WaitingForSlot bool
+	// consecutive failed attempts, 0 if the last attempt succeeded
+	FailedAttempts int
+	// zero if the filesystem may be retried immediately
+	NextRetry time.Time
 }
 
 //go:generate enumer -type=State
@@ -86,16 +99,38 @@ type Error interface {
 	LocalToFS() bool
 }
 
+// RetryBudget limits the retries of a filesystem after temporary errors.
+// Once it is exhausted, the filesystem is marked as permanently failed.
+type RetryBudget struct {
+	// Maximum number of consecutive failed attempts, 0 means unlimited.
+	MaxAttempts int
+	// Maximum time since the first of consecutive failed attempts, 0 means unlimited.
+	MaxDuration time.Duration
+}
+
+func (b RetryBudget) exhausted(failedAttempts int, since time.Duration) bool {
+	return (b.MaxAttempts > 0 && failedAttempts >= b.MaxAttempts) ||
+		(b.MaxDuration > 0 && since >= b.MaxDuration)
+}
+
 type Replication struct {
 	promBytesReplicated prometheus.Counter
 
 	fs                 string
+	slots              SlotAcquirer // nil if transfers are not limited
+	budget             RetryBudget
+	sendFeatures       SendFeatures
 
 	// lock protects all fields below it in this struct, but not the data behind pointers
 	lock               sync.Mutex
 	state              State
+	waitingForSlot     bool
 	err                Error
 	completed, pending []*ReplicationStep
+	// consecutive failed attempts, reset by a successful step
+	failedAttempts     int
+	firstFailureAt     time.Time
+	retryAt            time.Time
 }
 
 func (f *Replication) State() State {
@@ -108,12 +143,35 @@ func (f *Replication) FS() string { return f.fs }
 
 // returns zero value time.Time{} if no more pending steps
 func (f *Replication) NextStepDate() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
 	if len(f.pending) == 0 {
 		return time.Time{}
 	}
 	return f.pending[0].to.SnapshotTime()
 }
 
+// FailedAttempts returns the number of consecutive failed attempts, 0 if the last attempt succeeded.
+func (f *Replication) FailedAttempts() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.failedAttempts
+}
+
+// RetryAt returns the time before which the filesystem should not be retried, zero if it may be retried immediately.
+func (f *Replication) RetryAt() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.retryAt
+}
+
+// ScheduleRetry sets the time returned by RetryAt, the zero time allows an immediate retry.
+func (f *Replication) ScheduleRetry(at time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.retryAt = at
+}
+
 func (f *Replication) Err() Error {
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -146,6 +204,12 @@ func (f *Replication) UpdateSizeEsitmate(ctx context.Context, sender Sender) err
 	return nil
 }
 
+// SendFeatures are the stream features that the send requests of a Replication require from the sender,
+// see the fields of the same name in pdu.SendReq.
+type SendFeatures struct {
+	Compress, LargeBlocks, EmbeddedData, Raw, Properties bool
+}
+
 type ReplicationBuilder struct {
 	r *Replication
 }
@@ -154,6 +218,24 @@ func BuildReplication(fs string, promBytesReplicated prometheus.Counter) *Replic
 	return &ReplicationBuilder{&Replication{fs: fs, promBytesReplicated: promBytesReplicated}}
 }
 
+// Slots makes the steps of the replication acquire a slot from slots before they send.
+func (b *ReplicationBuilder) Slots(slots SlotAcquirer) *ReplicationBuilder {
+	b.r.slots = slots
+	return b
+}
+
+// SendFeatures makes the send requests of the replication require features from the sender.
+func (b *ReplicationBuilder) SendFeatures(features SendFeatures) *ReplicationBuilder {
+	b.r.sendFeatures = features
+	return b
+}
+
+// RetryBudget marks the replication as permanently failed once budget is exhausted.
+func (b *ReplicationBuilder) RetryBudget(budget RetryBudget) *ReplicationBuilder {
+	b.r.budget = budget
+	return b
+}
+
 func (b *ReplicationBuilder) AddStep(from, to FilesystemVersion) *ReplicationBuilder {
 	step := &ReplicationStep{
 		state:  StepReplicationReady,
@@ -165,6 +247,22 @@ func (b *ReplicationBuilder) AddStep(from, to FilesystemVersion) *ReplicationBui
 	return b
 }
 
+// AddResumeStep adds a step that resumes the interrupted send from -> to using token.
+// from is nil if the interrupted send was a full send.
+func (b *ReplicationBuilder) AddResumeStep(token string, from, to FilesystemVersion) *ReplicationBuilder {
+	b.AddStep(from, to)
+	b.r.pending[len(b.r.pending)-1].resumeToken = token
+	return b
+}
+
+// AddRollbackStep adds a step from -> to that asks the receiver to roll back
+// to its most recent snapshot before receiving (see pdu.ReceiveReq.Rollback).
+func (b *ReplicationBuilder) AddRollbackStep(from, to FilesystemVersion) *ReplicationBuilder {
+	b.AddStep(from, to)
+	b.r.pending[len(b.r.pending)-1].rollback = true
+	return b
+}
+
 func (b *ReplicationBuilder) Done() (r *Replication) {
 	if len(b.r.pending) > 0 {
 		b.r.state = Ready
@@ -220,9 +318,11 @@ type ReplicationStep struct {
 	// from, to and parent are assumed to be immutable
 	lock sync.Mutex
 
-	state    StepState
-	from, to FilesystemVersion
-	parent   *Replication
+	state       StepState
+	from, to    FilesystemVersion
+	resumeToken string // empty if the step does not resume an interrupted send
+	rollback    bool   // whether the receiver rolls back to its most recent snapshot before receiving
+	parent      *Replication
 
 	// both retry and permanent error
 	err error
@@ -275,12 +375,34 @@ func (f *Replication) Retry(ctx context.Context, ka *watchdog.KeepAlive, sender
 	u(func(fsr *Replication) {
 		if err != nil {
 			f.err = &StepError{stepStr: current.String(), err: err}
+			if !f.err.Temporary() || f.err.ContextErr() {
+				return
+			}
+			now := time.Now()
+			if f.failedAttempts == 0 {
+				f.firstFailureAt = now
+			}
+			f.failedAttempts++
+			if f.budget.exhausted(f.failedAttempts, now.Sub(f.firstFailureAt)) {
+				getLogger(ctx).
+					WithField("failed_attempts", f.failedAttempts).
+					WithField("since", f.firstFailureAt).
+					Error("retry budget exhausted, giving up on filesystem")
+				f.err = &RetryBudgetExhaustedError{
+					Err:            f.err,
+					FailedAttempts: f.failedAttempts,
+					Since:          f.firstFailureAt,
+				}
+			}
 			return
 		}
 		if err == nil && current.state != StepCompleted {
 			panic(fmt.Sprintf("implementation error: %v", current.state))
 		}
 		f.err = nil
+		f.failedAttempts = 0
+		f.firstFailureAt = time.Time{}
+		f.retryAt = time.Time{}
 		f.completed = append(f.completed, current)
 		f.pending = f.pending[1:]
 		if len(f.pending) > 0 {
@@ -345,13 +467,37 @@ func (e StepError) ContextErr() bool {
 	return false
 }
 
+// RetryBudgetExhaustedError is the permanent error of a filesystem
+// whose retries after temporary errors exceeded its RetryBudget.
+type RetryBudgetExhaustedError struct {
+	Err            Error // the error of the last attempt
+	FailedAttempts int
+	Since          time.Time
+}
+
+var _ Error = &RetryBudgetExhaustedError{}
+
+func (e *RetryBudgetExhaustedError) Error() string {
+	return fmt.Sprintf("giving up after %d failed attempts since %s: %s",
+		e.FailedAttempts, e.Since.Format(time.RFC3339), e.Err)
+}
+
+func (e *RetryBudgetExhaustedError) Temporary() bool { return false }
+
+func (e *RetryBudgetExhaustedError) LocalToFS() bool { return true }
+
+func (e *RetryBudgetExhaustedError) ContextErr() bool { return false }
+
 func (fsr *Replication) Report() *Report {
 	fsr.lock.Lock()
 	defer fsr.lock.Unlock()
 
 	rep := Report{
-		Filesystem: fsr.fs,
-		Status:     fsr.state.String(),
+		Filesystem:     fsr.fs,
+		Status:         fsr.state.String(),
+		WaitingForSlot: fsr.waitingForSlot,
+		FailedAttempts: fsr.failedAttempts,
+		NextRetry:      fsr.retryAt,
 	}
 
 	if fsr.err != nil && fsr.err.LocalToFS() {
@@ -400,6 +546,15 @@ func (s *ReplicationStep) doReplication(ctx context.Context, ka *watchdog.KeepAl
 	log := getLogger(ctx)
 	sr := s.buildSendRequest(false)
 
+	if s.parent.slots != nil {
+		release, err := s.parent.acquireSlot(ctx, ka)
+		if err != nil {
+			log.WithError(err).Info("cancelled while waiting for transfer slot")
+			return err
+		}
+		defer release()
+	}
+
 	log.Debug("initiate send request")
 	sres, sstream, err := sender.Send(ctx, sr)
 	if err != nil {
@@ -423,6 +578,7 @@ func (s *ReplicationStep) doReplication(ctx context.Context, ka *watchdog.KeepAl
 	rr := &pdu.ReceiveReq{
 		Filesystem:       fs,
 		ClearResumeToken: !sres.UsedResumeToken,
+		Rollback:         s.rollback,
 	}
 	log.Debug("initiate receive request")
 	err = receiver.Receive(ctx, rr, sstream)
@@ -446,6 +602,36 @@ func (s *ReplicationStep) doReplication(ctx context.Context, ka *watchdog.KeepAl
 
 }
 
+// acquireSlot waits for a transfer slot for f.
+// Waiting for a slot is not a lack of progress, hence ka is kept alive while waiting.
+func (f *Replication) acquireSlot(ctx context.Context, ka *watchdog.KeepAlive) (release func(), err error) {
+	setWaiting := func(waiting bool) {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		f.waitingForSlot = waiting
+	}
+	setWaiting(true)
+	defer setWaiting(false)
+
+	waitCtx, cancelWait := context.WithCancel(ctx)
+	defer cancelWait()
+	go func() {
+		t := time.NewTicker(1 * time.Second)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				ka.MadeProgress()
+			case <-waitCtx.Done():
+				return
+			}
+		}
+	}()
+
+	getLogger(ctx).Debug("wait for transfer slot")
+	return f.slots.AcquireSlot(ctx, f.fs)
+}
+
 func (s *ReplicationStep) doMarkReplicated(ctx context.Context, ka *watchdog.KeepAlive, sender Sender) error {
 
 	if s.state != StepMarkReplicatedReady {
@@ -454,6 +640,8 @@ func (s *ReplicationStep) doMarkReplicated(ctx context.Context, ka *watchdog.Kee
 
 	log := getLogger(ctx)
 
+	// the sender may also move its hold on the most recently replicated snapshot forward,
+	// see endpoint.Sender.HoldReplicated
 	log.Debug("advance replication cursor")
 	req := &pdu.ReplicationCursorReq{
 		Filesystem: s.parent.fs,
@@ -494,26 +682,38 @@ func (s *ReplicationStep) buildSendRequest(dryRun bool) (sr *pdu.SendReq) {
 	fs := s.parent.fs
 	if s.from == nil {
 		sr = &pdu.SendReq{
-			Filesystem: fs,
-			To:         s.to.RelName(),
-			DryRun:     dryRun,
+			Filesystem:  fs,
+			To:          s.to.RelName(),
+			ResumeToken: s.resumeToken,
+			DryRun:      dryRun,
 		}
 	} else {
 		sr = &pdu.SendReq{
-			Filesystem: fs,
-			From:       s.from.RelName(),
-			To:         s.to.RelName(),
-			DryRun:     dryRun,
+			Filesystem:  fs,
+			From:        s.from.RelName(),
+			To:          s.to.RelName(),
+			ResumeToken: s.resumeToken,
+			DryRun:      dryRun,
 		}
 	}
+	f := s.parent.sendFeatures
+	sr.Compress, sr.LargeBlocks, sr.EmbeddedData, sr.Raw, sr.Properties =
+		f.Compress, f.LargeBlocks, f.EmbeddedData, f.Raw, f.Properties
 	return sr
 }
 
 func (s *ReplicationStep) String() string {
+	var annotations string
+	if s.resumeToken != "" {
+		annotations += " (resume)"
+	}
+	if s.rollback {
+		annotations += " (rollback)"
+	}
 	if s.from == nil { // FIXME: ZFS semantics are that to is nil on non-incremental send
-		return fmt.Sprintf("%s%s (full)", s.parent.fs, s.to.RelName())
+		return fmt.Sprintf("%s%s (full)%s", s.parent.fs, s.to.RelName(), annotations)
 	} else {
-		return fmt.Sprintf("%s(%s => %s)", s.parent.fs, s.from.RelName(), s.to.RelName())
+		return fmt.Sprintf("%s(%s => %s)%s", s.parent.fs, s.from.RelName(), s.to.RelName(), annotations)
 	}
 }
 
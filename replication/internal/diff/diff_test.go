@@ -0,0 +1,74 @@
+package mainfsm
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/zrepl/zrepl/replication/pdu"
+)
+
+// fsvlist parses "@name,id" (snapshot) and "#name,id" (bookmark), id is used as Guid, CreateTXG and Creation
+func fsvlist(fsv ...string) (r []*FilesystemVersion) {
+	r = make([]*FilesystemVersion, len(fsv))
+	for i, f := range fsv {
+		split := strings.Split(f, ",")
+		if len(split) != 2 {
+			panic("invalid fsv spec")
+		}
+		id, err := strconv.Atoi(split[1])
+		if err != nil {
+			panic(err)
+		}
+		v := &FilesystemVersion{
+			Name:      split[0][1:],
+			Guid:      uint64(id),
+			CreateTXG: uint64(id),
+			Creation:  FilesystemVersionCreation(time.Unix(0, 0).Add(time.Duration(id) * time.Second)),
+		}
+		switch f[0] {
+		case '@':
+			v.Type = FilesystemVersion_Snapshot
+		case '#':
+			v.Type = FilesystemVersion_Bookmark
+		default:
+			panic("invalid character")
+		}
+		r[i] = v
+	}
+	return r
+}
+
+func relNames(path []*FilesystemVersion) []string {
+	names := make([]string, len(path))
+	for i, v := range path {
+		names[i] = v.RelName()
+	}
+	return names
+}
+
+func TestIncrementalPath_BookmarkIfSnapshotIsGone(t *testing.T) {
+	l := fsvlist
+
+	// the snapshot is preferred over a bookmark of it
+	path, conflict := IncrementalPath(l("@a,1"), l("#a_zrepl_job,1", "@a,1", "@b,2"))
+	assert.NoError(t, conflict)
+	assert.Equal(t, []string{"@a", "@b"}, relNames(path))
+
+	// the bookmark is used if the snapshot has been pruned, subsequent bookmarks are skipped
+	path, conflict = IncrementalPath(l("@a,1"), l("#a_zrepl_job,1", "#b_zrepl_job,2", "@b,2", "@c,3"))
+	assert.NoError(t, conflict)
+	assert.Equal(t, []string{"#a_zrepl_job", "@b", "@c"}, relNames(path))
+
+	// the receiver is up to date
+	path, conflict = IncrementalPath(l("@a,1", "@b,2"), l("#a_zrepl_job,1", "#b_zrepl_job,2"))
+	assert.NoError(t, conflict)
+	assert.Empty(t, path)
+
+	// neither snapshot nor bookmark
+	_, conflict = IncrementalPath(l("@a,1"), l("#b_zrepl_job,2", "@c,3"))
+	assert.IsType(t, &ConflictNoCommonAncestor{}, conflict)
+}
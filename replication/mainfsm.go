@@ -14,12 +14,14 @@ import (
 	"math/bits"
 	"net"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/zrepl/zrepl/replication/fsrep"
 	. "github.com/zrepl/zrepl/replication/internal/diff"
 	"github.com/zrepl/zrepl/replication/pdu"
+	"github.com/zrepl/zrepl/zfs"
 )
 
 //go:generate enumer -type=State
@@ -63,6 +65,9 @@ type Replication struct {
 	promSecsPerState *prometheus.HistogramVec // labels: state
 	promBytesReplicated *prometheus.CounterVec // labels: filesystem
 
+	// immutable
+	opts Options
+
 	Progress watchdog.KeepAlive
 
 	// lock protects all fields of this struct (but not the fields behind pointers!)
@@ -73,33 +78,84 @@ type Replication struct {
 	// Working, WorkingWait, Completed, ContextDone
 	queue     []*fsrep.Replication
 	completed []*fsrep.Replication
-	active    *fsrep.Replication // == queue[0] or nil, unlike in Report
+	active    []*fsrep.Replication // subset of queue, unlike in Report
 
 	// for PlanningError, WorkingWait and ContextError and Completed
 	err error
 
 	// PlanningError, WorkingWait
 	sleepUntil time.Time
+	// consecutive global errors, reset by successful planning or a successful step
+	failedAttempts int
 }
 
 type Report struct {
 	Status    string
 	Problem   string
 	SleepUntil time.Time
+	// consecutive global errors that SleepUntil backs off from
+	FailedAttempts int
 	Completed []*fsrep.Report
 	Pending   []*fsrep.Report
-	Active    *fsrep.Report // not contained in Pending, unlike in struct Replication
+	Active    []*fsrep.Report // not contained in Pending, unlike in struct Replication
+}
+
+// Options configures a Replication.
+type Options struct {
+	// Maximum number of filesystems that are replicated in parallel, < 1 is treated as 1.
+	Concurrency        int
+	ConflictResolution ConflictResolution
+	// Acquired by each step before it sends, nil if transfers are not limited.
+	Slots fsrep.SlotAcquirer
+	Retry RetryPolicy
+	// Stream features that every send request requires from the sender.
+	SendFeatures fsrep.SendFeatures
+}
+
+// ConflictResolution configures how conflicts between sender and receiver versions are resolved during planning.
+type ConflictResolution struct {
+	// If the receiver has no versions, replicate all snapshots of the sender instead of only the most recent one.
+	InitialReplicationAll bool
+	// If the receiver has diverged from the sender, destroy the receiver's snapshots
+	// after the most recent common snapshot and replicate from there.
+	RollbackDiverged bool
+	// Maximum number of receiver snapshots that a rollback may destroy.
+	RollbackMaxDestroy int
+	// Only report the snapshots that a rollback would destroy.
+	RollbackDryRun bool
 }
 
-func NewReplication(secsPerState *prometheus.HistogramVec, bytesReplicated *prometheus.CounterVec) *Replication {
+func NewReplication(secsPerState *prometheus.HistogramVec, bytesReplicated *prometheus.CounterVec, opts Options) *Replication {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
 	r := Replication{
 		promSecsPerState: secsPerState,
 		promBytesReplicated: bytesReplicated,
+		opts:             opts,
 		state:            Planning,
 	}
 	return &r
 }
 
+func (r *Replication) isActive(fsr *fsrep.Replication) bool {
+	for _, a := range r.active {
+		if a == fsr {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Replication) removeActive(fsr *fsrep.Replication) {
+	for i, a := range r.active {
+		if a == fsr {
+			r.active = append(r.active[:i], r.active[i+1:]...)
+			return
+		}
+	}
+}
+
 // Endpoint represents one side of the replication.
 //
 // An endpoint is either in Sender or Receiver mode, represented by the correspondingly
@@ -174,26 +230,151 @@ func (r *Replication) Drive(ctx context.Context, sender Sender, receiver Receive
 		Debug("main final state")
 }
 
-func resolveConflict(conflict error) (path []*pdu.FilesystemVersion, msg string) {
-	if noCommonAncestor, ok := conflict.(*ConflictNoCommonAncestor); ok {
-		if len(noCommonAncestor.SortedReceiverVersions) == 0 {
-			// TODO this is hard-coded replication policy: most recent snapshot as source
-			var mostRecentSnap *pdu.FilesystemVersion
-			for n := len(noCommonAncestor.SortedSenderVersions) - 1; n >= 0; n-- {
-				if noCommonAncestor.SortedSenderVersions[n].Type == pdu.FilesystemVersion_Snapshot {
-					mostRecentSnap = noCommonAncestor.SortedSenderVersions[n]
-					break
-				}
-			}
-			if mostRecentSnap == nil {
-				return nil, "no snapshots available on sender side"
+// conflictResolution is the result of resolveConflict.
+type conflictResolution struct {
+	// nil if the conflict cannot be resolved, msg explains why
+	path []*pdu.FilesystemVersion
+	// path[0] must be sent as a full stream, otherwise the receiver has path[0]
+	full bool
+	// the receiver must roll back to path[0] before receiving path[1]
+	rollback bool
+	// receiver snapshots to be destroyed before path can be replicated
+	destroy []*pdu.FilesystemVersion
+	msg     string
+}
+
+func resolveConflict(conflict error, policy ConflictResolution) (res conflictResolution) {
+	switch conflict := conflict.(type) {
+	case *ConflictNoCommonAncestor:
+		if len(conflict.SortedReceiverVersions) != 0 {
+			break
+		}
+		var snaps []*pdu.FilesystemVersion
+		for _, v := range conflict.SortedSenderVersions {
+			if v.Type == pdu.FilesystemVersion_Snapshot {
+				snaps = append(snaps, v)
 			}
-			return []*pdu.FilesystemVersion{mostRecentSnap}, fmt.Sprintf("start replication at most recent snapshot %s", mostRecentSnap.RelName())
+		}
+		if len(snaps) == 0 {
+			res.msg = "no snapshots available on sender side"
+			return res
+		}
+		res.full = true
+		if policy.InitialReplicationAll {
+			res.path = snaps
+			res.msg = fmt.Sprintf("start replication at oldest snapshot %s and replicate all %d snapshots", snaps[0].RelName(), len(snaps))
+			return res
+		}
+		mostRecentSnap := snaps[len(snaps)-1]
+		res.path = []*pdu.FilesystemVersion{mostRecentSnap}
+		res.msg = fmt.Sprintf("start replication at most recent snapshot %s", mostRecentSnap.RelName())
+		return res
+
+	case *ConflictDiverged:
+		if !policy.RollbackDiverged {
+			res.msg = "rollback of diverged receiver is not enabled"
+			return res
+		}
+		return resolveDiverged(conflict, policy)
+	}
+	res.msg = "no automated way to handle conflict type"
+	return res
+}
+
+// resolveDiverged rolls back the receiver to the most recent common snapshot.
+func resolveDiverged(conflict *ConflictDiverged, policy ConflictResolution) (res conflictResolution) {
+	ancestor := conflict.CommonAncestor
+	// zfs recv can only roll back to a snapshot, the receiver's common version might be a bookmark
+	var rcvHasAncestorSnap bool
+	for _, v := range conflict.SortedReceiverVersions {
+		rcvHasAncestorSnap = rcvHasAncestorSnap || (v.Type == pdu.FilesystemVersion_Snapshot && v.Guid == ancestor.Guid)
+	}
+	if !rcvHasAncestorSnap {
+		res.msg = fmt.Sprintf("cannot roll back: most recent common version %s is not a snapshot on the receiver", ancestor.RelName())
+		return res
+	}
+
+	path := []*pdu.FilesystemVersion{ancestor}
+	for _, v := range conflict.SenderOnly {
+		if v.Type == pdu.FilesystemVersion_Snapshot {
+			path = append(path, v)
 		}
 	}
-	return nil, "no automated way to handle conflict type"
+	if len(path) == 1 {
+		res.msg = fmt.Sprintf("cannot roll back: sender has no snapshots after most recent common version %s", ancestor.RelName())
+		return res
+	}
+
+	var destroy []*pdu.FilesystemVersion
+	names := make([]string, 0, len(conflict.ReceiverOnly))
+	for _, v := range conflict.ReceiverOnly {
+		if v.Type == pdu.FilesystemVersion_Snapshot {
+			destroy = append(destroy, v)
+			names = append(names, v.RelName())
+		}
+	}
+	what := fmt.Sprintf("rollback to %s destroys %d receiver snapshot(s): %s",
+		ancestor.RelName(), len(destroy), strings.Join(names, ", "))
+
+	if len(destroy) > policy.RollbackMaxDestroy {
+		res.msg = fmt.Sprintf("%s, more than the allowed maximum of %d", what, policy.RollbackMaxDestroy)
+		return res
+	}
+	if policy.RollbackDryRun {
+		res.msg = fmt.Sprintf("dry run: %s", what)
+		return res
+	}
+	res.path = path
+	res.rollback = true
+	res.destroy = destroy
+	res.msg = what
+	return res
+}
+
+// destroyReceiverVersions destroys the versions of fs on the receiver.
+// A non-nil error with ok == true is specific to fs, otherwise it affects the receiver as a whole.
+func destroyReceiverVersions(ctx context.Context, receiver Receiver, fs string, versions []*pdu.FilesystemVersion) (ok bool, err error) {
+	res, err := receiver.DestroySnapshots(ctx, &pdu.DestroySnapshotsReq{
+		Filesystem: fs,
+		Snapshots:  versions,
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, r := range res.Results {
+		if r.Error != "" {
+			return true, fmt.Errorf("cannot destroy receiver snapshot %s: %s", r.Snapshot.RelName(), r.Error)
+		}
+	}
+	return true, nil
+}
+
+// resumeTokenVersions returns the sender's versions that correspond to the GUIDs encoded in token.
+// from is nil if token is for a full send.
+func resumeTokenVersions(ctx context.Context, token string, sfsvs []*pdu.FilesystemVersion) (from, to *pdu.FilesystemVersion, err error) {
+	rt, err := zfs.ParseResumeToken(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, v := range sfsvs {
+		if v.Type == pdu.FilesystemVersion_Snapshot && v.Guid == rt.ToGUID {
+			to = v
+		}
+		// prefer snapshots over bookmarks, like IncrementalPath does
+		if rt.HasFromGUID && v.Guid == rt.FromGUID && (from == nil || from.Type == pdu.FilesystemVersion_Bookmark) {
+			from = v
+		}
+	}
+	if to == nil {
+		return nil, nil, fmt.Errorf("resume token refers to snapshot %#x that no longer exists on sender", rt.ToGUID)
+	}
+	if rt.HasFromGUID && from == nil {
+		return nil, nil, fmt.Errorf("resume token refers to incremental source %#x that no longer exists on sender", rt.FromGUID)
+	}
+	return from, to, nil
 }
 
+// RetryInterval is the default RetryPolicy.InitialInterval.
 var RetryInterval = envconst.Duration("ZREPL_REPLICATION_RETRY_INTERVAL", 10 * time.Second)
 
 type Error interface {
@@ -226,7 +407,8 @@ func statePlanning(ctx context.Context, ka *watchdog.KeepAlive, sender Sender, r
 			if !ge.Temporary {
 				r.state = PermanentError
 			} else {
-				r.sleepUntil = time.Now().Add(RetryInterval)
+				r.failedAttempts++
+				r.sleepUntil = r.opts.Retry.retryAt(time.Now(), r.failedAttempts)
 				r.state = PlanningError
 			}
 		}).rsf()
@@ -269,12 +451,13 @@ func statePlanning(ctx context.Context, ka *watchdog.KeepAlive, sender Sender, r
 			continue
 		}
 
-		receiverFSExists := false
-		for _, rfs := range rfss {
-			if rfs.Path == fs.Path {
-				receiverFSExists = true
+		var rfs *pdu.Filesystem
+		for _, f := range rfss {
+			if f.Path == fs.Path {
+				rfs = f
 			}
 		}
+		receiverFSExists := rfs != nil
 
 		var rfsvs []*pdu.FilesystemVersion
 		if receiverFSExists {
@@ -292,16 +475,35 @@ func statePlanning(ctx context.Context, ka *watchdog.KeepAlive, sender Sender, r
 		}
 		ka.MadeProgress()
 
+		// If the receiver has partially received state, resume that send first.
+		// Afterwards, the receiver has resumeTo and we continue from there.
+		var resumeFrom, resumeTo *pdu.FilesystemVersion
+		if receiverFSExists && rfs.ResumeToken != "" {
+			resumeFrom, resumeTo, err = resumeTokenVersions(ctx, rfs.ResumeToken, sfsvs)
+			if err != nil {
+				log.WithError(err).Info("cannot resume interrupted send, partially received state will be discarded")
+				resumeTo = nil
+			} else {
+				rfsvs = []*pdu.FilesystemVersion{resumeTo}
+			}
+		}
+
 		path, conflict := IncrementalPath(rfsvs, sfsvs)
+		var resolution conflictResolution
 		if conflict != nil {
-			var msg string
-			path, msg = resolveConflict(conflict) // no shadowing allowed!
+			var conflictPolicy ConflictResolution
+			u(func(r *Replication) {
+				conflictPolicy = r.opts.ConflictResolution
+			})
+			resolution = resolveConflict(conflict, conflictPolicy)
+			path = resolution.path // no shadowing allowed!
 			if path != nil {
 				log.WithField("conflict", conflict).Info("conflict")
-				log.WithField("resolution", msg).Info("automatically resolved")
+				log.WithField("resolution", resolution.msg).Info("automatically resolved")
 			} else {
 				log.WithField("conflict", conflict).Error("conflict")
-				log.WithField("problem", msg).Error("cannot resolve conflict")
+				log.WithField("problem", resolution.msg).Error("cannot resolve conflict")
+				conflict = fmt.Errorf("%s: %s", conflict, resolution.msg)
 			}
 		}
 		ka.MadeProgress()
@@ -310,15 +512,49 @@ func statePlanning(ctx context.Context, ka *watchdog.KeepAlive, sender Sender, r
 			continue
 		}
 
+		if len(resolution.destroy) > 0 {
+			log.WithField("resolution", resolution.msg).Warn("roll back diverged receiver")
+			if fsSpecific, err := destroyReceiverVersions(ctx, receiver, fs.Path, resolution.destroy); err != nil {
+				log.WithError(err).Error("cannot roll back receiver")
+				if !fsSpecific {
+					return handlePlanningError(err)
+				}
+				q = append(q, fsrep.NewReplicationConflictError(fs.Path, err))
+				continue
+			}
+			ka.MadeProgress()
+		}
+
 		var promBytesReplicated *prometheus.CounterVec
+		var slots fsrep.SlotAcquirer
+		var budget fsrep.RetryBudget
+		var features fsrep.SendFeatures
 		u(func(replication *Replication) { // FIXME args struct like in pruner (also use for sender and receiver)
 			promBytesReplicated = replication.promBytesReplicated
+			slots = replication.opts.Slots
+			budget = replication.opts.Retry.Budget
+			features = replication.opts.SendFeatures
 		})
 		fsrfsm := fsrep.BuildReplication(fs.Path, promBytesReplicated.WithLabelValues(fs.Path))
-		if len(path) == 1 {
+		if slots != nil {
+			fsrfsm.Slots(slots)
+		}
+		fsrfsm.RetryBudget(budget)
+		fsrfsm.SendFeatures(features)
+		if resumeTo != nil {
+			if resumeFrom != nil {
+				fsrfsm.AddResumeStep(rfs.ResumeToken, resumeFrom, resumeTo)
+			} else {
+				fsrfsm.AddResumeStep(rfs.ResumeToken, nil, resumeTo)
+			}
+		}
+		if len(path) == 1 || resolution.full {
 			fsrfsm.AddStep(nil, path[0])
-		} else {
-			for i := 0; i < len(path)-1; i++ {
+		}
+		for i := 0; i < len(path)-1; i++ {
+			if i == 0 && resolution.rollback {
+				fsrfsm.AddRollbackStep(path[i], path[i+1])
+			} else {
 				fsrfsm.AddStep(path[i], path[i+1])
 			}
 		}
@@ -341,6 +577,7 @@ func statePlanning(ctx context.Context, ka *watchdog.KeepAlive, sender Sender, r
 		r.completed = nil
 		r.queue = q
 		r.err = nil
+		r.failedAttempts = 0
 		r.state = Working
 	}).rsf()
 }
@@ -350,7 +587,7 @@ func statePlanningError(ctx context.Context, ka *watchdog.KeepAlive, sender Send
 	u(func(r *Replication) {
 		sleepUntil = r.sleepUntil
 	})
-	t := time.NewTimer(sleepUntil.Sub(time.Now()))
+	t := time.NewTimer(time.Until(sleepUntil))
 	getLogger(ctx).WithField("until", sleepUntil).Info("retry wait after planning error")
 	defer t.Stop()
 	select {
@@ -403,92 +640,167 @@ func (e FilesystemsReplicationFailedError) Error() string {
 	return fmt.Sprintf("%s could not be replicated: %s", fsstr, errorStr)
 }
 
-func stateWorking(ctx context.Context, ka *watchdog.KeepAlive, sender Sender, receiver Receiver, u updater) state {
+type fsrepRetryResult struct {
+	fsr *fsrep.Replication
+	err fsrep.Error
+}
 
-	var active *fsrep.Replication
-	rsfNext := u(func(r *Replication) {
+// stateWorking replicates up to Options.Concurrency filesystems in parallel.
+// Whenever a filesystem has completed a step, the queue is re-sorted and the freed slot
+// is handed to the idle filesystem with the oldest next step.
+// A non-filesystem-specific error stops the hand-out of new work; once all active filesystems
+// have returned, the state machine transitions to WorkingWait or PermanentError.
+// Filesystems that failed with a temporary filesystem-specific error are not handed out before
+// their backoff has passed. If only such filesystems are left, the state machine transitions to
+// WorkingWait until the earliest of them may be retried.
+func stateWorking(ctx context.Context, ka *watchdog.KeepAlive, sender Sender, receiver Receiver, u updater) state {
 
+	var concurrency int
+	u(func(r *Replication) {
 		r.err = nil
+		concurrency = r.opts.Concurrency
+	})
 
-		newq := make([]*fsrep.Replication, 0, len(r.queue))
-		for i := range r.queue {
-			if r.queue[i].CanRetry() {
-				newq = append(newq, r.queue[i])
-			} else {
-				r.completed = append(r.completed, r.queue[i])
-			}
+	results := make(chan fsrepRetryResult, concurrency)
+	running := 0
+
+	// set once a non-filesystem-specific error occurred, permanent errors take precedence
+	var stop *GlobalError
+	stopWith := func(err GlobalError) {
+		if stop == nil || (stop.Temporary && !err.Temporary) {
+			stop = &err
 		}
-		sort.SliceStable(newq, func(i, j int) bool {
-			return newq[i].NextStepDate().Before(newq[j].NextStepDate())
-		})
-		r.queue = newq
+	}
 
-		if len(r.queue) == 0 {
-			r.state = Completed
-			fsWithErr := FilesystemsReplicationFailedError{ // prepare it
-				FilesystemsWithError: make([]*fsrep.Replication, 0, len(r.completed)),
-			}
-			for _, fs := range r.completed {
-				if fs.CanRetry() {
-					panic(fmt.Sprintf("implementation error: completed contains retryable FS %s %#v",
-						fs.FS(), fs.Err()))
+	for {
+
+		var next []*fsrep.Replication
+		if stop == nil {
+			rsfNext := u(func(r *Replication) {
+
+				newq := make([]*fsrep.Replication, 0, len(r.queue))
+				for i := range r.queue {
+					if r.isActive(r.queue[i]) || r.queue[i].CanRetry() {
+						newq = append(newq, r.queue[i])
+					} else {
+						r.completed = append(r.completed, r.queue[i])
+					}
 				}
-				if fs.Err() != nil {
-					fsWithErr.FilesystemsWithError = append(fsWithErr.FilesystemsWithError, fs)
+				sort.SliceStable(newq, func(i, j int) bool {
+					return newq[i].NextStepDate().Before(newq[j].NextStepDate())
+				})
+				r.queue = newq
+
+				if len(r.queue) == 0 {
+					r.state = Completed
+					fsWithErr := FilesystemsReplicationFailedError{ // prepare it
+						FilesystemsWithError: make([]*fsrep.Replication, 0, len(r.completed)),
+					}
+					for _, fs := range r.completed {
+						if fs.CanRetry() {
+							panic(fmt.Sprintf("implementation error: completed contains retryable FS %s %#v",
+								fs.FS(), fs.Err()))
+						}
+						if fs.Err() != nil {
+							fsWithErr.FilesystemsWithError = append(fsWithErr.FilesystemsWithError, fs)
+						}
+					}
+					if len(fsWithErr.FilesystemsWithError) > 0 {
+						r.err = fsWithErr
+						r.state = PermanentError
+					}
+					return
 				}
+
+				// do not dequeue: if it's done, it will be sorted out the next time we check for more work
+				now := time.Now()
+				var earliestRetry time.Time
+				for _, fsr := range r.queue {
+					if len(r.active) >= r.opts.Concurrency {
+						break
+					}
+					if r.isActive(fsr) {
+						continue
+					}
+					if retryAt := fsr.RetryAt(); retryAt.After(now) {
+						if earliestRetry.IsZero() || retryAt.Before(earliestRetry) {
+							earliestRetry = retryAt
+						}
+						continue
+					}
+					r.active = append(r.active, fsr)
+					next = append(next, fsr)
+				}
+				if len(r.active) == 0 {
+					// all remaining filesystems back off
+					r.sleepUntil = earliestRetry
+					r.state = WorkingWait
+				}
+			}).rsf()
+			if running == 0 && len(next) == 0 {
+				return rsfNext
 			}
-			if len(fsWithErr.FilesystemsWithError) > 0 {
-				r.err = fsWithErr
-				r.state = PermanentError
-			}
-			return
 		}
 
-		active =  r.queue[0] // do not dequeue: if it's done, it will be sorted the next time we check for more work
-		r.active = active
-	}).rsf()
+		for _, fsr := range next {
+			running++
+			go func(fsr *fsrep.Replication) {
+				fsrCtx := fsrep.WithLogger(ctx, getLogger(ctx).WithField("fs", fsr.FS()))
+				err := fsr.Retry(fsrCtx, ka, sender, receiver)
+				results <- fsrepRetryResult{fsr, err}
+			}(fsr)
+		}
 
-	if active == nil {
-		return rsfNext
-	}
+		if running == 0 { // only reached if stop != nil
+			return u(func(r *Replication) {
+				r.err = *stop
+				if stop.Temporary {
+					r.failedAttempts++
+					r.sleepUntil = r.opts.Retry.retryAt(time.Now(), r.failedAttempts)
+					r.state = WorkingWait
+				} else {
+					r.state = PermanentError
+				}
+			}).rsf()
+		}
 
-	activeCtx := fsrep.WithLogger(ctx, getLogger(ctx).WithField("fs", active.FS()))
-	err := active.Retry(activeCtx, ka, sender, receiver)
-	u(func(r *Replication) {
-		r.active = nil
-	}).rsf()
+		res := <-results
+		running--
+		u(func(r *Replication) {
+			r.removeActive(res.fsr)
+		})
 
-	if err != nil {
-		if err.ContextErr() && ctx.Err() != nil {
-			getLogger(ctx).WithError(err).
-				Info("filesystem replication was cancelled")
-			u(func(r*Replication) {
-				r.err = GlobalError{Err: err, Temporary: false}
-				r.state = PermanentError
+		err := res.err
+		if err == nil {
+			u(func(r *Replication) {
+				r.failedAttempts = 0
 			})
+			continue
+		}
+		log := getLogger(ctx).WithField("fs", res.fsr.FS()).WithError(err)
+		if err.ContextErr() && ctx.Err() != nil {
+			log.Info("filesystem replication was cancelled")
+			stopWith(GlobalError{Err: err, Temporary: false})
 		} else if err.LocalToFS() {
-			getLogger(ctx).WithError(err).
-				Error("filesystem replication encountered a filesystem-specific error")
+			log.Error("filesystem replication encountered a filesystem-specific error")
 			// we stay in this state and let the queuing logic above de-prioritize this failing FS
+			if err.Temporary() {
+				var policy RetryPolicy
+				u(func(r *Replication) {
+					policy = r.opts.Retry
+				})
+				retryAt := policy.retryAt(time.Now(), res.fsr.FailedAttempts())
+				log.WithField("retry_at", retryAt).Info("backing off filesystem")
+				res.fsr.ScheduleRetry(retryAt)
+			}
 		} else if err.Temporary() {
-			getLogger(ctx).WithError(err).
-				Error("filesystem encountered a non-filesystem-specific temporary error, enter retry-wait")
-			u(func(r *Replication) {
-				r.err = GlobalError{Err: err, Temporary: true}
-				r.sleepUntil = time.Now().Add(RetryInterval)
-				r.state = WorkingWait
-			}).rsf()
+			log.Error("filesystem encountered a non-filesystem-specific temporary error, enter retry-wait")
+			stopWith(GlobalError{Err: err, Temporary: true})
 		} else {
-			getLogger(ctx).WithError(err).
-				Error("encountered a permanent non-filesystem-specific error")
-			u(func(r *Replication) {
-				r.err = GlobalError{Err: err, Temporary: false}
-				r.state = PermanentError
-			}).rsf()
+			log.Error("encountered a permanent non-filesystem-specific error")
+			stopWith(GlobalError{Err: err, Temporary: false})
 		}
 	}
-
-	return u(nil).rsf()
 }
 
 func stateWorkingWait(ctx context.Context, ka *watchdog.KeepAlive, sender Sender, receiver Receiver, u updater) state {
@@ -496,7 +808,7 @@ func stateWorkingWait(ctx context.Context, ka *watchdog.KeepAlive, sender Sender
 	u(func(r *Replication) {
 		sleepUntil = r.sleepUntil
 	})
-	t := time.NewTimer(RetryInterval)
+	t := time.NewTimer(time.Until(sleepUntil))
 	getLogger(ctx).WithField("until", sleepUntil).Info("retry wait after error")
 	defer t.Stop()
 	select {
@@ -508,6 +820,12 @@ func stateWorkingWait(ctx context.Context, ka *watchdog.KeepAlive, sender Sender
 
 	case <-t.C:
 	case <-wakeup.Wait(ctx):
+		// an explicit wakeup retries all filesystems immediately
+		u(func(r *Replication) {
+			for _, fsr := range r.queue {
+				fsr.ScheduleRetry(time.Time{})
+			}
+		})
 	}
 	return u(func(r *Replication) {
 		r.state = Working
@@ -524,6 +842,7 @@ func (r *Replication) Report() *Report {
 	rep := Report{
 		Status: r.state.String(),
 		SleepUntil: r.sleepUntil,
+		FailedAttempts: r.failedAttempts,
 	}
 
 	if r.err != nil {
@@ -537,14 +856,14 @@ func (r *Replication) Report() *Report {
 	rep.Pending = make([]*fsrep.Report, 0, len(r.queue))
 	rep.Completed = make([]*fsrep.Report, 0, len(r.completed)) // room for active (potentially)
 
-	// since r.active == r.queue[0], do not contain it in pending output
-	pending := r.queue
-	if r.active != nil {
-		rep.Active = r.active.Report()
-		pending = r.queue[1:]
-	}
-	for _, fsr := range pending {
-		rep.Pending= append(rep.Pending, fsr.Report())
+	// since r.active is a subset of r.queue, do not contain it in pending output
+	rep.Active = make([]*fsrep.Report, 0, len(r.active))
+	for _, fsr := range r.queue {
+		if r.isActive(fsr) {
+			rep.Active = append(rep.Active, fsr.Report())
+		} else {
+			rep.Pending = append(rep.Pending, fsr.Report())
+		}
 	}
 	for _, fsr := range r.completed {
 		rep.Completed = append(rep.Completed, fsr.Report())
@@ -232,14 +232,20 @@ func (m *ListFilesystemVersionsRes) GetVersions() []*FilesystemVersion {
 }
 
 type FilesystemVersion struct {
-	Type                 FilesystemVersion_VersionType `protobuf:"varint,1,opt,name=Type,proto3,enum=pdu.FilesystemVersion_VersionType" json:"Type,omitempty"`
-	Name                 string                        `protobuf:"bytes,2,opt,name=Name,proto3" json:"Name,omitempty"`
-	Guid                 uint64                        `protobuf:"varint,3,opt,name=Guid,proto3" json:"Guid,omitempty"`
-	CreateTXG            uint64                        `protobuf:"varint,4,opt,name=CreateTXG,proto3" json:"CreateTXG,omitempty"`
-	Creation             string                        `protobuf:"bytes,5,opt,name=Creation,proto3" json:"Creation,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}                      `json:"-"`
-	XXX_unrecognized     []byte                        `json:"-"`
-	XXX_sizecache        int32                         `json:"-"`
+	Type      FilesystemVersion_VersionType `protobuf:"varint,1,opt,name=Type,proto3,enum=pdu.FilesystemVersion_VersionType" json:"Type,omitempty"`
+	Name      string                        `protobuf:"bytes,2,opt,name=Name,proto3" json:"Name,omitempty"`
+	Guid      uint64                        `protobuf:"varint,3,opt,name=Guid,proto3" json:"Guid,omitempty"`
+	CreateTXG uint64                        `protobuf:"varint,4,opt,name=CreateTXG,proto3" json:"CreateTXG,omitempty"`
+	Creation  string                        `protobuf:"bytes,5,opt,name=Creation,proto3" json:"Creation,omitempty"`
+	// Number of holds on a snapshot (zfs userrefs), always 0 for bookmarks.
+	// A held snapshot cannot be destroyed.
+	UserRefs uint64 `protobuf:"varint,6,opt,name=UserRefs,proto3" json:"UserRefs,omitempty"`
+	// Space that would be freed by destroying only this snapshot (zfs used),
+	// always 0 for bookmarks.
+	Used                 uint64   `protobuf:"varint,7,opt,name=Used,proto3" json:"Used,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *FilesystemVersion) Reset()         { *m = FilesystemVersion{} }
@@ -301,6 +307,20 @@ func (m *FilesystemVersion) GetCreation() string {
 	return ""
 }
 
+func (m *FilesystemVersion) GetUserRefs() uint64 {
+	if m != nil {
+		return m.UserRefs
+	}
+	return 0
+}
+
+func (m *FilesystemVersion) GetUsed() uint64 {
+	if m != nil {
+		return m.Used
+	}
+	return 0
+}
+
 type SendReq struct {
 	Filesystem string `protobuf:"bytes,1,opt,name=Filesystem,proto3" json:"Filesystem,omitempty"`
 	From       string `protobuf:"bytes,2,opt,name=From,proto3" json:"From,omitempty"`
@@ -313,10 +333,19 @@ type SendReq struct {
 	// If ResumeToken is not empty, the GUIDs of From and To
 	// MUST correspond to those encoded in the ResumeToken.
 	// Otherwise, the Sender MUST return an error.
-	ResumeToken          string   `protobuf:"bytes,4,opt,name=ResumeToken,proto3" json:"ResumeToken,omitempty"`
-	Compress             bool     `protobuf:"varint,5,opt,name=Compress,proto3" json:"Compress,omitempty"`
-	Dedup                bool     `protobuf:"varint,6,opt,name=Dedup,proto3" json:"Dedup,omitempty"`
-	DryRun               bool     `protobuf:"varint,7,opt,name=DryRun,proto3" json:"DryRun,omitempty"`
+	ResumeToken string `protobuf:"bytes,4,opt,name=ResumeToken,proto3" json:"ResumeToken,omitempty"`
+	Compress    bool   `protobuf:"varint,5,opt,name=Compress,proto3" json:"Compress,omitempty"`
+	Dedup       bool   `protobuf:"varint,6,opt,name=Dedup,proto3" json:"Dedup,omitempty"`
+	DryRun      bool   `protobuf:"varint,7,opt,name=DryRun,proto3" json:"DryRun,omitempty"`
+	// Stream features required by the client (Compress = zfs send -c, LargeBlocks = -L,
+	// EmbeddedData = -e, Raw = -w, Properties = -p).
+	// The sender always uses the features it is configured to use.
+	// If a required feature is not among them, the sender MUST return an error.
+	// Dedup is not supported.
+	LargeBlocks          bool     `protobuf:"varint,8,opt,name=LargeBlocks,proto3" json:"LargeBlocks,omitempty"`
+	EmbeddedData         bool     `protobuf:"varint,9,opt,name=EmbeddedData,proto3" json:"EmbeddedData,omitempty"`
+	Raw                  bool     `protobuf:"varint,10,opt,name=Raw,proto3" json:"Raw,omitempty"`
+	Properties           bool     `protobuf:"varint,11,opt,name=Properties,proto3" json:"Properties,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -395,6 +424,34 @@ func (m *SendReq) GetDryRun() bool {
 	return false
 }
 
+func (m *SendReq) GetLargeBlocks() bool {
+	if m != nil {
+		return m.LargeBlocks
+	}
+	return false
+}
+
+func (m *SendReq) GetEmbeddedData() bool {
+	if m != nil {
+		return m.EmbeddedData
+	}
+	return false
+}
+
+func (m *SendReq) GetRaw() bool {
+	if m != nil {
+		return m.Raw
+	}
+	return false
+}
+
+func (m *SendReq) GetProperties() bool {
+	if m != nil {
+		return m.Properties
+	}
+	return false
+}
+
 type Property struct {
 	Name                 string   `protobuf:"bytes,1,opt,name=Name,proto3" json:"Name,omitempty"`
 	Value                string   `protobuf:"bytes,2,opt,name=Value,proto3" json:"Value,omitempty"`
@@ -501,7 +558,11 @@ func (m *SendRes) GetProperties() []*Property {
 type ReceiveReq struct {
 	Filesystem string `protobuf:"bytes,1,opt,name=Filesystem,proto3" json:"Filesystem,omitempty"`
 	// If true, the receiver should clear the resume token before perfoming the zfs recv of the stream in the request
-	ClearResumeToken     bool     `protobuf:"varint,2,opt,name=ClearResumeToken,proto3" json:"ClearResumeToken,omitempty"`
+	ClearResumeToken bool `protobuf:"varint,2,opt,name=ClearResumeToken,proto3" json:"ClearResumeToken,omitempty"`
+	// If true, the receiver should roll back the filesystem to its most recent snapshot
+	// before performing the zfs recv (zfs recv -F).
+	// Used after the snapshots of a diverged receiver have been destroyed.
+	Rollback             bool     `protobuf:"varint,3,opt,name=Rollback,proto3" json:"Rollback,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -545,6 +606,13 @@ func (m *ReceiveReq) GetClearResumeToken() bool {
 	return false
 }
 
+func (m *ReceiveReq) GetRollback() bool {
+	if m != nil {
+		return m.Rollback
+	}
+	return false
+}
+
 type ReceiveRes struct {
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
@@ -1066,6 +1134,117 @@ func _ReplicationCursorRes_OneofSizer(msg proto.Message) (n int) {
 	return n
 }
 
+type FilesystemSpaceReq struct {
+	Filesystem           string   `protobuf:"bytes,1,opt,name=Filesystem,proto3" json:"Filesystem,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FilesystemSpaceReq) Reset()         { *m = FilesystemSpaceReq{} }
+func (m *FilesystemSpaceReq) String() string { return proto.CompactTextString(m) }
+func (*FilesystemSpaceReq) ProtoMessage()    {}
+func (*FilesystemSpaceReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_pdu_fe566e6b212fcf8d, []int{16}
+}
+func (m *FilesystemSpaceReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FilesystemSpaceReq.Unmarshal(m, b)
+}
+func (m *FilesystemSpaceReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FilesystemSpaceReq.Marshal(b, m, deterministic)
+}
+func (dst *FilesystemSpaceReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FilesystemSpaceReq.Merge(dst, src)
+}
+func (m *FilesystemSpaceReq) XXX_Size() int {
+	return xxx_messageInfo_FilesystemSpaceReq.Size(m)
+}
+func (m *FilesystemSpaceReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_FilesystemSpaceReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FilesystemSpaceReq proto.InternalMessageInfo
+
+func (m *FilesystemSpaceReq) GetFilesystem() string {
+	if m != nil {
+		return m.Filesystem
+	}
+	return ""
+}
+
+type FilesystemSpaceRes struct {
+	// Properties used and available of the filesystem.
+	Used      uint64 `protobuf:"varint,1,opt,name=Used,proto3" json:"Used,omitempty"`
+	Available uint64 `protobuf:"varint,2,opt,name=Available,proto3" json:"Available,omitempty"`
+	// Properties used and available of the root filesystem of the filesystem's pool.
+	PoolUsed      uint64 `protobuf:"varint,3,opt,name=PoolUsed,proto3" json:"PoolUsed,omitempty"`
+	PoolAvailable uint64 `protobuf:"varint,4,opt,name=PoolAvailable,proto3" json:"PoolAvailable,omitempty"`
+	// Name of the filesystem's pool.
+	Pool                 string   `protobuf:"bytes,5,opt,name=Pool,proto3" json:"Pool,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FilesystemSpaceRes) Reset()         { *m = FilesystemSpaceRes{} }
+func (m *FilesystemSpaceRes) String() string { return proto.CompactTextString(m) }
+func (*FilesystemSpaceRes) ProtoMessage()    {}
+func (*FilesystemSpaceRes) Descriptor() ([]byte, []int) {
+	return fileDescriptor_pdu_fe566e6b212fcf8d, []int{17}
+}
+func (m *FilesystemSpaceRes) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FilesystemSpaceRes.Unmarshal(m, b)
+}
+func (m *FilesystemSpaceRes) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FilesystemSpaceRes.Marshal(b, m, deterministic)
+}
+func (dst *FilesystemSpaceRes) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FilesystemSpaceRes.Merge(dst, src)
+}
+func (m *FilesystemSpaceRes) XXX_Size() int {
+	return xxx_messageInfo_FilesystemSpaceRes.Size(m)
+}
+func (m *FilesystemSpaceRes) XXX_DiscardUnknown() {
+	xxx_messageInfo_FilesystemSpaceRes.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FilesystemSpaceRes proto.InternalMessageInfo
+
+func (m *FilesystemSpaceRes) GetUsed() uint64 {
+	if m != nil {
+		return m.Used
+	}
+	return 0
+}
+
+func (m *FilesystemSpaceRes) GetAvailable() uint64 {
+	if m != nil {
+		return m.Available
+	}
+	return 0
+}
+
+func (m *FilesystemSpaceRes) GetPoolUsed() uint64 {
+	if m != nil {
+		return m.PoolUsed
+	}
+	return 0
+}
+
+func (m *FilesystemSpaceRes) GetPoolAvailable() uint64 {
+	if m != nil {
+		return m.PoolAvailable
+	}
+	return 0
+}
+
+func (m *FilesystemSpaceRes) GetPool() string {
+	if m != nil {
+		return m.Pool
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*ListFilesystemReq)(nil), "pdu.ListFilesystemReq")
 	proto.RegisterType((*ListFilesystemRes)(nil), "pdu.ListFilesystemRes")
@@ -1085,53 +1264,64 @@ func init() {
 	proto.RegisterType((*ReplicationCursorReq_GetOp)(nil), "pdu.ReplicationCursorReq.GetOp")
 	proto.RegisterType((*ReplicationCursorReq_SetOp)(nil), "pdu.ReplicationCursorReq.SetOp")
 	proto.RegisterType((*ReplicationCursorRes)(nil), "pdu.ReplicationCursorRes")
+	proto.RegisterType((*FilesystemSpaceReq)(nil), "pdu.FilesystemSpaceReq")
+	proto.RegisterType((*FilesystemSpaceRes)(nil), "pdu.FilesystemSpaceRes")
 	proto.RegisterEnum("pdu.FilesystemVersion_VersionType", FilesystemVersion_VersionType_name, FilesystemVersion_VersionType_value)
 }
 
 func init() { proto.RegisterFile("pdu.proto", fileDescriptor_pdu_fe566e6b212fcf8d) }
 
 var fileDescriptor_pdu_fe566e6b212fcf8d = []byte{
-	// 659 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x55, 0xdb, 0x6e, 0x13, 0x31,
-	0x10, 0xcd, 0xe6, 0xba, 0x99, 0x94, 0x5e, 0xdc, 0xaa, 0x2c, 0x15, 0x82, 0xc8, 0xbc, 0x04, 0x24,
-	0x22, 0x91, 0x56, 0xbc, 0xf0, 0x96, 0xde, 0xf2, 0x80, 0xda, 0xca, 0x09, 0x55, 0x9f, 0x90, 0x42,
-	0x77, 0x44, 0x57, 0xb9, 0x78, 0x6b, 0x7b, 0x51, 0xc3, 0x07, 0xf0, 0x4f, 0xfc, 0x07, 0x0f, 0x7c,
-	0x0e, 0xf2, 0xec, 0x25, 0xdb, 0x24, 0x54, 0x79, 0x8a, 0xcf, 0xf8, 0x78, 0xe6, 0xcc, 0xf1, 0x8e,
-	0x03, 0xf5, 0xd0, 0x8f, 0xda, 0xa1, 0x92, 0x46, 0xb2, 0x52, 0xe8, 0x47, 0x7c, 0x17, 0x76, 0x3e,
-	0x07, 0xda, 0x9c, 0x05, 0x63, 0xd4, 0x33, 0x6d, 0x70, 0x22, 0xf0, 0x9e, 0x9f, 0x2d, 0x07, 0x35,
-	0xfb, 0x00, 0x8d, 0x79, 0x40, 0x7b, 0x4e, 0xb3, 0xd4, 0x6a, 0x74, 0xb6, 0xda, 0x36, 0x5f, 0x8e,
-	0x98, 0xe7, 0xf0, 0x2e, 0xc0, 0x1c, 0x32, 0x06, 0xe5, 0xab, 0xa1, 0xb9, 0xf3, 0x9c, 0xa6, 0xd3,
-	0xaa, 0x0b, 0x5a, 0xb3, 0x26, 0x34, 0x04, 0xea, 0x68, 0x82, 0x03, 0x39, 0xc2, 0xa9, 0x57, 0xa4,
-	0xad, 0x7c, 0x88, 0x7f, 0x82, 0x17, 0x8f, 0xb5, 0x5c, 0xa3, 0xd2, 0x81, 0x9c, 0x6a, 0x81, 0xf7,
-	0xec, 0x55, 0xbe, 0x40, 0x92, 0x38, 0x17, 0xe1, 0x97, 0xff, 0x3f, 0xac, 0x59, 0x07, 0xdc, 0x14,
-	0x26, 0xdd, 0xec, 0x2f, 0x74, 0x93, 0x6c, 0x8b, 0x8c, 0xc7, 0xff, 0x3a, 0xb0, 0xb3, 0xb4, 0xcf,
-	0x3e, 0x42, 0x79, 0x30, 0x0b, 0x91, 0x04, 0x6c, 0x76, 0xf8, 0xea, 0x2c, 0xed, 0xe4, 0xd7, 0x32,
-	0x05, 0xf1, 0xad, 0x23, 0x17, 0xc3, 0x09, 0x26, 0x6d, 0xd3, 0xda, 0xc6, 0xce, 0xa3, 0xc0, 0xf7,
-	0x4a, 0x4d, 0xa7, 0x55, 0x16, 0xb4, 0x66, 0x2f, 0xa1, 0x7e, 0xac, 0x70, 0x68, 0x70, 0x70, 0x73,
-	0xee, 0x95, 0x69, 0x63, 0x1e, 0x60, 0x07, 0xe0, 0x12, 0x08, 0xe4, 0xd4, 0xab, 0x50, 0xa6, 0x0c,
-	0xf3, 0xb7, 0xd0, 0xc8, 0x95, 0x65, 0x1b, 0xe0, 0xf6, 0xa7, 0xc3, 0x50, 0xdf, 0x49, 0xb3, 0x5d,
-	0xb0, 0xa8, 0x2b, 0xe5, 0x68, 0x32, 0x54, 0xa3, 0x6d, 0x87, 0xff, 0x76, 0xa0, 0xd6, 0xc7, 0xa9,
-	0xbf, 0x86, 0xaf, 0x56, 0xe4, 0x99, 0x92, 0x93, 0x54, 0xb8, 0x5d, 0xb3, 0x4d, 0x28, 0x0e, 0x24,
-	0xc9, 0xae, 0x8b, 0xe2, 0x40, 0x2e, 0x5e, 0x6d, 0x79, 0xe9, 0x6a, 0x49, 0xb8, 0x9c, 0x84, 0x0a,
-	0xb5, 0x26, 0xe1, 0xae, 0xc8, 0x30, 0xdb, 0x83, 0xca, 0x09, 0xfa, 0x51, 0xe8, 0x55, 0x69, 0x23,
-	0x06, 0x6c, 0x1f, 0xaa, 0x27, 0x6a, 0x26, 0xa2, 0xa9, 0x57, 0xa3, 0x70, 0x82, 0xf8, 0x11, 0xb8,
-	0x57, 0x4a, 0x86, 0xa8, 0xcc, 0x2c, 0x33, 0xd5, 0xc9, 0x99, 0xba, 0x07, 0x95, 0xeb, 0xe1, 0x38,
-	0x4a, 0x9d, 0x8e, 0x01, 0xff, 0x95, 0x75, 0xac, 0x59, 0x0b, 0xb6, 0xbe, 0x68, 0xf4, 0xf3, 0x8a,
-	0x1d, 0x2a, 0xb1, 0x18, 0x66, 0x1c, 0x36, 0x4e, 0x1f, 0x42, 0xbc, 0x35, 0xe8, 0xf7, 0x83, 0x9f,
-	0x71, 0xca, 0x92, 0x78, 0x14, 0x63, 0xef, 0x01, 0x12, 0x3d, 0x01, 0x6a, 0xaf, 0x44, 0x1f, 0xd7,
-	0x33, 0xfa, 0x2c, 0x52, 0x99, 0x22, 0x47, 0xe0, 0x37, 0x00, 0x02, 0x6f, 0x31, 0xf8, 0x81, 0xeb,
-	0x98, 0xff, 0x0e, 0xb6, 0x8f, 0xc7, 0x38, 0x54, 0x8b, 0x83, 0xe3, 0x8a, 0xa5, 0x38, 0xdf, 0xc8,
-	0x65, 0xd6, 0x7c, 0x04, 0xbb, 0x27, 0xa8, 0x8d, 0x92, 0xb3, 0xf4, 0x2b, 0x58, 0x67, 0x8a, 0xd8,
-	0x11, 0xd4, 0x33, 0xbe, 0x57, 0x7c, 0x72, 0x52, 0xe6, 0x44, 0xfe, 0x15, 0xd8, 0x42, 0xb1, 0x64,
-	0xe8, 0x52, 0x48, 0x95, 0x9e, 0x18, 0xba, 0x94, 0x67, 0x6f, 0xef, 0x54, 0x29, 0xa9, 0xd2, 0xdb,
-	0x23, 0xc0, 0x7b, 0xab, 0x9a, 0xb1, 0xcf, 0x54, 0xcd, 0x1a, 0x30, 0x36, 0xe9, 0x50, 0x3f, 0xa7,
-	0xfc, 0xcb, 0x52, 0x44, 0xca, 0xe3, 0x7f, 0x1c, 0xd8, 0x13, 0x18, 0x8e, 0x83, 0x5b, 0x1a, 0x9a,
-	0xe3, 0x48, 0x69, 0xa9, 0xd6, 0x31, 0xe6, 0x10, 0x4a, 0xdf, 0xd1, 0x90, 0xac, 0x46, 0xe7, 0x35,
-	0xd5, 0x59, 0x95, 0xa7, 0x7d, 0x8e, 0xe6, 0x32, 0xec, 0x15, 0x84, 0x65, 0xdb, 0x43, 0x1a, 0x0d,
-	0x0d, 0xca, 0x93, 0x87, 0xfa, 0xe9, 0x21, 0x8d, 0xe6, 0xa0, 0x06, 0x15, 0x4a, 0x72, 0xf0, 0x06,
-	0x2a, 0xb4, 0x61, 0x87, 0x27, 0x33, 0x32, 0xf6, 0x25, 0xc3, 0xdd, 0x32, 0x14, 0x65, 0xc8, 0x07,
-	0x2b, 0xbb, 0xb2, 0xa3, 0x15, 0xbf, 0x30, 0xb6, 0x9f, 0x72, 0xaf, 0x90, 0xbd, 0x31, 0xee, 0x85,
-	0x34, 0xf8, 0x10, 0xe8, 0x38, 0x9f, 0xdb, 0x2b, 0x88, 0x2c, 0xd2, 0x75, 0xa1, 0x1a, 0xbb, 0xf5,
-	0xad, 0x4a, 0x7f, 0x1e, 0x87, 0xff, 0x02, 0x00, 0x00, 0xff, 0xff, 0x66, 0x74, 0x36, 0x3a, 0x49,
-	0x06, 0x00, 0x00,
+	// 809 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x55, 0xcd, 0x6e, 0xdb, 0x46,
+	0x10, 0x36, 0x45, 0xca, 0xa6, 0x46, 0x4e, 0xe2, 0x6c, 0x8c, 0x94, 0x35, 0x8a, 0xd6, 0xd8, 0xf6,
+	0xa0, 0x16, 0xa8, 0x80, 0x2a, 0x46, 0x2f, 0x3d, 0x55, 0x56, 0x6c, 0x1d, 0x82, 0xc4, 0x58, 0x29,
+	0x41, 0x4f, 0x05, 0x28, 0x71, 0x9a, 0x10, 0xa2, 0xb4, 0xcc, 0xee, 0x32, 0x8d, 0xfa, 0x00, 0x45,
+	0x1f, 0xa2, 0x2f, 0xd1, 0x77, 0xe9, 0x03, 0x15, 0x3b, 0xfc, 0xd5, 0x4f, 0x1d, 0x9d, 0xb8, 0xdf,
+	0x37, 0xff, 0x33, 0xbb, 0x43, 0xe8, 0xa4, 0x51, 0xd6, 0x4f, 0x95, 0x34, 0x92, 0xb9, 0x69, 0x94,
+	0xf1, 0x27, 0xf0, 0xf8, 0x45, 0xac, 0xcd, 0x4d, 0x9c, 0xa0, 0x5e, 0x6b, 0x83, 0x4b, 0x81, 0xef,
+	0xf9, 0xcd, 0x2e, 0xa9, 0xd9, 0x0f, 0xd0, 0xad, 0x09, 0x1d, 0x38, 0x97, 0x6e, 0xaf, 0x3b, 0x78,
+	0xd4, 0xb7, 0xfe, 0x1a, 0x8a, 0x4d, 0x1d, 0x3e, 0x04, 0xa8, 0x21, 0x63, 0xe0, 0xdd, 0x85, 0xe6,
+	0x5d, 0xe0, 0x5c, 0x3a, 0xbd, 0x8e, 0xa0, 0x33, 0xbb, 0x84, 0xae, 0x40, 0x9d, 0x2d, 0x71, 0x2a,
+	0x17, 0xb8, 0x0a, 0x5a, 0x24, 0x6a, 0x52, 0xfc, 0x27, 0xf8, 0x7c, 0x33, 0x97, 0x37, 0xa8, 0x74,
+	0x2c, 0x57, 0x5a, 0xe0, 0x7b, 0xf6, 0x65, 0x33, 0x40, 0xe1, 0xb8, 0xc1, 0xf0, 0x57, 0xff, 0x6f,
+	0xac, 0xd9, 0x00, 0xfc, 0x12, 0x16, 0xd5, 0x3c, 0xdd, 0xaa, 0xa6, 0x10, 0x8b, 0x4a, 0x8f, 0xff,
+	0xd5, 0x82, 0xc7, 0x3b, 0x72, 0xf6, 0x23, 0x78, 0xd3, 0x75, 0x8a, 0x94, 0xc0, 0xc3, 0x01, 0xdf,
+	0xef, 0xa5, 0x5f, 0x7c, 0xad, 0xa6, 0x20, 0x7d, 0xdb, 0x91, 0x97, 0xe1, 0x12, 0x8b, 0xb2, 0xe9,
+	0x6c, 0xb9, 0xdb, 0x2c, 0x8e, 0x02, 0xf7, 0xd2, 0xe9, 0x79, 0x82, 0xce, 0xec, 0x0b, 0xe8, 0x5c,
+	0x2b, 0x0c, 0x0d, 0x4e, 0x7f, 0xb9, 0x0d, 0x3c, 0x12, 0xd4, 0x04, 0xbb, 0x00, 0x9f, 0x40, 0x2c,
+	0x57, 0x41, 0x9b, 0x3c, 0x55, 0xd8, 0xca, 0x5e, 0x6b, 0x54, 0x02, 0x7f, 0xd3, 0xc1, 0x31, 0x19,
+	0x56, 0xd8, 0x46, 0x7a, 0xad, 0x31, 0x0a, 0x4e, 0xf2, 0x48, 0xf6, 0xcc, 0xbf, 0x85, 0x6e, 0x23,
+	0x4d, 0x76, 0x0a, 0xfe, 0x64, 0x15, 0xa6, 0xfa, 0x9d, 0x34, 0x67, 0x47, 0x16, 0x0d, 0xa5, 0x5c,
+	0x2c, 0x43, 0xb5, 0x38, 0x73, 0xf8, 0x3f, 0x2d, 0x38, 0x99, 0xe0, 0x2a, 0x3a, 0x60, 0x0e, 0x36,
+	0xd4, 0x8d, 0x92, 0xcb, 0xb2, 0x50, 0x7b, 0x66, 0x0f, 0xa1, 0x35, 0x95, 0x54, 0x66, 0x47, 0xb4,
+	0xa6, 0x72, 0xfb, 0x2a, 0x78, 0x3b, 0x57, 0x81, 0x0a, 0x95, 0xcb, 0x54, 0xa1, 0xd6, 0x54, 0xa8,
+	0x2f, 0x2a, 0xcc, 0xce, 0xa1, 0x3d, 0xc2, 0x28, 0x4b, 0xa9, 0x4a, 0x5f, 0xe4, 0x80, 0x3d, 0x85,
+	0xe3, 0x91, 0x5a, 0x8b, 0x6c, 0x45, 0x45, 0xfa, 0xa2, 0x40, 0x36, 0xd6, 0x8b, 0x50, 0xbd, 0xc5,
+	0x61, 0x22, 0xe7, 0x0b, 0x1d, 0xf8, 0x24, 0x6c, 0x52, 0x8c, 0xc3, 0xe9, 0xf3, 0xe5, 0x0c, 0xa3,
+	0x08, 0xa3, 0x51, 0x68, 0xc2, 0xa0, 0x43, 0x2a, 0x1b, 0x1c, 0x3b, 0x03, 0x57, 0x84, 0xbf, 0x07,
+	0x40, 0x22, 0x7b, 0xb4, 0x7d, 0xb8, 0x53, 0x32, 0x45, 0x65, 0x62, 0xd4, 0x41, 0x97, 0x04, 0x0d,
+	0x86, 0x5f, 0x81, 0x5f, 0xa0, 0x75, 0x35, 0x7c, 0xa7, 0x31, 0xfc, 0x73, 0x68, 0xbf, 0x09, 0x93,
+	0xac, 0xbc, 0x11, 0x39, 0xe0, 0x7f, 0x3a, 0x65, 0xa7, 0x35, 0xeb, 0xc1, 0x23, 0x3b, 0xa8, 0x66,
+	0xa7, 0x1c, 0x0a, 0xb3, 0x4d, 0x53, 0x05, 0x1f, 0x53, 0x9c, 0x1b, 0x8c, 0x26, 0xf1, 0x1f, 0xb9,
+	0x4b, 0x57, 0x6c, 0x70, 0xec, 0xfb, 0x8d, 0x7c, 0x5d, 0x7a, 0x04, 0x0f, 0xe8, 0xfa, 0x96, 0x69,
+	0x6e, 0xa4, 0x6f, 0x00, 0x04, 0xce, 0x31, 0xfe, 0x80, 0x87, 0x0c, 0xfd, 0x3b, 0x38, 0xbb, 0x4e,
+	0x30, 0x54, 0xdb, 0x0f, 0xdc, 0x17, 0x3b, 0xbc, 0x1d, 0xad, 0x90, 0x49, 0x32, 0x0b, 0xe7, 0x0b,
+	0xba, 0x12, 0xbe, 0xa8, 0x30, 0x3f, 0x6d, 0x44, 0xd5, 0x7c, 0x01, 0x4f, 0x46, 0xa8, 0x8d, 0x92,
+	0xeb, 0xf2, 0x66, 0x1e, 0xb2, 0x09, 0xd8, 0x15, 0x74, 0x2a, 0xfd, 0xa0, 0x75, 0xef, 0x6b, 0xaf,
+	0x15, 0xf9, 0xaf, 0xc0, 0xb6, 0x82, 0x15, 0x8b, 0xa3, 0x84, 0x14, 0xe9, 0x9e, 0xc5, 0x51, 0xea,
+	0xd9, 0xc9, 0x3e, 0x57, 0x4a, 0xaa, 0x72, 0xb2, 0x04, 0xf8, 0x78, 0x5f, 0x31, 0x76, 0xd5, 0x9e,
+	0xd8, 0xe6, 0x24, 0xa6, 0x5c, 0x4c, 0x9f, 0x91, 0xff, 0xdd, 0x54, 0x44, 0xa9, 0xc7, 0xff, 0x75,
+	0xe0, 0x5c, 0x60, 0x9a, 0xc4, 0x73, 0x7a, 0xf8, 0xd7, 0x99, 0xd2, 0x52, 0x1d, 0xd2, 0x98, 0x67,
+	0xe0, 0xbe, 0x45, 0x43, 0x69, 0x75, 0x07, 0x5f, 0x51, 0x9c, 0x7d, 0x7e, 0xfa, 0xb7, 0x68, 0x5e,
+	0xa5, 0xe3, 0x23, 0x61, 0xb5, 0xad, 0x91, 0x46, 0x13, 0xb8, 0x9f, 0x32, 0x9a, 0x94, 0x46, 0x1a,
+	0xcd, 0xc5, 0x09, 0xb4, 0xc9, 0xc9, 0xc5, 0xd7, 0xd0, 0x26, 0x81, 0x9d, 0x7a, 0xd5, 0xc8, 0xbc,
+	0x2f, 0x15, 0x1e, 0x7a, 0xd0, 0x92, 0x29, 0x9f, 0xee, 0xad, 0xca, 0x3e, 0xf7, 0x7c, 0x4b, 0xda,
+	0x7a, 0xbc, 0xf1, 0x51, 0xb5, 0x27, 0xfd, 0x97, 0xd2, 0xe0, 0xc7, 0x58, 0xe7, 0xfe, 0xfc, 0xf1,
+	0x91, 0xa8, 0x98, 0xa1, 0x0f, 0xc7, 0x79, 0xb7, 0xf8, 0x15, 0xb0, 0xba, 0x03, 0x93, 0x34, 0x9c,
+	0x1f, 0x72, 0x9f, 0xf9, 0xdf, 0xce, 0x1e, 0xb3, 0x7a, 0x8d, 0x3a, 0xf5, 0x1a, 0xb5, 0x0b, 0xfb,
+	0xe7, 0x0f, 0x61, 0x9c, 0x84, 0xb3, 0x24, 0x7f, 0x78, 0x9e, 0xa8, 0x09, 0x5b, 0xf6, 0x9d, 0x94,
+	0x09, 0x59, 0xe5, 0x6b, 0xbe, 0xc2, 0xec, 0x1b, 0x78, 0x60, 0xcf, 0xb5, 0x75, 0xbe, 0xee, 0x37,
+	0x49, 0xfa, 0x95, 0x4a, 0x99, 0x14, 0xeb, 0x9e, 0xce, 0xb3, 0x63, 0xfa, 0xab, 0x3f, 0xfb, 0x6f,
+	0x00, 0x78, 0xfd, 0x13, 0xd2, 0xe2, 0x07, 0x00, 0x00,
 }
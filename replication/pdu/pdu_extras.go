@@ -41,6 +41,8 @@ func FilesystemVersionFromZFS(fsv *zfs.FilesystemVersion) *FilesystemVersion {
 		Guid:      fsv.Guid,
 		CreateTXG: fsv.CreateTXG,
 		Creation:  fsv.Creation.Format(time.RFC3339),
+		UserRefs:  fsv.UserRefs,
+		Used:      fsv.Used,
 	}
 }
 
@@ -72,5 +74,7 @@ func (v *FilesystemVersion) ZFSFilesystemVersion() (*zfs.FilesystemVersion, erro
 		Guid:      v.Guid,
 		CreateTXG: v.CreateTXG,
 		Creation:  ct,
+		UserRefs:  v.UserRefs,
+		Used:      v.Used,
 	}, nil
 }
@@ -0,0 +1,50 @@
+package replication
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/zrepl/zrepl/replication/fsrep"
+)
+
+// RetryPolicy configures the exponential backoff after temporary errors and
+// the per-filesystem retry budget.
+// The zero value retries every RetryInterval forever.
+type RetryPolicy struct {
+	// Wait before the first retry, doubled for each consecutive retry up to MaxInterval.
+	// 0 means RetryInterval.
+	InitialInterval time.Duration
+	// Maximum wait between retries, values below InitialInterval mean InitialInterval.
+	MaxInterval time.Duration
+	// Budget of each filesystem, see fsrep.RetryBudget.
+	Budget fsrep.RetryBudget
+}
+
+// retryJitter is the fraction by which a backoff is randomly shortened or extended,
+// so that jobs that failed at the same time do not retry in lockstep.
+const retryJitter = 0.2
+
+// backoff returns the wait before the retry after the attempt-th consecutive failure (starting at 1), including jitter.
+func (p RetryPolicy) backoff(attempt int, rnd func() float64) time.Duration {
+	initial := p.InitialInterval
+	if initial <= 0 {
+		initial = RetryInterval
+	}
+	max := p.MaxInterval
+	if max < initial {
+		max = initial
+	}
+	d := initial
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return time.Duration(float64(d) * (1 + retryJitter*(2*rnd()-1)))
+}
+
+// retryAt returns the time of the retry after the attempt-th consecutive failure.
+func (p RetryPolicy) retryAt(now time.Time, attempt int) time.Time {
+	return now.Add(p.backoff(attempt, rand.Float64))
+}
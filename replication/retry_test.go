@@ -0,0 +1,28 @@
+package replication
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	noJitter := func() float64 { return 0.5 }
+	p := RetryPolicy{InitialInterval: 10 * time.Second, MaxInterval: time.Minute}
+	expected := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for i, e := range expected {
+		assert.Equal(t, e, p.backoff(i+1, noJitter), "attempt %d", i+1)
+	}
+	// does not overflow
+	assert.Equal(t, time.Minute, p.backoff(1000, noJitter))
+
+	// jitter
+	assert.Equal(t, 8*time.Second, p.backoff(1, func() float64 { return 0 }))
+	assert.Equal(t, 12*time.Second, p.backoff(1, func() float64 { return 1 }))
+
+	// zero value retries every RetryInterval
+	var zero RetryPolicy
+	assert.Equal(t, RetryInterval, zero.backoff(1, noJitter))
+	assert.Equal(t, RetryInterval, zero.backoff(5, noJitter))
+}
@@ -0,0 +1,222 @@
+// Package cron implements parsing and evaluation of cron(5)-style schedules.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression with the five fields
+//
+//	minute hour day-of-month month day-of-week
+//
+// Each field is either *, a number, a range a-b, a step */n or a-b/n, or a comma-separated list thereof.
+// Month and day-of-week also accept three-letter English names (jan, mon), Sunday is 0 or 7.
+// Like in Vixie cron, if both day-of-month and day-of-week are restricted (do not start with *),
+// a day matches if either field matches.
+type Schedule struct {
+	spec                     string
+	minute, hour, dom, month uint64 // bit i set if value i matches
+	dow                      uint64
+	domStar, dowStar         bool
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    []string // names[i] is an alias for min+i
+}
+
+var (
+	fieldMinute = field{"minute", 0, 59, nil}
+	fieldHour   = field{"hour", 0, 23, nil}
+	fieldDOM    = field{"day-of-month", 1, 31, nil}
+	fieldMonth  = field{"month", 1, 12, []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	fieldDOW    = field{"day-of-week", 0, 7, []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// Parse parses a cron expression, see Schedule.
+func Parse(spec string) (*Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, has %d", spec, len(fields))
+	}
+	s := &Schedule{spec: spec}
+	var err error
+	if s.minute, _, err = fieldMinute.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if s.hour, _, err = fieldHour.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if s.dom, s.domStar, err = fieldDOM.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if s.month, _, err = fieldMonth.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if s.dow, s.dowStar, err = fieldDOW.parse(fields[4]); err != nil {
+		return nil, err
+	}
+	if s.dow&(1<<7) != 0 { // 7 is Sunday
+		s.dow |= 1 << 0
+	}
+	return s, nil
+}
+
+func (s *Schedule) String() string { return s.spec }
+
+// ParseWeekdays parses a day-of-week field (e.g. mon-fri or sat,sun) like Parse does.
+// Bit i of the result is set if time.Weekday(i) matches.
+func ParseWeekdays(expr string) (uint8, error) {
+	bits, _, err := fieldDOW.parse(expr)
+	if err != nil {
+		return 0, err
+	}
+	if bits&(1<<7) != 0 { // 7 is Sunday
+		bits |= 1 << 0
+	}
+	return uint8(bits &^ (1 << 7)), nil
+}
+
+func (f field) parse(expr string) (bits uint64, star bool, err error) {
+	for _, part := range strings.Split(expr, ",") {
+		b, err := f.parsePart(part)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid %s field %q: %s", f.name, expr, err)
+		}
+		bits |= b
+	}
+	return bits, strings.HasPrefix(expr, "*"), nil // like Vixie cron
+}
+
+func (f field) parsePart(part string) (uint64, error) {
+	rng, step := part, 1
+	if i := strings.IndexByte(part, '/'); i != -1 {
+		var err error
+		rng = part[:i]
+		step, err = strconv.Atoi(part[i+1:])
+		if err != nil || step <= 0 {
+			return 0, fmt.Errorf("invalid step %q", part[i+1:])
+		}
+	}
+
+	var lo, hi int
+	if rng == "*" {
+		lo, hi = f.min, f.max
+	} else if i := strings.IndexByte(rng, '-'); i != -1 {
+		var err error
+		if lo, err = f.value(rng[:i]); err != nil {
+			return 0, err
+		}
+		if hi, err = f.value(rng[i+1:]); err != nil {
+			return 0, err
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("invalid range %q", rng)
+		}
+	} else {
+		v, err := f.value(rng)
+		if err != nil {
+			return 0, err
+		}
+		lo, hi = v, v
+		if step != 1 { // a/n means a-max/n
+			hi = f.max
+		}
+	}
+
+	var bits uint64
+	for v := lo; v <= hi; v += step {
+		bits |= 1 << uint(v)
+	}
+	return bits, nil
+}
+
+func (f field) value(s string) (int, error) {
+	for i, n := range f.names {
+		if strings.EqualFold(s, n) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+func has(bits uint64, v int) bool { return bits&(1<<uint(v)) != 0 }
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom, dow := has(s.dom, t.Day()), has(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// maximum search horizon of Next, covers e.g. 29th of February on a Monday
+const searchYears = 30
+
+// Next returns the earliest time after t that matches the schedule.
+// The schedule is evaluated in t's location.
+// Returns the zero time if the schedule never matches (e.g. 30th of February).
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(searchYears, 0, 0)
+
+	for t.Before(end) {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !has(s.hour, t.Hour()) {
+			// not time.Date(..., t.Hour()+1, ...), which does not advance in the hour repeated at the end of DST
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			continue
+		}
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// Schedules is the union of multiple schedules.
+type Schedules []*Schedule
+
+// ParseAll parses each spec in specs, see Parse.
+func ParseAll(specs []string) (Schedules, error) {
+	ss := make(Schedules, len(specs))
+	for i, spec := range specs {
+		var err error
+		if ss[i], err = Parse(spec); err != nil {
+			return nil, err
+		}
+	}
+	return ss, nil
+}
+
+// Next returns the earliest time after t that matches any of the schedules, see Schedule.Next.
+func (ss Schedules) Next(t time.Time) (next time.Time) {
+	for _, s := range ss {
+		n := s.Next(t)
+		if !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return next
+}
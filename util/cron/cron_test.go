@@ -0,0 +1,103 @@
+package cron
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	invalid := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"1,,2 * * * *",
+	}
+	for _, spec := range invalid {
+		_, err := Parse(spec)
+		assert.Error(t, err, "spec %q", spec)
+	}
+}
+
+func TestNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	date := func(loc *time.Location, y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, loc)
+	}
+
+	tcs := []struct {
+		spec     string
+		from     time.Time
+		expected time.Time
+	}{
+		{"* * * * *", date(time.UTC, 2018, 10, 1, 12, 0).Add(30 * time.Second), date(time.UTC, 2018, 10, 1, 12, 1)},
+		{"0 * * * *", date(time.UTC, 2018, 10, 1, 12, 0), date(time.UTC, 2018, 10, 1, 13, 0)},
+		{"0 2 * * *", date(time.UTC, 2018, 10, 1, 12, 0), date(time.UTC, 2018, 10, 2, 2, 0)},
+		{"*/15 9-17 * * mon-fri", date(time.UTC, 2018, 10, 5, 17, 45), date(time.UTC, 2018, 10, 8, 9, 0)}, // Friday => Monday
+		{"*/15 9-17 * * 1-5", date(time.UTC, 2018, 10, 1, 9, 1), date(time.UTC, 2018, 10, 1, 9, 15)},
+		{"5/20 * * * *", date(time.UTC, 2018, 10, 1, 12, 30), date(time.UTC, 2018, 10, 1, 12, 45)},
+		{"0 0 1 jan *", date(time.UTC, 2018, 10, 1, 12, 0), date(time.UTC, 2019, 1, 1, 0, 0)},
+		{"0 0 29 2 *", date(time.UTC, 2018, 10, 1, 12, 0), date(time.UTC, 2020, 2, 29, 0, 0)},
+		{"0 0 * * 7", date(time.UTC, 2018, 10, 1, 12, 0), date(time.UTC, 2018, 10, 7, 0, 0)}, // Sunday
+		// day-of-month OR day-of-week
+		{"0 0 15 * mon", date(time.UTC, 2018, 10, 1, 12, 0), date(time.UTC, 2018, 10, 8, 0, 0)},
+		{"0 0 2 * mon", date(time.UTC, 2018, 10, 1, 12, 0), date(time.UTC, 2018, 10, 2, 0, 0)},
+		{"0 0 30 2 *", date(time.UTC, 2018, 10, 1, 12, 0), time.Time{}},
+		// evaluated in the location of from
+		{"0 2 * * *", date(berlin, 2018, 10, 1, 12, 0), date(berlin, 2018, 10, 2, 2, 0)},
+		// start of DST: 02:00 - 03:00 does not exist
+		{"30 2 * * *", date(berlin, 2018, 3, 24, 12, 0), date(berlin, 2018, 3, 26, 2, 30)},
+		{"0 * * * *", date(berlin, 2018, 3, 25, 1, 30), date(berlin, 2018, 3, 25, 3, 0)},
+		// end of DST: 02:00 - 03:00 is repeated, the hourly schedule must fire in both
+		{"0 * * * *", date(time.UTC, 2018, 10, 27, 23, 30).In(berlin), date(time.UTC, 2018, 10, 28, 0, 0)}, // 01:30 CEST => 02:00 CEST
+		{"0 * * * *", date(time.UTC, 2018, 10, 28, 0, 30).In(berlin), date(time.UTC, 2018, 10, 28, 1, 0)},  // 02:30 CEST => 02:00 CET
+	}
+
+	for _, tc := range tcs {
+		s, err := Parse(tc.spec)
+		require.NoError(t, err, "spec %q", tc.spec)
+		next := s.Next(tc.from)
+		assert.True(t, tc.expected.Equal(next), "spec %q from %s: expected %s, got %s", tc.spec, tc.from, tc.expected, next)
+	}
+}
+
+func TestSchedulesNext(t *testing.T) {
+	ss, err := ParseAll([]string{"0 2 * * *", "*/15 9-17 * * 1-5"})
+	require.NoError(t, err)
+
+	from := time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC) // Monday
+	assert.Equal(t, time.Date(2018, 10, 1, 2, 0, 0, 0, time.UTC), ss.Next(from))
+	assert.Equal(t, time.Date(2018, 10, 1, 9, 0, 0, 0, time.UTC), ss.Next(ss.Next(from)))
+
+	_, err = ParseAll([]string{"0 2 * * *", "invalid"})
+	assert.Error(t, err)
+}
+
+func TestParseWeekdays(t *testing.T) {
+	tcs := map[string]uint8{
+		"*":       0x7f,
+		"mon-fri": 0x3e,
+		"sat,sun": 0x41,
+		"7":       0x01,
+		"0-7":     0x7f,
+	}
+	for expr, expected := range tcs {
+		days, err := ParseWeekdays(expr)
+		assert.NoError(t, err, "expr %q", expr)
+		assert.Equal(t, expected, days, "expr %q", expr)
+	}
+	_, err := ParseWeekdays("fri-mon")
+	assert.Error(t, err)
+}
@@ -0,0 +1,114 @@
+// Package ratelimit implements token bucket rate limiting of byte streams.
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket whose rate may change over time.
+// It holds at most one second worth of tokens, i.e. it allows bursts of up to one second.
+// A Bucket may be shared by multiple Readers, which then share its rate.
+type Bucket struct {
+	rate func(now time.Time) int64 // bytes per second, <= 0 means unlimited
+
+	mtx    sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket returns a Bucket that allows rate(now) bytes per second at time now, <= 0 means unlimited.
+func NewBucket(rate func(now time.Time) int64) *Bucket {
+	return &Bucket{rate: rate}
+}
+
+// Constant returns a Bucket that allows bytesPerSecond, <= 0 means unlimited.
+func Constant(bytesPerSecond int64) *Bucket {
+	return NewBucket(func(time.Time) int64 { return bytesPerSecond })
+}
+
+// Rate returns the rate in bytes per second at now, <= 0 means unlimited.
+func (b *Bucket) Rate(now time.Time) int64 { return b.rate(now) }
+
+// take takes n tokens from the bucket and returns how long the caller must wait until they are available.
+func (b *Bucket) take(n int, now time.Time) time.Duration {
+	rate := b.rate(now)
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if rate <= 0 {
+		b.tokens, b.last = 0, time.Time{}
+		return 0
+	}
+	if b.last.IsZero() {
+		b.tokens = float64(rate)
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * float64(rate)
+	}
+	if b.tokens > float64(rate) {
+		b.tokens = float64(rate)
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / float64(rate) * float64(time.Second))
+}
+
+// Reader limits the throughput of an io.ReadCloser to the rates of all its buckets.
+//
+// Each Read reads at most one second worth of data at the lowest rate and then waits for the buckets,
+// so that a throttled stream still makes progress in regular intervals (e.g. for watchdogs that observe it).
+type Reader struct {
+	ctx     context.Context
+	r       io.ReadCloser
+	buckets []*Bucket
+}
+
+var _ io.ReadCloser = (*Reader)(nil)
+
+// NewReader returns a Reader that limits r to the rates of buckets, nil buckets are ignored.
+// A Read that waits for the buckets returns when ctx is done.
+func NewReader(ctx context.Context, r io.ReadCloser, buckets ...*Bucket) *Reader {
+	bs := make([]*Bucket, 0, len(buckets))
+	for _, b := range buckets {
+		if b != nil {
+			bs = append(bs, b)
+		}
+	}
+	return &Reader{ctx: ctx, r: r, buckets: bs}
+}
+
+func (r *Reader) Read(p []byte) (n int, err error) {
+	now := time.Now()
+	max := len(p)
+	for _, b := range r.buckets {
+		if rate := b.Rate(now); rate > 0 && int64(max) > rate {
+			max = int(rate)
+		}
+	}
+	n, err = r.r.Read(p[:max])
+	if n == 0 {
+		return n, err
+	}
+	var wait time.Duration
+	for _, b := range r.buckets {
+		if d := b.take(n, now); d > wait {
+			wait = d
+		}
+	}
+	if wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-r.ctx.Done():
+			return n, r.ctx.Err()
+		}
+	}
+	return n, err
+}
+
+func (r *Reader) Close() error { return r.r.Close() }
@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket(t *testing.T) {
+	b := Constant(100)
+	now := time.Unix(0, 0)
+	// a full second of burst
+	assert.Equal(t, time.Duration(0), b.take(100, now))
+	// then the caller has to wait for the deficit
+	assert.Equal(t, 500*time.Millisecond, b.take(50, now))
+	// refilled after the deficit is paid off
+	assert.Equal(t, time.Duration(0), b.take(10, now.Add(600*time.Millisecond)))
+	// burst is capped at one second
+	assert.Equal(t, 1*time.Second, b.take(200, now.Add(time.Hour)))
+
+	unlimited := Constant(0)
+	assert.Equal(t, time.Duration(0), unlimited.take(1<<30, now))
+}
+
+func TestReader(t *testing.T) {
+	data := bytes.Repeat([]byte{'x'}, 300)
+	r := NewReader(context.Background(), ioutil.NopCloser(bytes.NewReader(data)), nil, Constant(1000), Constant(200))
+
+	// reads are bounded by the lowest rate
+	p := make([]byte, len(data))
+	n, err := r.Read(p)
+	require.NoError(t, err)
+	assert.Equal(t, 200, n)
+
+	begin := time.Now()
+	rest, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Len(t, rest, 100)
+	assert.True(t, time.Since(begin) >= 400*time.Millisecond, "took %s", time.Since(begin))
+}
+
+func TestReaderContext(t *testing.T) {
+	data := bytes.Repeat([]byte{'x'}, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	r := NewReader(ctx, ioutil.NopCloser(bytes.NewReader(data)), Constant(10))
+	_, err := r.Read(make([]byte, 10)) // uses the burst
+	require.NoError(t, err)
+	cancel()
+	_, err = r.Read(make([]byte, 10))
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestReaderSharedBucket(t *testing.T) {
+	// concurrent streams of a job share its bucket instead of each getting the full rate
+	b := Constant(1000)
+	data := bytes.Repeat([]byte{'x'}, 1000)
+
+	begin := time.Now()
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			r := NewReader(context.Background(), ioutil.NopCloser(bytes.NewReader(data)), b)
+			_, err := ioutil.ReadAll(r)
+			done <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		require.NoError(t, <-done)
+	}
+	// the first second of data is the burst
+	assert.True(t, time.Since(begin) >= 900*time.Millisecond, "took %s", time.Since(begin))
+}
+
+func TestReaderRateChange(t *testing.T) {
+	// a running stream follows the bucket's rate, e.g. when a time-of-day profile becomes active
+	var rate int64 = 0
+	b := NewBucket(func(time.Time) int64 { return rate })
+	data := bytes.Repeat([]byte{'x'}, 300)
+	r := NewReader(context.Background(), ioutil.NopCloser(bytes.NewReader(data)), b)
+
+	n, err := r.Read(make([]byte, 200))
+	require.NoError(t, err)
+	assert.Equal(t, 200, n)
+
+	rate = 50
+	begin := time.Now()
+	rest, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Len(t, rest, 100)
+	assert.True(t, time.Since(begin) >= 900*time.Millisecond, "took %s", time.Since(begin))
+}
@@ -0,0 +1,146 @@
+// Package window implements weekly recurring time windows, e.g. the times at which replication is allowed.
+package window
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zrepl/zrepl/util/cron"
+)
+
+// Window is the time range start-end on the days of the week it starts on.
+// If end is not after start, the window ends on the next day, start == end is a 24h window.
+type Window struct {
+	days       uint8 // bit i set if the window starts on time.Weekday(i)
+	start, end int   // minutes since midnight
+}
+
+// Parse parses a window. days is a cron(5)-style day-of-week field (e.g. mon-fri or sat,sun),
+// the empty string means every day. start and end are of the form HH:MM, end may be 24:00.
+func Parse(days, start, end string) (*Window, error) {
+	w := &Window{days: 0x7f}
+	var err error
+	if days != "" {
+		if w.days, err = cron.ParseWeekdays(days); err != nil {
+			return nil, err
+		}
+	}
+	if w.start, err = parseTimeOfDay(start, false); err != nil {
+		return nil, fmt.Errorf("invalid start: %s", err)
+	}
+	if w.end, err = parseTimeOfDay(end, true); err != nil {
+		return nil, fmt.Errorf("invalid end: %s", err)
+	}
+	return w, nil
+}
+
+func parseTimeOfDay(s string, allow24 bool) (int, error) {
+	comps := strings.Split(s, ":")
+	if len(comps) != 2 || len(comps[1]) != 2 {
+		return 0, fmt.Errorf("%q is not of the form HH:MM", s)
+	}
+	h, err := strconv.Atoi(comps[0])
+	if err != nil {
+		return 0, fmt.Errorf("%q is not of the form HH:MM", s)
+	}
+	m, err := strconv.Atoi(comps[1])
+	if err != nil {
+		return 0, fmt.Errorf("%q is not of the form HH:MM", s)
+	}
+	if h == 24 && m == 0 && allow24 {
+		return 24 * 60, nil
+	}
+	if h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("%q is not a valid time of day", s)
+	}
+	return h*60 + m, nil
+}
+
+// occurrence returns the occurrence of w that starts on the day of midnight, ok is false if w does not start on that day.
+func (w *Window) occurrence(midnight time.Time) (from, to time.Time, ok bool) {
+	if w.days&(1<<uint(midnight.Weekday())) == 0 {
+		return from, to, false
+	}
+	y, mo, d := midnight.Date()
+	loc := midnight.Location()
+	from = time.Date(y, mo, d, w.start/60, w.start%60, 0, 0, loc)
+	endDay := d
+	if w.end <= w.start {
+		endDay++
+	}
+	to = time.Date(y, mo, endDay, w.end/60, w.end%60, 0, 0, loc)
+	return from, to, true
+}
+
+// Windows is the union of multiple windows, evaluated in a fixed location.
+type Windows struct {
+	ws  []*Window
+	loc *time.Location
+}
+
+func New(ws []*Window, loc *time.Location) *Windows {
+	return &Windows{ws: ws, loc: loc}
+}
+
+// occurrences calls f for each occurrence of ws that starts on the day before t up to a week after t, in loc.
+func (ws *Windows) occurrences(t time.Time, f func(from, to time.Time)) {
+	t = t.In(ws.loc)
+	y, m, d := t.Date()
+	for i := -1; i <= 7; i++ {
+		midnight := time.Date(y, m, d+i, 0, 0, 0, 0, ws.loc)
+		for _, w := range ws.ws {
+			if from, to, ok := w.occurrence(midnight); ok {
+				f(from, to)
+			}
+		}
+	}
+}
+
+// Contains reports whether t is within any of the windows.
+func (ws *Windows) Contains(t time.Time) (contains bool) {
+	ws.occurrences(t, func(from, to time.Time) {
+		if !t.Before(from) && t.Before(to) {
+			contains = true
+		}
+	})
+	return contains
+}
+
+// NextOpen returns t if t is within a window, otherwise the start of the next window after t.
+// Returns the zero time if no window ever opens.
+func (ws *Windows) NextOpen(t time.Time) (next time.Time) {
+	if ws.Contains(t) {
+		return t
+	}
+	ws.occurrences(t, func(from, to time.Time) {
+		if from.After(t) && (next.IsZero() || from.Before(next)) {
+			next = from
+		}
+	})
+	return next
+}
+
+// maximum number of adjacent or overlapping windows that NextClose follows, more than a week of windows
+const maxAdjacent = 16
+
+// NextClose returns the earliest time at or after t that is not within any window, i.e. t if t is outside all windows.
+// Adjacent and overlapping windows are treated as one.
+// Returns the zero time if the windows never close.
+func (ws *Windows) NextClose(t time.Time) time.Time {
+	c := t
+	for i := 0; i < maxAdjacent; i++ {
+		var end time.Time
+		ws.occurrences(c, func(from, to time.Time) {
+			if !c.Before(from) && c.Before(to) && to.After(end) {
+				end = to
+			}
+		})
+		if end.IsZero() {
+			return c
+		}
+		c = end
+	}
+	return time.Time{}
+}
@@ -0,0 +1,84 @@
+package window
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	invalid := []struct{ days, start, end string }{
+		{"", "", "06:00"},
+		{"", "22:00", ""},
+		{"", "24:00", "06:00"},
+		{"", "22:60", "06:00"},
+		{"", "22", "06:00"},
+		{"", "22:0", "06:00"},
+		{"", "ab:cd", "06:00"},
+		{"foo", "22:00", "06:00"},
+		{"fri-mon", "22:00", "06:00"},
+	}
+	for _, tc := range invalid {
+		_, err := Parse(tc.days, tc.start, tc.end)
+		assert.Error(t, err, "%#v", tc)
+	}
+	_, err := Parse("", "00:00", "24:00")
+	assert.NoError(t, err)
+}
+
+func TestWindows(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	night, err := Parse("mon-fri", "22:00", "06:00")
+	require.NoError(t, err)
+	weekend, err := Parse("sat,sun", "00:00", "24:00")
+	require.NoError(t, err)
+	ws := New([]*Window{night, weekend}, berlin)
+
+	date := func(m time.Month, d, h, min int) time.Time {
+		return time.Date(2018, m, d, h, min, 0, 0, berlin)
+	}
+
+	// 2018-10-01 is a Monday
+	tcs := []struct {
+		at                  time.Time
+		contains            bool
+		nextOpen, nextClose time.Time
+	}{
+		{date(10, 1, 12, 0), false, date(10, 1, 22, 0), date(10, 1, 12, 0)},
+		{date(10, 1, 22, 0), true, date(10, 1, 22, 0), date(10, 2, 6, 0)},
+		{date(10, 2, 5, 59), true, date(10, 2, 5, 59), date(10, 2, 6, 0)},
+		{date(10, 2, 6, 0), false, date(10, 2, 22, 0), date(10, 2, 6, 0)},
+		// Friday night is followed by the weekend
+		{date(10, 5, 23, 0), true, date(10, 5, 23, 0), date(10, 8, 0, 0)},
+		// Sunday night does not start a night window
+		{date(10, 7, 23, 0), true, date(10, 7, 23, 0), date(10, 8, 0, 0)},
+		{date(10, 8, 0, 0), false, date(10, 8, 22, 0), date(10, 8, 0, 0)},
+		// DST has ended on 2018-10-28
+		{date(10, 29, 23, 0), true, date(10, 29, 23, 0), date(10, 30, 6, 0)},
+	}
+	for _, tc := range tcs {
+		assert.Equal(t, tc.contains, ws.Contains(tc.at), "at %s", tc.at)
+		assert.True(t, tc.nextOpen.Equal(ws.NextOpen(tc.at)), "at %s: NextOpen %s", tc.at, ws.NextOpen(tc.at))
+		assert.True(t, tc.nextClose.Equal(ws.NextClose(tc.at)), "at %s: NextClose %s", tc.at, ws.NextClose(tc.at))
+	}
+
+	// times in other locations are evaluated in the windows' location
+	assert.True(t, ws.Contains(time.Date(2018, 10, 1, 20, 30, 0, 0, time.UTC)))
+}
+
+func TestWindowsNeverOrAlwaysOpen(t *testing.T) {
+	now := time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	never := New(nil, time.UTC)
+	assert.False(t, never.Contains(now))
+	assert.True(t, never.NextOpen(now).IsZero())
+
+	allDay, err := Parse("", "00:00", "00:00")
+	require.NoError(t, err)
+	always := New([]*Window{allDay}, time.UTC)
+	assert.True(t, always.Contains(now))
+	assert.True(t, always.NextClose(now).IsZero())
+}
@@ -0,0 +1,120 @@
+package zfs
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ReplicationHoldTagPrefix is the prefix of the tags of the holds
+// that zrepl places on the most recently replicated snapshot of a filesystem.
+const ReplicationHoldTagPrefix = "zrepl_last_replicated_"
+
+// ReplicationHoldTag returns the tag of the hold that job places on replicated snapshots.
+func ReplicationHoldTag(job string) string {
+	return ReplicationHoldTagPrefix + job
+}
+
+// IsReplicationHoldTag reports whether tag was created by ReplicationHoldTag.
+func IsReplicationHoldTag(tag string) bool {
+	return strings.HasPrefix(tag, ReplicationHoldTagPrefix)
+}
+
+type Hold struct {
+	Snapshot string // fs@snap
+	Tag      string
+	Since    string // creation time of the hold as printed by zfs holds
+}
+
+func runZFS(args ...string) (stdout []byte, err error) {
+	cmd := exec.Command(ZFS_BINARY, args...)
+	stderr := bytes.NewBuffer(make([]byte, 0, 1024))
+	cmd.Stderr = stderr
+	stdout, err = cmd.Output()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			err = ZFSError{
+				Stderr:  stderr.Bytes(),
+				WaitErr: err,
+			}
+		}
+	}
+	return stdout, err
+}
+
+// ZFSHold places a hold with tag on fs@snapshot.
+// Holding a snapshot that already has a hold with tag is not an error.
+func ZFSHold(fs *DatasetPath, snapshot, tag string) error {
+	_, err := runZFS("hold", tag, zfsBuildSnapName(fs, snapshot))
+	if zfsErr, ok := err.(ZFSError); ok && bytes.Contains(zfsErr.Stderr, []byte("tag already exists")) {
+		return nil
+	}
+	return err
+}
+
+// ZFSRelease releases the hold with tag from snapshots (fs@snap).
+func ZFSRelease(tag string, snapshots ...string) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	_, err := runZFS(append([]string{"release", tag}, snapshots...)...)
+	return err
+}
+
+// ZFSHolds returns the holds on snapshots (fs@snap).
+func ZFSHolds(snapshots ...string) ([]Hold, error) {
+	if len(snapshots) == 0 {
+		return nil, nil
+	}
+	out, err := runZFS(append([]string{"holds", "-H"}, snapshots...)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseHolds(out)
+}
+
+func parseHolds(out []byte) ([]Hold, error) {
+	var holds []Hold
+	for _, line := range strings.Split(string(out), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected output of zfs holds: %q", line)
+		}
+		holds = append(holds, Hold{Snapshot: fields[0], Tag: fields[1], Since: fields[2]})
+	}
+	return holds, nil
+}
+
+// ZFSListHeldSnapshots returns the snapshots (fs@snap) that have at least one hold.
+// If root is not empty, only the snapshots of root (and of its descendants if recursive) are returned.
+func ZFSListHeldSnapshots(root string, recursive bool) ([]string, error) {
+	args := []string{"-t", "snapshot"}
+	if root != "" {
+		if recursive {
+			args = append(args, "-r")
+		} else {
+			args = append(args, "-d", "1")
+		}
+		args = append(args, root)
+	}
+	rows, err := ZFSList([]string{"name", "userrefs"}, args...)
+	if err != nil {
+		return nil, err
+	}
+	var held []string
+	for _, row := range rows {
+		refs, err := strconv.ParseUint(row[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse userrefs of %s: %s", row[0], err)
+		}
+		if refs > 0 {
+			held = append(held, row[0])
+		}
+	}
+	return held, nil
+}
@@ -0,0 +1,271 @@
+package zfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Decoder for packed nvlists (see nvpair.c in the ZFS source tree).
+//
+// Only the value types that occur in the nvlists zrepl needs to decode are supported,
+// pairs of other types are skipped.
+
+const (
+	nvEncodeNative = 0
+	nvEncodeXDR    = 1
+
+	nvHostEndianBig    = 0
+	nvHostEndianLittle = 1
+)
+
+type nvDataType int32
+
+const (
+	nvTypeBoolean      nvDataType = 1
+	nvTypeInt32        nvDataType = 5
+	nvTypeUint32       nvDataType = 6
+	nvTypeInt64        nvDataType = 7
+	nvTypeUint64       nvDataType = 8
+	nvTypeString       nvDataType = 9
+	nvTypeBooleanValue nvDataType = 21
+)
+
+var errNVListTruncated = errors.New("nvlist truncated")
+
+// nvlistUnpack decodes a packed nvlist into a map from pair name to value.
+// Values are of type bool, int32, uint32, int64, uint64 or string.
+// Pairs of DATA_TYPE_BOOLEAN, which carry no value, are represented as true.
+func nvlistUnpack(packed []byte) (map[string]interface{}, error) {
+	if len(packed) < 4 {
+		return nil, errNVListTruncated
+	}
+	encoding, endian := packed[0], packed[1]
+
+	var d nvDecoder
+	switch endian {
+	case nvHostEndianBig:
+		d.order = binary.BigEndian
+	case nvHostEndianLittle:
+		d.order = binary.LittleEndian
+	default:
+		return nil, fmt.Errorf("unknown nvlist endianness %d", endian)
+	}
+	d.buf = packed[4:]
+
+	switch encoding {
+	case nvEncodeNative:
+		return d.native()
+	case nvEncodeXDR:
+		d.order = binary.BigEndian // XDR is always big endian
+		return d.xdr()
+	default:
+		return nil, fmt.Errorf("unknown nvlist encoding %d", encoding)
+	}
+}
+
+type nvDecoder struct {
+	buf   []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+func (d *nvDecoder) bytes(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.buf) {
+		return nil, errNVListTruncated
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *nvDecoder) uint16() (uint16, error) {
+	b, err := d.bytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return d.order.Uint16(b), nil
+}
+
+func (d *nvDecoder) uint32() (uint32, error) {
+	b, err := d.bytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return d.order.Uint32(b), nil
+}
+
+func (d *nvDecoder) uint64() (uint64, error) {
+	b, err := d.bytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return d.order.Uint64(b), nil
+}
+
+// nvlist header: int32 nvl_version, uint32 nvl_nvflag
+func (d *nvDecoder) header() error {
+	_, err := d.bytes(8)
+	return err
+}
+
+// native encoding, pairs look like
+//
+//	int32 nvp_size, int16 nvp_name_sz, int16 nvp_reserve, int32 nvp_value_elem, int32 nvp_type
+//	name (nvp_name_sz bytes including the terminating NUL), padded to 8 bytes
+//	value
+//
+// The list is terminated by nvp_size == 0.
+func (d *nvDecoder) native() (map[string]interface{}, error) {
+	if err := d.header(); err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{})
+	for {
+		start := d.pos
+		size, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return m, nil
+		}
+		if int(size) > len(d.buf)-start {
+			return nil, errNVListTruncated
+		}
+		nameSz, err := d.uint16()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := d.uint16(); err != nil { // nvp_reserve
+			return nil, err
+		}
+		if _, err := d.uint32(); err != nil { // nvp_value_elem
+			return nil, err
+		}
+		typ, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		name, err := d.bytes(int(nameSz))
+		if err != nil {
+			return nil, err
+		}
+		name = bytes.TrimRight(name, "\x00")
+		d.pos = start + nvAlign(d.pos-start, 8)
+
+		end := start + int(size)
+		if d.pos > end {
+			return nil, errNVListTruncated
+		}
+		v, err := d.value(nvDataType(typ), func() (string, error) {
+			s := d.buf[d.pos:end]
+			if i := bytes.IndexByte(s, 0); i >= 0 {
+				s = s[:i]
+			}
+			return string(s), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			m[string(name)] = v
+		}
+		d.pos = end
+	}
+}
+
+// XDR encoding, pairs look like
+//
+//	int32 encode_size, int32 decode_size
+//	string name, int32 type, int32 nelem
+//	value
+//
+// where strings are encoded as uint32 length + bytes padded to 4 bytes.
+// The list is terminated by encode_size == decode_size == 0.
+func (d *nvDecoder) xdr() (map[string]interface{}, error) {
+	if err := d.header(); err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{})
+	for {
+		start := d.pos
+		encSize, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		decSize, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		if encSize == 0 && decSize == 0 {
+			return m, nil
+		}
+		if int(encSize) > len(d.buf)-start {
+			return nil, errNVListTruncated
+		}
+		name, err := d.xdrString()
+		if err != nil {
+			return nil, err
+		}
+		typ, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := d.uint32(); err != nil { // nelem
+			return nil, err
+		}
+
+		v, err := d.value(nvDataType(typ), d.xdrString)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			m[name] = v
+		}
+		d.pos = start + int(encSize)
+	}
+}
+
+// value decodes the value of a pair of type typ, using str to decode strings.
+// Returns nil for unsupported types.
+func (d *nvDecoder) value(typ nvDataType, str func() (string, error)) (interface{}, error) {
+	switch typ {
+	case nvTypeBoolean:
+		return true, nil
+	case nvTypeBooleanValue:
+		b, err := d.uint32()
+		return b != 0, err
+	case nvTypeInt32:
+		i, err := d.uint32()
+		return int32(i), err
+	case nvTypeUint32:
+		return d.uint32()
+	case nvTypeInt64:
+		i, err := d.uint64()
+		return int64(i), err
+	case nvTypeUint64:
+		return d.uint64()
+	case nvTypeString:
+		return str()
+	default:
+		return nil, nil
+	}
+}
+
+func (d *nvDecoder) xdrString() (string, error) {
+	l, err := d.uint32()
+	if err != nil {
+		return "", err
+	}
+	b, err := d.bytes(nvAlign(int(l), 4))
+	if err != nil {
+		return "", err
+	}
+	return string(b[:l]), nil
+}
+
+func nvAlign(n, to int) int {
+	return (n + to - 1) &^ (to - 1)
+}
@@ -1,9 +1,11 @@
 package zfs
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/pkg/errors"
 	"strconv"
+	"strings"
 )
 
 const ReplicationCursorBookmarkName = "zrepl_replication_cursor"
@@ -56,3 +58,32 @@ func ZFSSetReplicationCursor(fs *DatasetPath, snapname string) (guid uint64, err
 	}
 	return snapGuid, nil
 }
+
+// ReplicationBookmarkInfix separates snapshot and job name in the names of the bookmarks
+// that zrepl creates for replicated snapshots, see ReplicationBookmarkName.
+const ReplicationBookmarkInfix = "_zrepl_"
+
+// ReplicationBookmarkName returns the name of the bookmark that job creates for the replicated snapshot.
+func ReplicationBookmarkName(snapshot, job string) string {
+	return snapshot + ReplicationBookmarkInfix + job
+}
+
+// ParseReplicationBookmarkName is the inverse of ReplicationBookmarkName.
+// ok is false if bookmark was not created by ReplicationBookmarkName.
+func ParseReplicationBookmarkName(bookmark string) (snapshot, job string, ok bool) {
+	i := strings.LastIndex(bookmark, ReplicationBookmarkInfix)
+	if i <= 0 || i+len(ReplicationBookmarkInfix) == len(bookmark) {
+		return "", "", false
+	}
+	return bookmark[:i], bookmark[i+len(ReplicationBookmarkInfix):], true
+}
+
+// ZFSBookmarkReplicated creates the bookmark ReplicationBookmarkName(snapname, job) of fs@snapname.
+// It is not an error if the bookmark already exists.
+func ZFSBookmarkReplicated(fs *DatasetPath, snapname, job string) error {
+	err := ZFSBookmark(fs, snapname, ReplicationBookmarkName(snapname, job))
+	if zfsErr, ok := err.(ZFSError); ok && bytes.Contains(zfsErr.Stderr, []byte("bookmark exists")) {
+		return nil
+	}
+	return err
+}
@@ -1,110 +1,165 @@
 package zfs
 
 import (
+	"bytes"
+	"compress/zlib"
 	"context"
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
-	"os/exec"
-	"regexp"
+	"io"
+	"io/ioutil"
 	"strconv"
-	"time"
+	"strings"
 )
 
+// ResumeToken is the decoded form of the receive_resume_token property of a filesystem.
 type ResumeToken struct {
 	HasFromGUID, HasToGUID bool
 	FromGUID, ToGUID       uint64
-	// no support for other fields
-}
+	ToName                 string
+
+	// Position in the send stream at which the send is resumed
+	Object, Offset, Bytes uint64
 
-var resumeTokenNVListRE = regexp.MustCompile(`\t(\S+) = (.*)`)
-var resumeTokenContentsRE = regexp.MustCompile(`resume token contents:\nnvlist version: 0`)
-var resumeTokenIsCorruptRE = regexp.MustCompile(`resume token is corrupt`)
+	// Stream features that were requested by the interrupted send
+	EmbedOK, CompressOK, RawOK, LargeBlockOK bool
+}
 
 var ResumeTokenCorruptError = errors.New("resume token is corrupt")
-var ResumeTokenDecodingNotSupported = errors.New("zfs binary does not allow decoding resume token or zrepl cannot scrape zfs output")
+var ResumeTokenDecodingNotSupported = errors.New("resume token version or encoding is not supported by zrepl")
 var ResumeTokenParsingError = errors.New("zrepl cannot parse resume token values")
 
-// Abuse 'zfs send' to decode the resume token
+// ZFS_SEND_RESUME_TOKEN_VERSION in the ZFS source tree
+const resumeTokenVersion = 1
+
+// maxResumeTokenPackedLen limits the uncompressed length of the nvlist in a resume token.
+// Tokens may be supplied by a remote peer, real ones are a few hundred bytes.
+const maxResumeTokenPackedLen = 256 << 10
+
+// ParseResumeToken decodes a resume token as produced by libzfs (see zfs_send_resume_token_to_nvlist).
+//
+// Example resume tokens:
+//
+// From a non-incremental send
+//
+//	1-bf31b879a-b8-789c636064000310a500c4ec50360710e72765a5269740f80cd8e4d3d28a534b18e00024cf86249f5459925acc802a8facbf243fbd3433858161f5ddb9ab1ae7c7466a20c97382e5f312735319180af2f3730cf58166953824c2cc0200cde81651
+//
+// From an incremental send
 //
-// FIXME: implement nvlist unpacking in Go and read through libzfs_sendrecv.c
+//	1-c49b979a2-e0-789c636064000310a501c49c50360710a715e5e7a69766a63040c1eabb735735ce8f8d5400b2d991d4e52765a5269740f82080219f96569c5ac2000720793624f9a4ca92d46206547964fd25f91057f09e37babb88c9bf5503499e132c9f97989bcac050909f9f63a80f34abc421096616007c881d4c
+//
+// The format is VERSION-CHECKSUM-LENGTH-PAYLOAD, where PAYLOAD is the hex-encoded
+// zlib-compressed packed nvlist, LENGTH its uncompressed length and CHECKSUM
+// the first word of the fletcher4 checksum of the compressed nvlist.
+//
+// The decoded nvlist of the incremental token above, as printed by zfs send -nvt <token>:
+//
+//	resume token contents:
+//	nvlist version: 0
+//		fromguid = 0x595d9f81aa9dddab
+//		object = 0x1
+//		offset = 0x0
+//		bytes = 0x0
+//		toguid = 0x854f02a2dd32cf0d
+//		toname = pool1/test@b
 func ParseResumeToken(ctx context.Context, token string) (*ResumeToken, error) {
 
-	// Example resume tokens:
-	//
-	// From a non-incremental send
-	// 1-bf31b879a-b8-789c636064000310a500c4ec50360710e72765a5269740f80cd8e4d3d28a534b18e00024cf86249f5459925acc802a8facbf243fbd3433858161f5ddb9ab1ae7c7466a20c97382e5f312735319180af2f3730cf58166953824c2cc0200cde81651
-
-	// From an incremental send
-	// 1-c49b979a2-e0-789c636064000310a501c49c50360710a715e5e7a69766a63040c1eabb735735ce8f8d5400b2d991d4e52765a5269740f82080219f96569c5ac2000720793624f9a4ca92d46206547964fd25f91057f09e37babb88c9bf5503499e132c9f97989bcac050909f9f63a80f34abc421096616007c881d4c
-
-	// Resulting output of zfs send -nvt <token>
-	//
-	//resume token contents:
-	//nvlist version: 0
-	//	fromguid = 0x595d9f81aa9dddab
-	//	object = 0x1
-	//	offset = 0x0
-	//	bytes = 0x0
-	//	toguid = 0x854f02a2dd32cf0d
-	//	toname = pool1/test@b
-	//cannot resume send: 'pool1/test@b' used in the initial send no longer exists
-
-	ctx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
-	defer cancel()
-	cmd := exec.CommandContext(ctx, ZFS_BINARY, "send", "-nvt", string(token))
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			if !exitErr.Exited() {
-				return nil, err
-			}
-			// we abuse zfs send for decoding, the exit error may be due to
-			// a) the token being from a third machine
-			// b) it no longer exists on the machine where
-		} else {
-			return nil, err
-		}
+	comps := strings.SplitN(token, "-", 4)
+	if len(comps) != 4 {
+		return nil, ResumeTokenCorruptError
 	}
 
-	if !resumeTokenContentsRE.Match(output) {
-		if resumeTokenIsCorruptRE.Match(output) {
-			return nil, ResumeTokenCorruptError
-		}
+	version, err := strconv.ParseUint(comps[0], 10, 32)
+	if err != nil {
+		return nil, ResumeTokenCorruptError
+	}
+	if version != resumeTokenVersion {
 		return nil, ResumeTokenDecodingNotSupported
 	}
+	checksum, err := strconv.ParseUint(comps[1], 16, 64)
+	if err != nil {
+		return nil, ResumeTokenCorruptError
+	}
+	packedLen, err := strconv.ParseUint(comps[2], 16, 32)
+	if err != nil || packedLen > maxResumeTokenPackedLen {
+		return nil, ResumeTokenCorruptError
+	}
+	compressed, err := hex.DecodeString(comps[3])
+	if err != nil {
+		return nil, ResumeTokenCorruptError
+	}
 
-	matches := resumeTokenNVListRE.FindAllStringSubmatch(string(output), -1)
-	if matches == nil {
-		return nil, ResumeTokenDecodingNotSupported
+	if fletcher4FirstWord(compressed) != checksum {
+		return nil, ResumeTokenCorruptError
 	}
 
-	rt := &ResumeToken{}
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, ResumeTokenCorruptError
+	}
+	packed, err := ioutil.ReadAll(io.LimitReader(zr, int64(packedLen)))
+	if err != nil || uint64(len(packed)) != packedLen {
+		return nil, ResumeTokenCorruptError
+	}
 
-	for _, m := range matches {
-		attr, val := m[1], m[2]
-		switch attr {
+	nvl, err := nvlistUnpack(packed)
+	if err != nil {
+		return nil, ResumeTokenParsingError
+	}
+
+	rt := &ResumeToken{}
+	for name, val := range nvl {
+		var ok bool
+		switch name {
 		case "fromguid":
-			rt.FromGUID, err = strconv.ParseUint(val, 0, 64)
-			if err != nil {
-				return nil, ResumeTokenParsingError
-			}
-			rt.HasFromGUID = true
+			rt.FromGUID, ok = val.(uint64)
+			rt.HasFromGUID = ok
 		case "toguid":
-			rt.ToGUID, err = strconv.ParseUint(val, 0, 64)
-			if err != nil {
-				return nil, ResumeTokenParsingError
-			}
-			rt.HasToGUID = true
+			rt.ToGUID, ok = val.(uint64)
+			rt.HasToGUID = ok
+		case "toname":
+			rt.ToName, ok = val.(string)
+		case "object":
+			rt.Object, ok = val.(uint64)
+		case "offset":
+			rt.Offset, ok = val.(uint64)
+		case "bytes":
+			rt.Bytes, ok = val.(uint64)
+		case "embedok":
+			rt.EmbedOK, ok = val.(bool)
+		case "compressok":
+			rt.CompressOK, ok = val.(bool)
+		case "rawok":
+			rt.RawOK, ok = val.(bool)
+		case "largeblockok":
+			rt.LargeBlockOK, ok = val.(bool)
+		default:
+			ok = true // ignore fields unknown to zrepl
+		}
+		if !ok {
+			return nil, ResumeTokenParsingError
 		}
 	}
 
 	if !rt.HasToGUID {
-		return nil, ResumeTokenDecodingNotSupported
+		return nil, ResumeTokenParsingError
 	}
 
 	return rt, nil
 
 }
 
+// fletcher4FirstWord computes the first word of the fletcher4 checksum of b.
+// Like libzfs when it creates the token, trailing bytes that do not fill a 32bit word are ignored.
+func fletcher4FirstWord(b []byte) uint64 {
+	var a uint64
+	for i := 0; i+4 <= len(b); i += 4 {
+		a += uint64(binary.LittleEndian.Uint32(b[i : i+4]))
+	}
+	return a
+}
+
 func ZFSGetReceiveResumeToken(fs *DatasetPath) (string, error) {
 	const prop_receive_resume_token = "receive_resume_token"
 	props, err := ZFSGet(fs, []string{prop_receive_resume_token})
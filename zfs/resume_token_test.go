@@ -31,8 +31,6 @@ func (rtt *ResumeTokenTest) Test(t *testing.T) {
 
 func TestParseResumeToken(t *testing.T) {
 
-	t.SkipNow() // FIXME not compatible with docker
-
 	tbl := []ResumeTokenTest{
 		{
 			Msg:   "normal send (non-incremental)",
@@ -40,6 +38,8 @@ func TestParseResumeToken(t *testing.T) {
 			ExpectToken: &zfs.ResumeToken{
 				HasToGUID: true,
 				ToGUID:    0x595d9f81aa9dddab,
+				ToName:    "pool1/test@a",
+				Object:    1,
 			},
 		},
 		{
@@ -50,6 +50,44 @@ func TestParseResumeToken(t *testing.T) {
 				ToGUID:      0x854f02a2dd32cf0d,
 				HasFromGUID: true,
 				FromGUID:    0x595d9f81aa9dddab,
+				ToName:      "pool1/test@b",
+				Object:      1,
+			},
+		},
+		{
+			Msg:   "incremental send with stream features and progress",
+			Token: `1-1663a05316-150-789c636064000310a501c49c50360710a715e5e7a69766a63040c1eabb735735ce8f8d5400b2d991d4e52765a5269730303442d561c8a7a515a796806464e0f26c48f2499525a9c5407a42b12c56fd25f91057f09e37babb88c9bf5503499e132c9f97989bcac050909f9f63a80f34abc42109648e04543fcc7fa9b949a929f9d960f3b991c493f3730b8a528b8b815270fb7991e473128bd2539372f293b3212a24a0ee87c9172596c3b432000009992dd3`,
+			ExpectToken: &zfs.ResumeToken{
+				HasToGUID:    true,
+				ToGUID:       0x854f02a2dd32cf0d,
+				HasFromGUID:  true,
+				FromGUID:     0x595d9f81aa9dddab,
+				ToName:       "pool1/test@b",
+				Object:       0x81,
+				Offset:       0x1c0000,
+				Bytes:        0x1d7390,
+				EmbedOK:      true,
+				CompressOK:   true,
+				RawOK:        true,
+				LargeBlockOK: true,
+			},
+		},
+		{
+			Msg:   "same as above, XDR encoded nvlist",
+			Token: `1-155726bf03-16c-789c6364648001104b058a39d28af273d34b3353406c905c64ecfcc65573efae4652c3969f94959a5c02d6cb01d50f028d286ad2d28a53d1d5c88049981ad6a4ca92d462063435b2c51390cd29c987ba06aaa6d59f69d15da3f3bc40b60e1403d5e425e6a682d57042cde129c8cfcf31d4079a5fe29004b51984d95373935253f2b3191810ee568062aee4fcdc82a2d4e2628834863c4f4e62517a6a524e7e723658054c1e66366b516239542b032274810000b2272f46`,
+			ExpectToken: &zfs.ResumeToken{
+				HasToGUID:    true,
+				ToGUID:       0x854f02a2dd32cf0d,
+				HasFromGUID:  true,
+				FromGUID:     0x595d9f81aa9dddab,
+				ToName:       "pool1/test@b",
+				Object:       0x81,
+				Offset:       0x1c0000,
+				Bytes:        0x1d7390,
+				EmbedOK:      true,
+				CompressOK:   true,
+				RawOK:        true,
+				LargeBlockOK: true,
 			},
 		},
 		{
@@ -57,6 +95,36 @@ func TestParseResumeToken(t *testing.T) {
 			Token:       `1-bf31b879a-b8-789c636064000310a500c4ec50360710e72765a5269740f80cd8e4d3d28a534b18e00024cf86249f5459925acc802a8facbf243fbd3433858161f5ddb9ab1ae7c7466a20c97382e5f312735319180af2f3730cf58166953824c2cc0200cd12345`,
 			ExpectError: zfs.ResumeTokenCorruptError,
 		},
+		{
+			Msg:         "checksum mismatch",
+			Token:       `1-bf31b879b-b8-789c636064000310a500c4ec50360710e72765a5269740f80cd8e4d3d28a534b18e00024cf86249f5459925acc802a8facbf243fbd3433858161f5ddb9ab1ae7c7466a20c97382e5f312735319180af2f3730cf58166953824c2cc0200cde81651`,
+			ExpectError: zfs.ResumeTokenCorruptError,
+		},
+		{
+			Msg:         "length mismatch",
+			Token:       `1-bf31b879a-b9-789c636064000310a500c4ec50360710e72765a5269740f80cd8e4d3d28a534b18e00024cf86249f5459925acc802a8facbf243fbd3433858161f5ddb9ab1ae7c7466a20c97382e5f312735319180af2f3730cf58166953824c2cc0200cde81651`,
+			ExpectError: zfs.ResumeTokenCorruptError,
+		},
+		{
+			Msg:         "oversized length",
+			Token:       `1-bf31b879a-ffffffff-789c636064000310a500c4ec50360710e72765a5269740f80cd8e4d3d28a534b18e00024cf86249f5459925acc802a8facbf243fbd3433858161f5ddb9ab1ae7c7466a20c97382e5f312735319180af2f3730cf58166953824c2cc0200cde81651`,
+			ExpectError: zfs.ResumeTokenCorruptError,
+		},
+		{
+			Msg:         "not a token",
+			Token:       `-`,
+			ExpectError: zfs.ResumeTokenCorruptError,
+		},
+		{
+			Msg:         "unsupported token version",
+			Token:       `2-bf31b879a-b8-789c636064000310a500c4ec50360710e72765a5269740f80cd8e4d3d28a534b18e00024cf86249f5459925acc802a8facbf243fbd3433858161f5ddb9ab1ae7c7466a20c97382e5f312735319180af2f3730cf58166953824c2cc0200cde81651`,
+			ExpectError: zfs.ResumeTokenDecodingNotSupported,
+		},
+		{
+			Msg:         "valid nvlist without toguid",
+			Token:       `1-33bd7930e-30-789c636064000310a500c4ec50360710e72765a5269740f830000031e602ab`,
+			ExpectError: zfs.ResumeTokenParsingError,
+		},
 	}
 
 	for _, test := range tbl {
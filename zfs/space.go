@@ -0,0 +1,48 @@
+package zfs
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Space is the space usage of a filesystem and of its pool in bytes.
+type Space struct {
+	// properties used and available of the filesystem
+	Used, Available uint64
+	// properties used and available of the root filesystem of the pool
+	PoolUsed, PoolAvailable uint64
+	// name of the pool
+	Pool string
+}
+
+// ZFSGetSpace returns the space usage of fs and of its pool.
+func ZFSGetSpace(fs *DatasetPath) (*Space, error) {
+	if fs.Empty() {
+		return nil, fmt.Errorf("empty dataset path")
+	}
+	var s Space
+	var err error
+	if s.Used, s.Available, err = zfsGetUsedAvailable(fs); err != nil {
+		return nil, err
+	}
+	s.Pool = fs.comps[0]
+	pool := &DatasetPath{fs.comps[:1]}
+	if s.PoolUsed, s.PoolAvailable, err = zfsGetUsedAvailable(pool); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func zfsGetUsedAvailable(fs *DatasetPath) (used, available uint64, err error) {
+	props, err := ZFSGet(fs, []string{"used", "available"})
+	if err != nil {
+		return 0, 0, err
+	}
+	if used, err = strconv.ParseUint(props.Get("used"), 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("cannot parse used of %s: %s", fs.ToString(), err)
+	}
+	if available, err = strconv.ParseUint(props.Get("available"), 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("cannot parse available of %s: %s", fs.ToString(), err)
+	}
+	return used, available, nil
+}
@@ -69,6 +69,12 @@ type FilesystemVersion struct {
 
 	// The time the dataset was created
 	Creation time.Time
+
+	// The number of holds on a snapshot, always 0 for bookmarks
+	UserRefs uint64
+
+	// The space used by the snapshot alone, always 0 for bookmarks
+	Used uint64
 }
 
 func (v FilesystemVersion) String() string {
@@ -96,7 +102,7 @@ func ZFSListFilesystemVersions(fs *DatasetPath, filter FilesystemVersionFilter)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go ZFSListChan(ctx, listResults,
-		[]string{"name", "guid", "createtxg", "creation"},
+		[]string{"name", "guid", "createtxg", "creation", "userrefs", "used"},
 		"-r", "-d", "1",
 		"-t", "bookmark,snapshot",
 		"-s", "createtxg", fs.ToString())
@@ -138,6 +144,17 @@ func ZFSListFilesystemVersions(fs *DatasetPath, filter FilesystemVersionFilter)
 			v.Creation = time.Unix(creationUnix, 0)
 		}
 
+		if v.Type == Snapshot {
+			if v.UserRefs, err = strconv.ParseUint(line[4], 10, 64); err != nil {
+				err = fmt.Errorf("cannot parse userrefs '%s': %s", line[4], err)
+				return nil, err
+			}
+			if v.Used, err = strconv.ParseUint(line[5], 10, 64); err != nil {
+				err = fmt.Errorf("cannot parse used '%s': %s", line[5], err)
+				return nil, err
+			}
+		}
+
 		accept := true
 		if filter != nil {
 			accept, err = filter.Filter(v.Type, v.Name)
@@ -15,7 +15,9 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/zrepl/zrepl/util"
 	"regexp"
+	"sort"
 	"strconv"
+	"time"
 )
 
 type DatasetPath struct {
@@ -286,13 +288,45 @@ func absVersion(fs, v string) (full string, err error) {
 	return fmt.Sprintf("%s%s", fs, v), nil
 }
 
-func buildCommonSendArgs(fs string, from, to string, token string) ([]string, error) {
+// ZFSSendFlags are the stream features used by zfs send.
+type ZFSSendFlags struct {
+	Compressed   bool // -c
+	LargeBlocks  bool // -L
+	EmbeddedData bool // -e
+	Raw          bool // -w
+	Properties   bool // -p
+}
+
+func (f ZFSSendFlags) args() []string {
+	args := make([]string, 0, 5)
+	if f.Compressed {
+		args = append(args, "-c")
+	}
+	if f.LargeBlocks {
+		args = append(args, "-L")
+	}
+	if f.EmbeddedData {
+		args = append(args, "-e")
+	}
+	if f.Raw {
+		args = append(args, "-w")
+	}
+	if f.Properties {
+		args = append(args, "-p")
+	}
+	return args
+}
+
+func buildCommonSendArgs(fs string, from, to string, token string, flags ZFSSendFlags) ([]string, error) {
 	args := make([]string, 0, 3)
 	if token != "" {
+		// the stream features are encoded in the token and must not be specified again
 		args = append(args, "-t", token)
 		return args, nil
 	}
 
+	args = append(args, flags.args()...)
+
 	toV, err := absVersion(fs, to)
 	if err != nil {
 		return nil, err
@@ -315,14 +349,14 @@ func buildCommonSendArgs(fs string, from, to string, token string) ([]string, er
 }
 
 // if token != "", then send -t token is used
-// otherwise send [-i from] to is used
+// otherwise send [flags] [-i from] to is used
 // (if from is "" a full ZFS send is done)
-func ZFSSend(ctx context.Context, fs string, from, to string, token string) (stream io.ReadCloser, err error) {
+func ZFSSend(ctx context.Context, fs string, from, to string, token string, flags ZFSSendFlags) (stream io.ReadCloser, err error) {
 
 	args := make([]string, 0)
 	args = append(args, "send")
 
-	sargs, err := buildCommonSendArgs(fs, from, to, token)
+	sargs, err := buildCommonSendArgs(fs, from, to, token, flags)
 	if err != nil {
 		return nil, err
 	}
@@ -408,10 +442,11 @@ func (s *DrySendInfo) unmarshalInfoLine(l string) (regexMatched bool, err error)
 }
 
 // from may be "", in which case a full ZFS send is done
+// flags should be the same as for the actual ZFSSend, since they affect the size estimate (e.g. -c).
 // May return BookmarkSizeEstimationNotSupported as err if from is a bookmark.
-func ZFSSendDry(fs string, from, to string, token string) (_ *DrySendInfo, err error) {
+func ZFSSendDry(fs string, from, to string, token string, flags ZFSSendFlags) (_ *DrySendInfo, err error) {
 
-	if strings.Contains(from, "#") {
+	if token == "" && strings.Contains(from, "#") {
 		/* TODO:
 		 * ZFS at the time of writing does not support dry-run send because size-estimation
 		 * uses fromSnap's deadlist. However, for a bookmark, that deadlist no longer exists.
@@ -436,7 +471,7 @@ func ZFSSendDry(fs string, from, to string, token string) (_ *DrySendInfo, err e
 
 	args := make([]string, 0)
 	args = append(args, "send", "-n", "-v", "-P")
-	sargs, err := buildCommonSendArgs(fs, from, to, token)
+	sargs, err := buildCommonSendArgs(fs, from, to, token, flags)
 	if err != nil {
 		return nil, err
 	}
@@ -497,6 +532,56 @@ func ZFSRecv(ctx context.Context, fs string, stream io.Reader, additionalArgs ..
 	return nil
 }
 
+// ZFSRecvProperties are the property overrides (zfs recv -o) and exclusions (zfs recv -x)
+// applied when receiving a stream.
+type ZFSRecvProperties struct {
+	Override map[string]string
+	Exclude  []string
+}
+
+func (p ZFSRecvProperties) Validate() error {
+	validName := func(name string) error {
+		if name == "" || strings.ContainsAny(name, "= \t\n") {
+			return fmt.Errorf("invalid property name %q", name)
+		}
+		if name == ZREPL_PLACEHOLDER_PROPERTY_NAME {
+			return fmt.Errorf("property %q is managed by zrepl", name)
+		}
+		return nil
+	}
+	for name := range p.Override {
+		if err := validName(name); err != nil {
+			return err
+		}
+	}
+	for _, name := range p.Exclude {
+		if err := validName(name); err != nil {
+			return err
+		}
+		if _, ok := p.Override[name]; ok {
+			return fmt.Errorf("property %q cannot be both overridden and excluded", name)
+		}
+	}
+	return nil
+}
+
+// Args returns the zfs recv arguments for p, in a stable order.
+func (p ZFSRecvProperties) Args() []string {
+	names := make([]string, 0, len(p.Override))
+	for name := range p.Override {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args := make([]string, 0, 2*(len(p.Override)+len(p.Exclude)))
+	for _, name := range names {
+		args = append(args, "-o", fmt.Sprintf("%s=%s", name, p.Override[name]))
+	}
+	for _, name := range p.Exclude {
+		args = append(args, "-x", name)
+	}
+	return args
+}
+
 type ClearResumeTokenError struct {
 	ZFSOutput []byte
 	CmdError error
@@ -733,6 +818,47 @@ func ZFSSnapshot(fs *DatasetPath, name string, recursive bool) (err error) {
 
 }
 
+// ZFSSnapshotAtomic creates snapshot name of all fss in a single zfs snapshot invocation.
+// ZFS creates such snapshots atomically, i.e. either all or none of them are created.
+// All fss must be in the same pool.
+func ZFSSnapshotAtomic(fss []*DatasetPath, name string) (err error) {
+
+	if len(fss) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	defer func() {
+		took := time.Since(start).Seconds()
+		for _, fs := range fss {
+			prom.ZFSSnapshotDuration.WithLabelValues(fs.ToString()).Observe(took)
+		}
+	}()
+
+	args := []string{"snapshot"}
+	for _, fs := range fss {
+		args = append(args, zfsBuildSnapName(fs, name))
+	}
+	cmd := exec.Command(ZFS_BINARY, args...)
+
+	stderr := bytes.NewBuffer(make([]byte, 0, 1024))
+	cmd.Stderr = stderr
+
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+
+	if err = cmd.Wait(); err != nil {
+		err = ZFSError{
+			Stderr:  stderr.Bytes(),
+			WaitErr: err,
+		}
+	}
+
+	return
+
+}
+
 func ZFSBookmark(fs *DatasetPath, snapshot, bookmark string) (err error) {
 
 	promTimer := prometheus.NewTimer(prom.ZFSBookmarkDuration.WithLabelValues(fs.ToString()))
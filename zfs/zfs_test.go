@@ -206,3 +206,70 @@ size	10518512
 		})
 	}
 }
+
+func TestZFSRecvProperties(t *testing.T) {
+
+	p := ZFSRecvProperties{
+		Override: map[string]string{
+			"readonly":   "on",
+			"mountpoint": "none",
+			"canmount":   "off",
+		},
+		Exclude: []string{"keylocation", "com.example:prop"},
+	}
+	assert.NoError(t, p.Validate())
+	assert.Equal(t, []string{
+		"-o", "canmount=off",
+		"-o", "mountpoint=none",
+		"-o", "readonly=on",
+		"-x", "keylocation",
+		"-x", "com.example:prop",
+	}, p.Args())
+
+	assert.Empty(t, ZFSRecvProperties{}.Args())
+
+	invalid := []ZFSRecvProperties{
+		{Override: map[string]string{"": "on"}},
+		{Override: map[string]string{"a=b": "on"}},
+		{Exclude: []string{"read only"}},
+		{Exclude: []string{ZREPL_PLACEHOLDER_PROPERTY_NAME}},
+		{Override: map[string]string{"readonly": "on"}, Exclude: []string{"readonly"}},
+	}
+	for _, p := range invalid {
+		assert.Error(t, p.Validate(), "%#v", p)
+	}
+}
+
+func TestParseHolds(t *testing.T) {
+	out := "pool/a@1\tzrepl_last_replicated_foo\tThu Oct 18 13:37 2018\n" +
+		"pool/a@1\tkeep\tFri Oct 19 08:00 2018\n"
+	holds, err := parseHolds([]byte(out))
+	assert.NoError(t, err)
+	assert.Equal(t, []Hold{
+		{Snapshot: "pool/a@1", Tag: "zrepl_last_replicated_foo", Since: "Thu Oct 18 13:37 2018"},
+		{Snapshot: "pool/a@1", Tag: "keep", Since: "Fri Oct 19 08:00 2018"},
+	}, holds)
+	assert.True(t, IsReplicationHoldTag(holds[0].Tag))
+	assert.False(t, IsReplicationHoldTag(holds[1].Tag))
+
+	holds, err = parseHolds(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, holds)
+
+	_, err = parseHolds([]byte("pool/a@1 keep\n"))
+	assert.Error(t, err)
+}
+
+func TestReplicationBookmarkName(t *testing.T) {
+	name := ReplicationBookmarkName("zrepl_20181018_133700_000", "laptop_push")
+	assert.Equal(t, "zrepl_20181018_133700_000_zrepl_laptop_push", name)
+	snap, job, ok := ParseReplicationBookmarkName(name)
+	assert.True(t, ok)
+	assert.Equal(t, "zrepl_20181018_133700_000", snap)
+	assert.Equal(t, "laptop_push", job)
+
+	for _, bm := range []string{ReplicationCursorBookmarkName, "manual", "_zrepl_foo", "foo_zrepl_"} {
+		_, _, ok := ParseReplicationBookmarkName(bm)
+		assert.False(t, ok, bm)
+	}
+}